@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+
+	"lbtc/internal/escrow"
+)
+
+// 受托人离线工具：用受托人私钥解密份额，并对提交消息签名
+// 私钥通过环境变量 TRUSTEE_PRIVATE_KEY 提供，不经过 API 服务器
+func must(err error, msg string) {
+	if err != nil {
+		log.Fatalf("%s: %v", msg, err)
+	}
+}
+
+func main() {
+	recoveryFlag := flag.Int64("recovery", 0, "恢复流程 ID")
+	shareFlag := flag.String("share", "", "加密份额（base64，来自发起恢复的响应）")
+	pubFlag := flag.Bool("pubkey", false, "仅输出受托人公钥（用于登记受托人）")
+	flag.Parse()
+
+	privHex := os.Getenv("TRUSTEE_PRIVATE_KEY")
+	if privHex == "" {
+		log.Fatal("缺少 TRUSTEE_PRIVATE_KEY 环境变量（受托人私钥）")
+	}
+	privateKey, err := crypto.HexToECDSA(trim0x(privHex))
+	must(err, "解析私钥失败")
+
+	if *pubFlag {
+		fmt.Printf("publicKey=0x%x\n", crypto.FromECDSAPub(&privateKey.PublicKey))
+		return
+	}
+
+	if *recoveryFlag == 0 || *shareFlag == "" {
+		log.Fatal("必须指定 --recovery 和 --share")
+	}
+
+	enc, err := base64.StdEncoding.DecodeString(*shareFlag)
+	must(err, "解析加密份额失败")
+	share, err := ecies.ImportECDSA(privateKey).Decrypt(enc, nil, nil)
+	must(err, "解密份额失败")
+
+	msg := escrow.RecoveryMessage(*recoveryFlag, share)
+	sig, err := crypto.Sign(accounts.TextHash([]byte(msg)), privateKey)
+	must(err, "签名失败")
+	sig[crypto.RecoveryIDOffset] += 27
+
+	fmt.Printf("share=0x%x\n", share)
+	fmt.Printf("signature=0x%x\n", sig)
+}
+
+func trim0x(s string) string {
+	if len(s) > 1 && (s[0:2] == "0x" || s[0:2] == "0X") {
+		return s[2:]
+	}
+	return s
+}
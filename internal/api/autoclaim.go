@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+	"lbtc/internal/autoclaim"
+	"lbtc/internal/risk"
+)
+
+// AutoClaimRequest 开启自动领取请求
+type AutoClaimRequest struct {
+	Password string `json:"password,omitempty"` // 可选，钱包已解锁时可省略
+	Account  string `json:"account,omitempty"`  // 子账户标签，默认主账户
+	TOTPCode string `json:"totpCode,omitempty"` // 开启两步验证时必填
+	Consent  bool   `json:"consent"`            // 必须为 true：同意服务器保存仅用于领取奖励的委托私钥
+}
+
+// AutoClaimAccountRequest 暂停或恢复自动领取请求
+type AutoClaimAccountRequest struct {
+	Account string `json:"account,omitempty"`
+}
+
+// AutoClaimInfo 自动领取委托
+type AutoClaimInfo struct {
+	Account      string     `json:"account"`
+	Address      string     `json:"address"`
+	Status       string     `json:"status"` // active / paused / revoked
+	NextRunAt    *time.Time `json:"nextRunAt,omitempty"`
+	LastClaimDay int64      `json:"lastClaimDay,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// AutoClaimStatus 自动领取状态
+type AutoClaimStatus struct {
+	Enabled     bool            `json:"enabled"` // 服务器是否开启自动领取
+	Delegations []AutoClaimInfo `json:"delegations"`
+}
+
+// AutoClaimRun 自动领取记录
+type AutoClaimRun struct {
+	Account     string    `json:"account"`
+	Address     string    `json:"address"`
+	ClaimDay    int64     `json:"claimDay"`
+	Status      string    `json:"status"` // submitted / skipped / failed
+	TxHash      string    `json:"txHash,omitempty"`
+	JobID       int64     `json:"jobId,omitempty"`
+	OperationID string    `json:"operationId,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func autoClaimInfo(d *autoclaim.DelegationModel) AutoClaimInfo {
+	account := d.Account
+	if auth.IsDefaultAccount(account) {
+		account = auth.DefaultAccount
+	}
+	info := AutoClaimInfo{
+		Account:      account,
+		Address:      d.Address,
+		Status:       d.Status,
+		LastClaimDay: d.LastClaimDay,
+		CreatedAt:    d.CreatedAt,
+		RevokedAt:    d.RevokedAt,
+	}
+	if d.Status == autoclaim.StatusActive {
+		next := d.NextRunAt
+		info.NextRunAt = &next
+	}
+	return info
+}
+
+// respondAutoClaimError 将自动领取服务的错误转换为响应
+func respondAutoClaimError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, autoclaim.ErrDisabled):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, autoclaim.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "更新自动领取失败")
+	}
+}
+
+// normalizeAccount 将默认账户的各种写法统一为空字符串
+func normalizeAccount(account string) string {
+	if auth.IsDefaultAccount(account) {
+		return ""
+	}
+	return account
+}
+
+// 查询自动领取状态
+func (s *Server) handleAutoClaimStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	list, err := s.AutoClaim.List(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询自动领取失败")
+		return
+	}
+	infos := make([]AutoClaimInfo, 0, len(list))
+	for i := range list {
+		infos = append(infos, autoClaimInfo(&list[i]))
+	}
+	respondSuccess(w, AutoClaimStatus{Enabled: s.AutoClaim.Enabled(), Delegations: infos})
+}
+
+// 开启自动领取：用户同意后，解密私钥并以服务器委托密钥重新加密保存
+func (s *Server) handleEnableAutoClaim(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req AutoClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !req.Consent {
+		respondError(w, http.StatusBadRequest, "需要同意服务器保存用于自动领取的委托私钥（consent 为 true）")
+		return
+	}
+	if !s.AutoClaim.Enabled() {
+		respondError(w, http.StatusServiceUnavailable, autoclaim.ErrDisabled.Error())
+		return
+	}
+	account := normalizeAccount(req.Account)
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.requireTOTP(w, r, userID, req.TOTPCode) {
+		return
+	}
+	privBytes, address, err := s.signingKey(r, user, req.Password, account)
+	if err != nil {
+		respondSigningKeyError(w, err)
+		return
+	}
+	d, err := s.AutoClaim.Grant(userID, account, address, privBytes)
+	zeroBytes(privBytes)
+	if err != nil {
+		respondAutoClaimError(w, err)
+		return
+	}
+
+	s.recordAutoClaim(r, userID, d, "开启")
+	respondSuccess(w, autoClaimInfo(d))
+}
+
+// 暂停自动领取
+func (s *Server) handlePauseAutoClaim(w http.ResponseWriter, r *http.Request) {
+	s.setAutoClaimPaused(w, r, true)
+}
+
+// 恢复自动领取
+func (s *Server) handleResumeAutoClaim(w http.ResponseWriter, r *http.Request) {
+	s.setAutoClaimPaused(w, r, false)
+}
+
+func (s *Server) setAutoClaimPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req AutoClaimAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "无效的请求体")
+			return
+		}
+	}
+	d, err := s.AutoClaim.SetPaused(userID, normalizeAccount(req.Account), paused)
+	if err != nil {
+		respondAutoClaimError(w, err)
+		return
+	}
+	action := "恢复"
+	if paused {
+		action = "暂停"
+	}
+	s.recordAutoClaim(r, userID, d, action)
+	respondSuccess(w, autoClaimInfo(d))
+}
+
+// 撤销自动领取：删除委托私钥
+func (s *Server) handleRevokeAutoClaim(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	account := normalizeAccount(r.URL.Query().Get("account"))
+
+	d, err := s.AutoClaim.Revoke(userID, account)
+	if err != nil {
+		respondAutoClaimError(w, err)
+		return
+	}
+	s.recordAutoClaim(r, userID, d, "撤销")
+	respondSuccess(w, map[string]string{"message": "已撤销自动领取"})
+}
+
+// 查询自动领取记录
+func (s *Server) handleAutoClaimHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	limit := 30
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 365 之间")
+			return
+		}
+		limit = n
+	}
+	runs, err := s.AutoClaim.History(userID, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询自动领取记录失败")
+		return
+	}
+	infos := make([]AutoClaimRun, 0, len(runs))
+	for _, run := range runs {
+		account := run.Account
+		if auth.IsDefaultAccount(account) {
+			account = auth.DefaultAccount
+		}
+		infos = append(infos, AutoClaimRun{
+			Account:     account,
+			Address:     run.Address,
+			ClaimDay:    run.ClaimDay,
+			Status:      run.Status,
+			TxHash:      run.TxHash,
+			JobID:       run.JobID,
+			OperationID: run.OperationID,
+			Detail:      run.Detail,
+			CreatedAt:   run.CreatedAt,
+		})
+	}
+	respondSuccess(w, infos)
+}
+
+func (s *Server) recordAutoClaim(r *http.Request, userID int64, d *autoclaim.DelegationModel, action string) {
+	account := d.Account
+	if auth.IsDefaultAccount(account) {
+		account = auth.DefaultAccount
+	}
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventAutoClaim,
+		UserID:  &userID,
+		Subject: account,
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("%s自动领取 %s", action, d.Address),
+	})
+}
+
+// autoClaim 为委托账户提交当天的领取（autoclaim.ClaimFunc）：与手动领取共用领取锁、Gas 补贴与发送队列
+func (s *Server) autoClaim(ctx context.Context, d *autoclaim.DelegationModel, day int64) (*autoclaim.RunModel, error) {
+	locked, err := s.AuthService.IsClaimLocked(d.UserID, day, d.Account)
+	if err != nil {
+		return nil, fmt.Errorf("检查领取状态失败: %v", err)
+	}
+	if locked {
+		return &autoclaim.RunModel{Status: autoclaim.RunSkipped, Detail: "今日已提交领取"}, nil
+	}
+	from := common.HexToAddress(d.Address)
+	canClaim, err := s.canClaimDailyReward(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	if !canClaim {
+		return &autoclaim.RunModel{Status: autoclaim.RunSkipped, Detail: "今日已在链上领取"}, nil
+	}
+
+	if err := s.AuthService.AddClaimLock(d.UserID, day, d.Account); err != nil {
+		return nil, fmt.Errorf("领取锁定失败: %v", err)
+	}
+	claim, err := s.submitClaim(ctx, d.UserID, d.Account, day, from, s.AutoClaim.Signer(d))
+	if err != nil {
+		_ = s.AuthService.RemoveClaimLock(d.UserID, day, d.Account)
+		if errors.Is(err, risk.ErrBlocked) {
+			return &autoclaim.RunModel{Status: autoclaim.RunSkipped, Detail: err.Error()}, nil
+		}
+		return nil, err
+	}
+	run := &autoclaim.RunModel{
+		Status: autoclaim.RunSubmitted,
+		TxHash: claim.Tx.Hash().Hex(),
+		JobID:  claim.Job.ID,
+	}
+	if claim.Operation != nil {
+		run.OperationID = claim.Operation.ID
+	}
+	return run, nil
+}
+
+// canClaimDailyReward 调用合约的 canClaimDailyReward 查询地址今天是否还能领取
+func (s *Server) canClaimDailyReward(ctx context.Context, address common.Address) (bool, error) {
+	data, err := s.Contract.ABI.Pack("canClaimDailyReward", address)
+	if err != nil {
+		return false, fmt.Errorf("打包调用失败: %v", err)
+	}
+	contract := s.ContractAddress
+	result, err := s.Client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return false, fmt.Errorf("调用合约失败: %v", err)
+	}
+	if len(result) < 32 {
+		return false, fmt.Errorf("canClaimDailyReward 返回值无效")
+	}
+	return result[31] != 0, nil
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/bonus"
+)
+
+// BonusPayoutInfo 连续领取奖金发放记录（管理员）
+type BonusPayoutInfo struct {
+	ID        int64     `json:"id"`
+	Address   string    `json:"address"`
+	Milestone int       `json:"milestone"`
+	StartDay  int64     `json:"startDay"`
+	ClaimDay  int64     `json:"claimDay"`
+	Amount    string    `json:"amount"`
+	Status    string    `json:"status"`
+	JobID     int64     `json:"jobId,omitempty"`
+	TxHash    string    `json:"txHash,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 管理员查询连续领取奖金发放记录
+func (s *Server) handleListBonusPayouts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "", bonus.StatusPending, bonus.StatusSent, bonus.StatusFailed:
+	default:
+		respondError(w, http.StatusBadRequest, "无效的 status")
+		return
+	}
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 1000 之间")
+			return
+		}
+		limit = n
+	}
+
+	payouts, err := s.Bonus.List(status, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询奖金记录失败")
+		return
+	}
+	infos := make([]BonusPayoutInfo, 0, len(payouts))
+	for i := range payouts {
+		infos = append(infos, bonusPayoutInfo(&payouts[i]))
+	}
+	respondSuccess(w, infos)
+}
+
+// 管理员重试发送失败的连续领取奖金
+func (s *Server) handleRetryBonusPayout(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "无效的奖金 ID")
+		return
+	}
+
+	payout, err := s.Bonus.Retry(id, s.requeueBonus)
+	if err != nil {
+		switch {
+		case errors.Is(err, bonus.ErrNotFound):
+			respondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, bonus.ErrNotRetryable):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("重试奖金失败: %v", err))
+		}
+		return
+	}
+
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventBonusAdmin,
+		UserID:  &userID,
+		Subject: fmt.Sprintf("bonus:%d", payout.ID),
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("重试 %s 连续领取 %d 天的奖金", payout.Address, payout.Milestone),
+	})
+	respondSuccess(w, bonusPayoutInfo(payout))
+}
+
+func bonusPayoutInfo(p *bonus.PayoutModel) BonusPayoutInfo {
+	return BonusPayoutInfo{
+		ID:        p.ID,
+		Address:   p.Address,
+		Milestone: p.Milestone,
+		StartDay:  p.StartDay,
+		ClaimDay:  p.ClaimDay,
+		Amount:    p.Amount,
+		Status:    p.Status,
+		JobID:     p.JobID,
+		TxHash:    p.TxHash,
+		Error:     p.Error,
+		CreatedAt: p.CreatedAt,
+	}
+}
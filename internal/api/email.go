@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/config"
+	"lbtc/internal/mail"
+)
+
+// MagicLinkRequest 申请免密登录链接请求
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+// MagicLinkLoginRequest 使用免密登录链接登录请求
+type MagicLinkLoginRequest struct {
+	Token    string `json:"token"`
+	TOTPCode string `json:"totpCode,omitempty"` // 开启登录两步验证时必填
+}
+
+// newMailer 按配置选择发信方式：SMTP > 文件 > 日志
+func newMailer() mail.Mailer {
+	if smtpCfg := config.GetSMTPConfig(); smtpCfg.Host != "" {
+		return &mail.SMTPMailer{
+			Host:     smtpCfg.Host,
+			Port:     smtpCfg.Port,
+			Username: smtpCfg.Username,
+			Password: smtpCfg.Password,
+			From:     config.GetMailFrom(),
+		}
+	}
+	if path := config.GetMailFile(); path != "" {
+		return &mail.FileMailer{Path: path, From: config.GetMailFrom()}
+	}
+	log.Printf("警告: 未配置 SMTP_HOST 或 MAIL_FILE，邮件只会打印到日志")
+	return mail.LogMailer{}
+}
+
+// sendVerificationEmail 发送邮箱验证链接，失败只记录日志（用户可重新发送）
+func (s *Server) sendVerificationEmail(user *auth.User) {
+	token, err := s.AuthService.EmailVerificationToken(user)
+	if err != nil {
+		log.Printf("用户 %d 生成邮箱验证令牌失败: %v", user.ID, err)
+		return
+	}
+	link := config.GetAppBaseURL() + "/api/auth/verify-email?token=" + url.QueryEscape(token)
+	err = s.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "验证你的 QXB 账户邮箱",
+		Body:    fmt.Sprintf("请在 48 小时内打开以下链接完成邮箱验证：\n\n%s\n\n如果不是你本人注册，请忽略本邮件。", link),
+	})
+	if err != nil {
+		log.Printf("用户 %d 发送验证邮件失败: %v", user.ID, err)
+	}
+}
+
+// verifiedOnly 要求已验证邮箱（自托管账户不受限制）
+func (s *Server) verifiedOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(int64)
+		user, err := s.AuthService.GetByID(userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+			return
+		}
+		if !user.EmailVerified() {
+			respondError(w, http.StatusForbidden, auth.ErrEmailNotVerified.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+// 打开邮件中的验证链接
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondError(w, http.StatusBadRequest, "缺少 token")
+		return
+	}
+
+	user, err := s.AuthService.VerifyEmail(token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "验证邮箱失败")
+		return
+	}
+	respondSuccess(w, UserInfo{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		EmailVerified: true,
+	})
+}
+
+// 重新发送验证邮件
+func (s *Server) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if user.EmailVerified() {
+		respondError(w, http.StatusBadRequest, "邮箱已验证")
+		return
+	}
+
+	s.sendVerificationEmail(user)
+	respondSuccess(w, map[string]interface{}{"sent": true})
+}
+
+// 申请免密登录链接；无论邮箱是否存在都返回相同结果，避免泄露注册信息
+func (s *Server) handleMagicLinkRequest(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if err := auth.ValidateEmail(req.Email); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.allow(w, "magic:ip:"+clientIP(r), magicLinkIPRule) ||
+		!s.allow(w, "magic:email:"+normalizeEmailKey(req.Email), magicLinkEmailRule) {
+		return
+	}
+
+	// 异步发送，响应时间不随邮箱是否存在而变化
+	go func(email string) {
+		token, user, err := s.AuthService.NewMagicLink(email)
+		if err != nil {
+			if !errors.Is(err, auth.ErrMagicLinkThrottled) && !errors.Is(err, auth.ErrNonCustodial) {
+				log.Printf("生成免密登录链接失败: %v", err)
+			}
+			return
+		}
+		link := config.GetMagicLinkURL() + "?token=" + url.QueryEscape(token)
+		err = s.Mailer.Send(mail.Message{
+			To:      user.Email,
+			Subject: "登录 QXB",
+			Body:    fmt.Sprintf("打开以下链接即可登录（15 分钟内有效，只能使用一次）：\n\n%s\n\n如果不是你本人操作，请忽略本邮件。", link),
+		})
+		if err != nil {
+			log.Printf("用户 %d 发送登录邮件失败: %v", user.ID, err)
+		}
+	}(req.Email)
+
+	respondSuccess(w, map[string]interface{}{"sent": true})
+}
+
+// 使用免密登录链接登录；签发的令牌与密码登录相同，但签名操作仍需要密码解密私钥
+func (s *Server) handleMagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "缺少 token")
+		return
+	}
+
+	// 先检查两步验证，验证码错误时链接仍然可用
+	user, err := s.AuthService.PeekMagicLink(req.Token)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, auth.ErrInvalidMagicLink.Error())
+		return
+	}
+	totp, err := s.AuthService.GetTOTPStatus(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return
+	}
+	if !s.checkLoginLockout(w, r, user.Email) {
+		return
+	}
+	if totp.Enabled && totp.RequireForLogin && !s.checkTOTP(w, r, user.ID, req.TOTPCode) {
+		if req.TOTPCode != "" {
+			s.loginFailed(r, user.Email)
+		}
+		return
+	}
+
+	user, err = s.AuthService.ConsumeMagicLink(req.Token)
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, auth.ErrInvalidMagicLink.Error())
+		return
+	}
+	tokens, err := s.createSession(r, user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成令牌失败")
+		return
+	}
+
+	respondSuccess(w, LoginResponse{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresIn:     tokens.ExpiresIn,
+		EmailVerified: true,
+	})
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+	"lbtc/internal/config"
+	"lbtc/internal/escrow"
+	"lbtc/internal/mail"
+)
+
+// AddTrusteeRequest 添加受托人请求
+type AddTrusteeRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"` // secp256k1 公钥（hex，压缩或未压缩）
+}
+
+// TrusteeInfo 受托人信息
+type TrusteeInfo struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
+
+// EscrowEnrollRequest 开启私钥托管请求
+type EscrowEnrollRequest struct {
+	Password  string  `json:"password"`
+	Trustees  []int64 `json:"trustees"`
+	Threshold int     `json:"threshold"`
+}
+
+// EscrowStatus 托管状态
+type EscrowStatus struct {
+	Enabled   bool          `json:"enabled"`
+	Threshold int           `json:"threshold,omitempty"`
+	Total     int           `json:"total,omitempty"`
+	CreatedAt string        `json:"createdAt,omitempty"`
+	Recovery  *RecoveryInfo `json:"recovery,omitempty"` // 进行中的恢复流程（如非本人发起，请立即取消）
+}
+
+// StartRecoveryRequest 发起恢复请求
+type StartRecoveryRequest struct {
+	Email string `json:"email"`
+}
+
+// EncryptedShare 加密给受托人的份额
+type EncryptedShare struct {
+	TrusteeID int64  `json:"trusteeId"`
+	EncShare  string `json:"encShare"` // ECIES 密文（base64）
+}
+
+// RecoveryInfo 恢复流程状态
+type RecoveryInfo struct {
+	RecoveryID int64            `json:"recoveryId"`
+	Status     string           `json:"status"` // pending / ready / completed / cancelled
+	Threshold  int              `json:"threshold"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	CompleteAt time.Time        `json:"completeAt"` // 冷静期结束时间，之前不能完成恢复
+	Shares     []EncryptedShare `json:"shares,omitempty"`
+}
+
+// CancelRecoveryRequest 取消恢复请求；账户所有者登录后取消时无需令牌
+type CancelRecoveryRequest struct {
+	Token string `json:"token,omitempty"`
+}
+
+// SubmitShareRequest 受托人提交份额请求
+type SubmitShareRequest struct {
+	TrusteeID int64  `json:"trusteeId"`
+	Share     string `json:"share"`     // 解密后的份额（hex）
+	Signature string `json:"signature"` // 受托人对 RecoveryMessage 的 personal_sign 签名（hex）
+}
+
+// SubmitShareResponse 提交份额响应
+type SubmitShareResponse struct {
+	Status    string `json:"status"`
+	Submitted int    `json:"submitted"`
+	Threshold int    `json:"threshold"`
+}
+
+// CompleteRecoveryRequest 完成恢复请求
+type CompleteRecoveryRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// enrollEscrow 解密用户私钥与助记词并拆分给受托人
+func (s *Server) enrollEscrow(user *auth.User, password string, trusteeIDs []int64, threshold int) error {
+	secret, err := s.AuthService.WalletSecret(user, password)
+	if err != nil {
+		return errors.New("密码错误或解密失败")
+	}
+	defer zeroBytes(secret)
+	return s.EscrowService.Enroll(user.ID, user.Address, secret, trusteeIDs, threshold)
+}
+
+// 添加受托人（管理员）
+func (s *Server) handleAddTrustee(w http.ResponseWriter, r *http.Request) {
+	var req AddTrusteeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Name == "" || req.PublicKey == "" {
+		respondError(w, http.StatusBadRequest, "名称和公钥不能为空")
+		return
+	}
+
+	trustee, err := s.EscrowService.AddTrustee(req.Name, req.PublicKey)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("添加受托人失败: %v", err))
+		return
+	}
+
+	respondSuccess(w, TrusteeInfo{
+		ID:        trustee.ID,
+		Name:      trustee.Name,
+		PublicKey: trustee.PublicKey,
+	})
+}
+
+// 列出受托人
+func (s *Server) handleListTrustees(w http.ResponseWriter, r *http.Request) {
+	trustees, err := s.EscrowService.ListTrustees()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询受托人失败")
+		return
+	}
+
+	infos := make([]TrusteeInfo, 0, len(trustees))
+	for _, t := range trustees {
+		infos = append(infos, TrusteeInfo{ID: t.ID, Name: t.Name, PublicKey: t.PublicKey})
+	}
+	respondSuccess(w, infos)
+}
+
+// 开启（或重新配置）私钥托管
+func (s *Server) handleEscrowEnroll(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req EscrowEnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Password == "" || len(req.Trustees) == 0 {
+		respondError(w, http.StatusBadRequest, "密码和受托人不能为空")
+		return
+	}
+	if req.Threshold < 2 || req.Threshold > len(req.Trustees) {
+		respondError(w, http.StatusBadRequest, "托管门限必须在 2 到受托人数量之间")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+
+	if err := s.enrollEscrow(user, req.Password, req.Trustees, req.Threshold); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("开启私钥托管失败: %v", err))
+		return
+	}
+
+	respondSuccess(w, EscrowStatus{
+		Enabled:   true,
+		Threshold: req.Threshold,
+		Total:     len(req.Trustees),
+	})
+}
+
+// 查询托管状态
+func (s *Server) handleEscrowStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	e, err := s.EscrowService.GetEscrow(userID)
+	if err != nil {
+		if errors.Is(err, escrow.ErrNotEnrolled) {
+			respondSuccess(w, EscrowStatus{Enabled: false})
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "查询托管状态失败")
+		return
+	}
+
+	status := EscrowStatus{
+		Enabled:   true,
+		Threshold: e.Threshold,
+		Total:     e.Total,
+		CreatedAt: e.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	recovery, err := s.EscrowService.ActiveRecovery(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询恢复流程失败")
+		return
+	}
+	if recovery != nil {
+		if status.Recovery, err = s.recoveryInfo(recovery, nil); err != nil {
+			respondError(w, http.StatusInternalServerError, "查询托管状态失败")
+			return
+		}
+	}
+	respondSuccess(w, status)
+}
+
+// recoveryInfo 恢复流程状态；shares 为空时不返回份额
+func (s *Server) recoveryInfo(recovery *escrow.RecoveryModel, shares []escrow.ShareModel) (*RecoveryInfo, error) {
+	e, err := s.EscrowService.GetEscrow(recovery.UserID)
+	if err != nil {
+		return nil, err
+	}
+	info := &RecoveryInfo{
+		RecoveryID: recovery.ID,
+		Status:     recovery.Status,
+		Threshold:  e.Threshold,
+		CreatedAt:  recovery.CreatedAt,
+		CompleteAt: recovery.CompleteAt,
+	}
+	for _, sh := range shares {
+		info.Shares = append(info.Shares, EncryptedShare{TrusteeID: sh.TrusteeID, EncShare: sh.EncShareB64})
+	}
+	return info, nil
+}
+
+// notifyRecovery 给账户邮箱发送恢复相关邮件，失败只记录日志
+func (s *Server) notifyRecovery(user *auth.User, subject, body string) {
+	if err := s.Mailer.Send(mail.Message{To: user.Email, Subject: subject, Body: body}); err != nil {
+		log.Printf("用户 %d 发送恢复通知失败: %v", user.ID, err)
+	}
+}
+
+// startRecovery 为已验证邮箱的托管用户发起恢复，恢复令牌只发送到该邮箱
+func (s *Server) startRecovery(email, ip string) {
+	user, err := s.AuthService.GetByEmail(email)
+	if err != nil || user.NonCustodial || user.EmailVerifiedAt == nil {
+		return
+	}
+	recovery, token, err := s.EscrowService.StartRecovery(user.ID)
+	if err != nil {
+		if !errors.Is(err, escrow.ErrNotEnrolled) && !errors.Is(err, escrow.ErrRecoveryActive) {
+			log.Printf("用户 %d 发起恢复失败: %v", user.ID, err)
+		}
+		return
+	}
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventRecovery,
+		UserID:  &user.ID,
+		Subject: user.Email,
+		IP:      ip,
+		Detail:  fmt.Sprintf("发起私钥恢复 %d，冷静期至 %s", recovery.ID, recovery.CompleteAt.UTC().Format(time.RFC3339)),
+	})
+
+	base := config.GetAppBaseURL()
+	s.notifyRecovery(user, "QXB 私钥恢复已发起", fmt.Sprintf(
+		"有人（IP %s）为你的账户发起了私钥恢复（编号 %d）。\n\n"+
+			"恢复令牌：%s\n\n"+
+			"受托人提交份额后，可在 %s 之后使用该令牌设置新密码（POST %s/api/escrow/recovery/%d/complete）。\n"+
+			"受托人可在 %s/api/escrow/recovery/%d 获取加密份额。\n\n"+
+			"如果不是你本人操作，请立即登录后取消，或使用上面的令牌取消（POST %s/api/escrow/recovery/%d/cancel），并不要把令牌告诉任何人。",
+		ip, recovery.ID, token,
+		recovery.CompleteAt.UTC().Format(time.RFC3339), base, recovery.ID,
+		base, recovery.ID,
+		base, recovery.ID))
+}
+
+// 发起私钥恢复；恢复令牌只发送到账户的已验证邮箱，无论邮箱是否存在都返回相同结果
+func (s *Server) handleStartRecovery(w http.ResponseWriter, r *http.Request) {
+	var req StartRecoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if err := auth.ValidateEmail(req.Email); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ip := clientIP(r)
+	if !s.allow(w, "recovery:ip:"+ip, recoveryIPRule) ||
+		!s.allow(w, "recovery:email:"+normalizeEmailKey(req.Email), recoveryEmailRule) {
+		return
+	}
+
+	// 异步处理，响应时间不随邮箱是否存在或是否开启托管而变化
+	go s.startRecovery(req.Email, ip)
+
+	respondSuccess(w, map[string]interface{}{"sent": true})
+}
+
+// 查询恢复流程与加密份额（供受托人解密）
+func (s *Server) handleGetRecovery(w http.ResponseWriter, r *http.Request) {
+	recoveryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的恢复 ID")
+		return
+	}
+	recovery, shares, err := s.EscrowService.GetRecovery(recoveryID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, escrow.ErrRecoveryClosed.Error())
+		return
+	}
+	if recovery.Status != escrow.RecoveryPending {
+		shares = nil
+	}
+	info, err := s.recoveryInfo(recovery, shares)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询托管状态失败")
+		return
+	}
+	respondSuccess(w, info)
+}
+
+// 取消恢复：账户所有者登录后可直接取消，否则需要恢复令牌
+func (s *Server) handleCancelRecovery(w http.ResponseWriter, r *http.Request) {
+	recoveryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的恢复 ID")
+		return
+	}
+	var req CancelRecoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	userID, _ := r.Context().Value(contextKeyUserID).(int64)
+
+	recovery, err := s.EscrowService.CancelRecovery(recoveryID, userID, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, escrow.ErrRecoveryClosed):
+			respondError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, escrow.ErrInvalidRecoveryToken):
+			respondError(w, http.StatusForbidden, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "取消恢复失败")
+		}
+		return
+	}
+
+	event := audit.EventModel{
+		Type:   audit.EventRecovery,
+		UserID: &recovery.UserID,
+		IP:     clientIP(r),
+		Detail: fmt.Sprintf("取消私钥恢复 %d", recovery.ID),
+	}
+	if user, err := s.AuthService.GetByID(recovery.UserID); err == nil {
+		event.Subject = user.Email
+		s.notifyRecovery(user, "QXB 私钥恢复已取消", fmt.Sprintf("你的账户的私钥恢复（编号 %d）已被取消。", recovery.ID))
+	}
+	s.Audit.Record(event)
+
+	info, err := s.recoveryInfo(recovery, nil)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询托管状态失败")
+		return
+	}
+	respondSuccess(w, info)
+}
+
+// 受托人提交份额
+func (s *Server) handleSubmitShare(w http.ResponseWriter, r *http.Request) {
+	recoveryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的恢复 ID")
+		return
+	}
+
+	var req SubmitShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	share := common.FromHex(req.Share)
+	signature := common.FromHex(req.Signature)
+	if req.TrusteeID == 0 || len(share) == 0 || len(signature) == 0 {
+		respondError(w, http.StatusBadRequest, "受托人、份额和签名不能为空")
+		return
+	}
+
+	recovery, submitted, err := s.EscrowService.SubmitShare(recoveryID, req.TrusteeID, share, signature)
+	if err != nil {
+		if errors.Is(err, escrow.ErrRecoveryClosed) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("提交份额失败: %v", err))
+		return
+	}
+	e, err := s.EscrowService.GetEscrow(recovery.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询托管状态失败")
+		return
+	}
+
+	respondSuccess(w, SubmitShareResponse{
+		Status:    recovery.Status,
+		Submitted: submitted,
+		Threshold: e.Threshold,
+	})
+}
+
+// 完成恢复：使用恢复令牌取回私钥并设置新密码
+func (s *Server) handleCompleteRecovery(w http.ResponseWriter, r *http.Request) {
+	recoveryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的恢复 ID")
+		return
+	}
+
+	var req CompleteRecoveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "恢复令牌和新密码不能为空")
+		return
+	}
+
+	userID, secret, err := s.EscrowService.CompleteRecovery(recoveryID, req.Token)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("完成恢复失败: %v", err))
+		return
+	}
+	defer zeroBytes(secret)
+
+	if err := s.AuthService.ResetPassword(userID, req.NewPassword, secret); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("重置密码失败: %v", err))
+		return
+	}
+	s.Unlocked.LockUser(userID)
+	if _, err := s.AuthService.RevokeOtherSessions(userID, ""); err != nil {
+		log.Printf("用户 %d 恢复后注销会话失败: %v", userID, err)
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventRecovery,
+		UserID:  &user.ID,
+		Subject: user.Email,
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("完成私钥恢复 %d 并重置密码", recoveryID),
+	})
+	s.notifyRecovery(user, "QXB 私钥恢复已完成", fmt.Sprintf("你的账户已通过私钥恢复（编号 %d）重置密码，其他登录会话已注销。如果不是你本人操作，请立即联系管理员。", recoveryID))
+	respondSuccess(w, UserInfo{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Address: user.Address,
+	})
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"lbtc/internal/faucet"
+)
+
+// FaucetPolicyInfo Gas 补贴策略（金额均为 wei，"0" 表示不限）
+type FaucetPolicyInfo struct {
+	Threshold       string `json:"threshold"`
+	TopUp           string `json:"topUp"`
+	UserDailyBudget string `json:"userDailyBudget"`
+	DailyBudget     string `json:"dailyBudget"`
+	TotalBudget     string `json:"totalBudget"`
+	CooldownSeconds int64  `json:"cooldownSeconds"`
+}
+
+// FaucetUserBudgetInfo 单个用户（或地址）的补贴额度
+type FaucetUserBudgetInfo struct {
+	UserID         int64      `json:"userId,omitempty"`
+	Address        string     `json:"address,omitempty"`
+	SpentToday     string     `json:"spentToday"`
+	RemainingToday *string    `json:"remainingToday"` // null 表示不限
+	NextGrantAt    *time.Time `json:"nextGrantAt,omitempty"`
+}
+
+// FaucetBudgetResponse 补贴策略与额度使用情况
+type FaucetBudgetResponse struct {
+	Policy         FaucetPolicyInfo      `json:"policy"`
+	SpentToday     string                `json:"spentToday"`
+	RemainingToday *string               `json:"remainingToday"` // null 表示不限
+	GrantsToday    int64                 `json:"grantsToday"`
+	SpentTotal     string                `json:"spentTotal"`
+	RemainingTotal *string               `json:"remainingTotal"` // null 表示不限
+	User           *FaucetUserBudgetInfo `json:"user,omitempty"`
+}
+
+// FaucetGrantInfo 补贴记录
+type FaucetGrantInfo struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId,omitempty"`
+	Address   string    `json:"address"`
+	Amount    string    `json:"amount"`
+	TxHash    string    `json:"txHash,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// respondFundError 自动补贴 ETH 失败：额度用尽或冷却中返回 429，其他错误返回 500
+func respondFundError(w http.ResponseWriter, err error) {
+	var refused *faucet.ErrRefused
+	if errors.As(err, &refused) {
+		if refused.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64((refused.RetryAfter+time.Second-1)/time.Second), 10))
+		}
+		respondError(w, http.StatusTooManyRequests, refused.Error())
+		return
+	}
+	respondError(w, http.StatusInternalServerError, fmt.Sprintf("自动转账 ETH 失败: %v", err))
+}
+
+func optionalWei(v *big.Int) *string {
+	if v == nil {
+		return nil
+	}
+	s := v.String()
+	return &s
+}
+
+// faucetSubject 解析管理员查询中的 userId 与 address 参数
+func faucetSubject(r *http.Request) (int64, string, error) {
+	var userID int64
+	if v := r.URL.Query().Get("userId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return 0, "", fmt.Errorf("无效的 userId")
+		}
+		userID = id
+	}
+	var address string
+	if v := r.URL.Query().Get("address"); v != "" {
+		if !common.IsHexAddress(v) {
+			return 0, "", fmt.Errorf("无效的地址")
+		}
+		address = common.HexToAddress(v).Hex()
+	}
+	return userID, address, nil
+}
+
+// 查询 Gas 补贴策略与剩余额度（管理员），可用 userId / address 查询单个用户
+func (s *Server) handleFaucetBudget(w http.ResponseWriter, r *http.Request) {
+	userID, address, err := faucetSubject(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	budget, err := s.Faucet.Summary()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询补贴额度失败")
+		return
+	}
+	policy := s.Faucet.Policy()
+	resp := FaucetBudgetResponse{
+		Policy: FaucetPolicyInfo{
+			Threshold:       policy.Threshold.String(),
+			TopUp:           policy.TopUp.String(),
+			UserDailyBudget: policy.UserDailyBudget.String(),
+			DailyBudget:     policy.DailyBudget.String(),
+			TotalBudget:     policy.TotalBudget.String(),
+			CooldownSeconds: int64(policy.Cooldown / time.Second),
+		},
+		SpentToday:     budget.SpentToday.String(),
+		RemainingToday: optionalWei(budget.RemainingToday),
+		GrantsToday:    budget.GrantsToday,
+		SpentTotal:     budget.SpentTotal.String(),
+		RemainingTotal: optionalWei(budget.RemainingTotal),
+	}
+
+	if userID > 0 || address != "" {
+		user, err := s.Faucet.UserSummary(userID, address)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "查询补贴额度失败")
+			return
+		}
+		resp.User = &FaucetUserBudgetInfo{
+			UserID:         userID,
+			Address:        address,
+			SpentToday:     user.SpentToday.String(),
+			RemainingToday: optionalWei(user.RemainingToday),
+			NextGrantAt:    user.NextGrantAt,
+		}
+	}
+	respondSuccess(w, resp)
+}
+
+// 列出 Gas 补贴记录（管理员）
+func (s *Server) handleListFaucetGrants(w http.ResponseWriter, r *http.Request) {
+	userID, address, err := faucetSubject(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 1000 之间")
+			return
+		}
+		limit = n
+	}
+
+	grants, err := s.Faucet.List(userID, address, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询补贴记录失败")
+		return
+	}
+	infos := make([]FaucetGrantInfo, 0, len(grants))
+	for _, g := range grants {
+		infos = append(infos, FaucetGrantInfo{
+			ID:        g.ID,
+			UserID:    g.UserID,
+			Address:   g.Address,
+			Amount:    g.Amount,
+			TxHash:    g.TxHash,
+			Status:    g.Status,
+			Error:     g.Error,
+			CreatedAt: g.CreatedAt,
+		})
+	}
+	respondSuccess(w, infos)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -18,6 +19,12 @@ import (
 	"github.com/gorilla/mux"
 
 	"lbtc/internal/auth"
+	"lbtc/internal/config"
+	"lbtc/internal/faucet"
+	"lbtc/internal/jobqueue"
+	"lbtc/internal/operation"
+	"lbtc/internal/pow"
+	"lbtc/internal/risk"
 )
 
 // contextKey 用于 context 值的自定义类型
@@ -26,6 +33,10 @@ type contextKey string
 const (
 	contextKeyUserID    contextKey = "user_id"
 	contextKeyUserEmail contextKey = "user_email"
+	contextKeySessionID contextKey = "session_id"
+
+	contextKeyNonCustodial contextKey = "non_custodial"
+	contextKeyPoW          contextKey = "pow" // 请求已通过工作量证明
 )
 
 // TokenInfo 代币信息
@@ -46,64 +57,110 @@ type BalanceInfo struct {
 
 // RewardStatus 奖励状态
 type RewardStatus struct {
-	Address      string `json:"address"`
-	CanClaim     bool   `json:"canClaim"`
-	LastClaimDay uint64 `json:"lastClaimDay"`
-	NextClaimDay uint64 `json:"nextClaimDay"`
+	Address       string            `json:"address"`
+	CanClaim      bool              `json:"canClaim"`
+	LastClaimDay  uint64            `json:"lastClaimDay"`
+	NextClaimDay  uint64            `json:"nextClaimDay"`
+	CurrentStreak int               `json:"currentStreak"`           // 当前连续领取天数（今天尚未领取时，昨天领取过仍算连续）
+	LongestStreak int               `json:"longestStreak"`           // 历史最长连续领取天数
+	NextMilestone *StreakMilestone  `json:"nextMilestone,omitempty"` // 下一个奖金里程碑，未开启奖金或已全部达成时省略
+	StreakBonuses []StreakBonusInfo `json:"streakBonuses"`           // 已发放的连续领取奖金
+}
+
+// StreakMilestone 连续领取奖金里程碑
+type StreakMilestone struct {
+	Days   int    `json:"days"`
+	Amount string `json:"amount"` // QXB 最小单位
+}
+
+// StreakBonusInfo 连续领取奖金发放记录
+type StreakBonusInfo struct {
+	Milestone int       `json:"milestone"`
+	Amount    string    `json:"amount"`
+	ClaimDay  int64     `json:"claimDay"`
+	Status    string    `json:"status"` // pending / sent / failed
+	TxHash    string    `json:"txHash,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 // ClaimRequest 领取奖励请求
 type ClaimRequest struct {
 	PrivateKey string `json:"privateKey,omitempty"` // 可选，如果为空则使用存储的私钥
-	Password   string `json:"password,omitempty"`   // 用于解密存储的私钥
+	Password   string `json:"password,omitempty"`   // 用于解密存储的私钥（钱包已解锁时可省略）
+	Account    string `json:"account,omitempty"`    // 可选，子账户标签，默认主账户
 }
 
 // TransferRequest 转账请求
 type TransferRequest struct {
 	To       string `json:"to"`
 	Amount   string `json:"amount"`
-	Password string `json:"password"` // 用于解密存储的私钥
+	Password string `json:"password,omitempty"` // 用于解密存储的私钥（钱包已解锁时可省略）
+	Account  string `json:"account,omitempty"`  // 可选，子账户标签，默认主账户
+	TOTPCode string `json:"totpCode,omitempty"` // 两步验证码（金额超过用户设置的阈值时必填）
+
+	Passkey *PasskeyConfirmation `json:"passkey,omitempty"` // 可选，通行密钥确认（可替代两步验证码）
 }
 
 // RegisterRequest 注册请求
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email           string  `json:"email"`
+	Password        string  `json:"password"`
+	EscrowTrustees  []int64 `json:"escrowTrustees,omitempty"`  // 可选，开启私钥托管的受托人
+	EscrowThreshold int     `json:"escrowThreshold,omitempty"` // 可选，恢复所需的份数
 }
 
 // LoginRequest 登录请求
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	TOTPCode string `json:"totpCode,omitempty"` // 开启两步验证且要求登录验证时必填
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+	TOTPCode    string `json:"totpCode,omitempty"` // 开启两步验证时必填
 }
 
 // RegisterResponse 注册响应
 type RegisterResponse struct {
-	UserID  int64  `json:"user_id"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
-	Token   string `json:"token"`
+	UserID        int64  `json:"user_id"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	Token         string `json:"token"`
+	RefreshToken  string `json:"refreshToken"`
+	ExpiresIn     int64  `json:"expiresIn"` // token 有效期（秒）
+	EscrowEnabled bool   `json:"escrowEnabled,omitempty"`
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	UserID  int64  `json:"user_id"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
-	Token   string `json:"token"`
+	UserID        int64  `json:"user_id"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	Token         string `json:"token"`
+	RefreshToken  string `json:"refreshToken"`
+	ExpiresIn     int64  `json:"expiresIn"`              // token 有效期（秒）
+	NonCustodial  bool   `json:"nonCustodial,omitempty"` // SIWE 自托管账户
+	EmailVerified bool   `json:"emailVerified"`
 }
 
 // UserInfo 用户信息
 type UserInfo struct {
-	UserID  int64  `json:"user_id"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
+	UserID        int64  `json:"user_id"`
+	Email         string `json:"email"`
+	Address       string `json:"address"`
+	NonCustodial  bool   `json:"nonCustodial,omitempty"`
+	EmailVerified bool   `json:"emailVerified"`
 }
 
 // ClaimResponse 领取奖励响应
 type ClaimResponse struct {
-	TxHash string `json:"txHash"`
-	Status string `json:"status"`
+	TxHash      string `json:"txHash"`
+	Status      string `json:"status"`
+	OperationID string `json:"operationId,omitempty"` // 需要先补贴 Gas 时返回，用于查询异步操作状态
+	JobID       int64  `json:"jobId,omitempty"`       // 交易仍在发送队列中时返回
 }
 
 // ResumeResponse 简历响应
@@ -173,15 +230,24 @@ func (s *Server) handleTokenBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	info, err := s.balanceInfo(context.Background(), address)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("查询余额失败: %v", err))
+		return
+	}
+
+	respondSuccess(w, info)
+}
+
+// balanceInfo 查询并格式化地址的代币余额
+func (s *Server) balanceInfo(ctx context.Context, address string) (*BalanceInfo, error) {
 	contract := s.ContractAddress
 	userAddr := common.HexToAddress(address)
-	ctx := context.Background()
 
 	// 查询余额
 	balance, err := s.callUint256WithParam(ctx, contract, "balanceOf", userAddr)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("查询余额失败: %v", err))
-		return
+		return nil, err
 	}
 
 	// 查询小数位数
@@ -198,13 +264,11 @@ func (s *Server) handleTokenBalance(w http.ResponseWriter, r *http.Request) {
 	// 查询符号
 	symbol, _ := s.callString(ctx, contract, "symbol")
 
-	info := BalanceInfo{
+	return &BalanceInfo{
 		Address: address,
 		Balance: balanceFloat.Text('f', 6),
 		Symbol:  symbol,
-	}
-
-	respondSuccess(w, info)
+	}, nil
 }
 
 // 查询每日奖励状态
@@ -273,6 +337,7 @@ func (s *Server) handleRewardStatus(w http.ResponseWriter, r *http.Request) {
 				LastClaimDay: lastClaimDay.Uint64(),
 				NextClaimDay: nextClaimDay.Uint64(),
 			}
+			s.fillStreak(&status, userAddr)
 			respondSuccess(w, status)
 			return
 		}
@@ -285,10 +350,45 @@ func (s *Server) handleRewardStatus(w http.ResponseWriter, r *http.Request) {
 		LastClaimDay: 0,
 		NextClaimDay: nextClaimDay.Uint64(),
 	}
+	s.fillStreak(&status, userAddr)
 
 	respondSuccess(w, status)
 }
 
+// fillStreak 根据已索引的领取事件填写连续领取天数与奖金记录；查询失败时只记录日志，不影响奖励状态
+func (s *Server) fillStreak(status *RewardStatus, address common.Address) {
+	status.StreakBonuses = []StreakBonusInfo{}
+	streak, err := s.Claims.Streak(address.Hex())
+	if err != nil {
+		log.Printf("计算 %s 的连续领取天数失败: %v", address.Hex(), err)
+		return
+	}
+	status.CurrentStreak = streak.Current
+	status.LongestStreak = streak.Longest
+	for _, m := range s.Bonus.Milestones() {
+		if m.Days > streak.Current {
+			status.NextMilestone = &StreakMilestone{Days: m.Days, Amount: m.Amount.String()}
+			break
+		}
+	}
+
+	payouts, err := s.Bonus.ListByAddress(address.Hex(), 100)
+	if err != nil {
+		log.Printf("查询 %s 的连续领取奖金失败: %v", address.Hex(), err)
+		return
+	}
+	for _, p := range payouts {
+		status.StreakBonuses = append(status.StreakBonuses, StreakBonusInfo{
+			Milestone: p.Milestone,
+			Amount:    p.Amount,
+			ClaimDay:  p.ClaimDay,
+			Status:    p.Status,
+			TxHash:    p.TxHash,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+}
+
 // 读取作者简历（Markdown）从合约
 func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
 	contract := s.ContractAddress
@@ -303,86 +403,91 @@ func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
 	respondSuccess(w, ResumeResponse{Content: content})
 }
 
-// checkAndFundETH 检查地址的 ETH 余额，如果不足则自动转账少量 ETH
-// 最小余额阈值：0.001 ETH (1000000000000000 wei)
-// waitForConfirmation: 是否等待交易确认（true=等待确认，false=只发送交易）
-func (s *Server) checkAndFundETH(ctx context.Context, address common.Address, waitForConfirmation bool) error {
+// queueTopUp 检查地址的 ETH 余额，低于补贴阈值时按补贴策略预留额度并将补贴交易放入任务队列
+// 余额充足时返回 nil；userID 为 0 表示未登录（直接提供私钥），此时按地址统计额度；额度用尽时返回 *faucet.ErrRefused
+func (s *Server) queueTopUp(ctx context.Context, userID int64, address common.Address) (*faucet.GrantModel, *jobqueue.JobModel, error) {
 	if s.OwnerPrivateKey == nil {
 		// 如果没有配置拥有者私钥，跳过自动转账
 		log.Printf("警告: 未配置拥有者私钥，无法自动转账 ETH 给 %s", address.Hex())
-		return fmt.Errorf("未配置拥有者私钥，无法自动转账 ETH")
+		return nil, nil, fmt.Errorf("未配置拥有者私钥，无法自动转账 ETH")
 	}
 
 	// 检查当前 ETH 余额
 	balance, err := s.Client.BalanceAt(ctx, address, nil)
 	if err != nil {
-		return fmt.Errorf("查询 ETH 余额失败: %v", err)
+		return nil, nil, fmt.Errorf("查询 ETH 余额失败: %v", err)
 	}
 
-	// 最小余额阈值：0.001 ETH
-	minBalance := big.NewInt(1000000000000000) // 0.001 ETH in wei
-	if balance.Cmp(minBalance) >= 0 {
+	if !s.Faucet.NeedsTopUp(balance) {
 		// 余额充足，无需转账
 		log.Printf("地址 %s ETH 余额充足: %s wei", address.Hex(), balance.String())
-		return nil
+		return nil, nil, nil
 	}
 
 	log.Printf("地址 %s ETH 余额不足: %s wei，需要自动转账", address.Hex(), balance.String())
 
-	// 余额不足，需要转账
-	// 转账金额：0.002 ETH（足够支付几次交易的 gas）
-	transferAmount := big.NewInt(2000000000000000) // 0.002 ETH in wei
-
-	// 获取拥有者地址
-	ownerAddress := crypto.PubkeyToAddress(s.OwnerPrivateKey.PublicKey)
-
-	// 获取 nonce
-	nonce, err := s.Client.PendingNonceAt(ctx, ownerAddress)
-	if err != nil {
-		return fmt.Errorf("获取 nonce 失败: %v", err)
+	// 风控标记的账户暂停补贴，需要自行转入 ETH
+	if s.riskStatus(userID, address) != risk.StatusClear {
+		return nil, nil, &faucet.ErrRefused{Reason: "账户正在风控审核中，Gas 补贴已暂停"}
 	}
 
-	// 获取链 ID
-	chainID, err := s.Client.NetworkID(ctx)
+	// 检查补贴额度并预留本次补贴
+	grant, err := s.Faucet.Reserve(userID, address.Hex())
 	if err != nil {
-		return fmt.Errorf("获取链 ID 失败: %v", err)
+		return nil, nil, err
 	}
-
-	// 获取 Gas 价格
-	gasPrice, err := s.Client.SuggestGasPrice(ctx)
+	job, err := s.enqueueTopUp(grant)
 	if err != nil {
-		return fmt.Errorf("获取 Gas 价格失败: %v", err)
+		return nil, nil, err
 	}
+	return grant, job, nil
+}
 
-	// 创建 ETH 转账交易（普通转账，不是合约调用）
-	tx := types.NewTransaction(nonce, address, transferAmount, 21000, gasPrice, nil)
-
-	// 签名交易
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), s.OwnerPrivateKey)
-	if err != nil {
-		return fmt.Errorf("签名交易失败: %v", err)
+// releaseGrant 补贴未发出或失败，释放预留的额度
+func (s *Server) releaseGrant(id int64, cause error) {
+	if err := s.Faucet.MarkFailed(id, cause); err != nil {
+		log.Printf("记录补贴 %d 失败状态出错: %v", id, err)
 	}
+}
 
-	// 发送交易
-	err = s.Client.SendTransaction(ctx, signedTx)
-	if err != nil {
-		return fmt.Errorf("发送 ETH 转账失败: %v", err)
+// checkAndFundETH 检查地址的 ETH 余额，低于补贴阈值时按补贴策略自动转账少量 ETH
+func (s *Server) checkAndFundETH(ctx context.Context, userID int64, address common.Address, waitForConfirmation bool) error {
+	grant, job, err := s.queueTopUp(ctx, userID, address)
+	if err != nil || grant == nil {
+		return err
 	}
 
-	txHash := signedTx.Hash().Hex()
-	log.Printf("✅ 自动转账 ETH 已发送: %s -> %s, 金额: %s wei, txHash: %s",
-		ownerAddress.Hex(), address.Hex(), transferAmount.String(), txHash)
-
 	// 如果需要等待确认
 	if waitForConfirmation {
+		job, err := s.waitTxJob(ctx, job.ID)
+		if err != nil {
+			return fmt.Errorf("查询补贴任务失败: %v", err)
+		}
+		switch job.Status {
+		case jobqueue.StatusSucceeded:
+		case jobqueue.StatusDead, jobqueue.StatusCanceled:
+			return fmt.Errorf("发送 ETH 转账失败: %s", job.LastError)
+		default:
+			return fmt.Errorf("ETH 转账仍在排队，请稍后再试")
+		}
+		var result jobqueue.TxResult
+		if err := json.Unmarshal([]byte(job.Result), &result); err != nil {
+			return fmt.Errorf("解析补贴任务结果失败: %v", err)
+		}
+		txHash := result.TxHash
 		log.Printf("⏳ 等待 ETH 转账确认: %s", txHash)
-		receipt, err := s.waitForTransaction(ctx, signedTx.Hash())
+		receipt, err := s.waitForTransaction(ctx, common.HexToHash(txHash))
 		if err != nil {
 			return fmt.Errorf("等待 ETH 转账确认失败: %v", err)
 		}
 
 		if receipt.Status == 0 {
-			return fmt.Errorf("ETH 转账交易失败: txHash=%s", txHash)
+			err := fmt.Errorf("ETH 转账交易失败: txHash=%s", txHash)
+			s.releaseGrant(grant.ID, err)
+			return err
+		}
+		if err := s.Faucet.MarkConfirmed(grant.ID); err != nil {
+			log.Printf("记录补贴 %d 确认状态失败: %v", grant.ID, err)
 		}
 
 		log.Printf("✅ ETH 转账已确认: %s (区块: %d)", txHash, receipt.BlockNumber.Uint64())
@@ -435,16 +540,27 @@ func (s *Server) handleClaimReward(w http.ResponseWriter, r *http.Request) {
 
 	var privateKey *ecdsa.PrivateKey
 	var fromAddress common.Address
+	var account string
+	var claimDay int64 // 0 表示未加领取锁
 	releaseLock := func() {}
 
-	// 优先使用存储的私钥（如果用户已登录且提供了密码）
+	// 优先使用存储的私钥（如果用户已登录且提供了密码，或钱包已解锁）
 	userIDVal := r.Context().Value(contextKeyUserID)
-	if userIDVal != nil && req.Password != "" {
+	if userIDVal != nil && nonCustodial(r) {
+		// 自托管账户：返回未签名交易，由用户自己签名并广播
+		s.respondUnsignedCall(w, r, userIDVal.(int64), "claimDailyReward")
+		return
+	}
+	if userIDVal != nil && (req.Password != "" || req.PrivateKey == "") {
 		userID := userIDVal.(int64)
+		account = req.Account
+		if auth.IsDefaultAccount(account) {
+			account = ""
+		}
 
 		// 当天领取锁（避免同一天重复发起，避免 pending 窗口内多次提交）
-		claimDay := time.Now().UTC().Unix() / 86400
-		locked, err := s.AuthService.IsClaimLocked(userID, claimDay)
+		claimDay = time.Now().UTC().Unix() / 86400
+		locked, err := s.AuthService.IsClaimLocked(userID, claimDay, account)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "检查领取状态失败")
 			return
@@ -453,13 +569,13 @@ func (s *Server) handleClaimReward(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusBadRequest, "今日已提交领取，请等待链上确认")
 			return
 		}
-		if err := s.AuthService.AddClaimLock(userID, claimDay); err != nil {
+		if err := s.AuthService.AddClaimLock(userID, claimDay, account); err != nil {
 			respondError(w, http.StatusInternalServerError, "领取锁定失败，请稍后再试")
 			return
 		}
 		// 失败时回滚锁；成功则保留，防止同日重复提交
 		releaseLock = func() {
-			_ = s.AuthService.RemoveClaimLock(userID, claimDay)
+			_ = s.AuthService.RemoveClaimLock(userID, claimDay, account)
 		}
 
 		user, err := s.AuthService.GetByID(userID)
@@ -469,21 +585,22 @@ func (s *Server) handleClaimReward(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		privBytes, err := s.AuthService.DecryptPrivateKey(user, req.Password)
+		privBytes, address, err := s.signingKey(r, user, req.Password, account)
 		if err != nil {
 			releaseLock()
-			respondError(w, http.StatusBadRequest, "密码错误或解密失败")
+			respondSigningKeyError(w, err)
 			return
 		}
 
 		privateKey, err = crypto.ToECDSA(privBytes)
+		zeroBytes(privBytes)
 		if err != nil {
 			releaseLock()
 			respondError(w, http.StatusInternalServerError, "解析私钥失败")
 			return
 		}
 
-		fromAddress = common.HexToAddress(user.Address)
+		fromAddress = common.HexToAddress(address)
 	} else if req.PrivateKey != "" {
 		// 使用提供的私钥（向后兼容）
 		privateKeyHex := req.PrivateKey
@@ -504,85 +621,132 @@ func (s *Server) handleClaimReward(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contract := s.ContractAddress
+	// 需要补贴时返回异步操作（202）；否则等待交易广播
+	var fundUserID int64
+	if userIDVal != nil {
+		fundUserID = userIDVal.(int64)
+	}
 	ctx := context.Background()
-
-	// 前置钩子：检查并自动转账 ETH（如果余额不足）
-	// 等待确认以确保ETH到账后再继续操作
-	if err := s.checkAndFundETH(ctx, fromAddress, true); err != nil {
+	claim, err := s.submitClaim(ctx, fundUserID, account, claimDay, fromAddress, keySigner(privateKey))
+	if err != nil {
 		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("自动转账 ETH 失败: %v", err))
+		var refused *faucet.ErrRefused
+		if errors.As(err, &refused) {
+			respondFundError(w, err)
+			return
+		}
+		if errors.Is(err, risk.ErrBlocked) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	// 获取 nonce
-	nonce, err := s.Client.PendingNonceAt(ctx, fromAddress)
-	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取 nonce 失败: %v", err))
+	if claim.Operation != nil {
+		respondJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Data: ClaimResponse{
+				TxHash:      claim.Tx.Hash().Hex(),
+				Status:      claim.Operation.Status,
+				OperationID: claim.Operation.ID,
+				JobID:       claim.Job.ID,
+			},
+		})
 		return
 	}
+	if !s.respondQueuedTx(ctx, w, claim.Job, claim.Tx) {
+		releaseLock()
+	}
+}
 
-	// 获取链 ID
-	chainID, err := s.Client.NetworkID(ctx)
+// claimSubmission 已放入发送队列的领取交易
+type claimSubmission struct {
+	Tx        *types.Transaction
+	Job       *jobqueue.JobModel
+	Operation *operation.OperationModel // 需要先补贴 Gas 时创建的异步操作
+}
+
+// submitClaim 为 from 签名 claimDailyReward 交易并放入发送队列，请求与自动领取共用
+// 前置检查 ETH 余额，不足时按补贴策略预留额度并将补贴交易放入队列，同时创建异步操作跟踪补贴与领取交易
+// claimDay 非 0 时将领取锁关联到交易；返回错误时没有交易会被发出，调用方负责释放领取锁
+func (s *Server) submitClaim(ctx context.Context, userID int64, account string, claimDay int64, from common.Address, sign txSignFunc) (*claimSubmission, error) {
+	contract := s.ContractAddress
+
+	if s.riskStatus(userID, from) == risk.StatusBlocked {
+		return nil, risk.ErrBlocked
+	}
+	grant, fundJob, err := s.queueTopUp(ctx, userID, from)
 	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取链 ID 失败: %v", err))
-		return
+		var refused *faucet.ErrRefused
+		if errors.As(err, &refused) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("自动转账 ETH 失败: %v", err)
+	}
+	abort := func(err error) (*claimSubmission, error) {
+		if grant != nil {
+			if _, cancelErr := s.Jobs.Cancel(fundJob.ID); cancelErr != nil {
+				log.Printf("取消补贴任务 %d 失败: %v", fundJob.ID, cancelErr)
+			}
+		}
+		return nil, err
 	}
 
 	// 获取 Gas 价格
 	gasPrice, err := s.Client.SuggestGasPrice(ctx)
 	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取 Gas 价格失败: %v", err))
-		return
+		return abort(fmt.Errorf("获取 Gas 价格失败: %v", err))
 	}
 
 	// 打包 claimDailyReward 调用
 	data, err := s.Contract.ABI.Pack("claimDailyReward")
 	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("打包调用失败: %v", err))
-		return
+		return abort(fmt.Errorf("打包调用失败: %v", err))
 	}
 
-	// 估算 Gas
+	// 估算 Gas（不指定 Gas 价格，余额为 0 时也能估算）
 	msg := ethereum.CallMsg{
-		From: fromAddress,
+		From: from,
 		To:   &contract,
 		Data: data,
 	}
 	gasLimit, err := s.Client.EstimateGas(ctx, msg)
 	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("估算 Gas 失败: %v", err))
-		return
+		return abort(fmt.Errorf("估算 Gas 失败: %v", err))
 	}
 
-	// 创建交易
-	tx := types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, data)
-
-	// 签名交易
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
-	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("签名交易失败: %v", err))
-		return
+	// 分配 nonce、签名并放入发送队列
+	// 需要补贴时领取交易会在补贴到账前因余额不足重试，放宽重试次数以覆盖补贴确认时间
+	maxAttempts := 0
+	if grant != nil {
+		maxAttempts = claimFundedMaxAttempts
 	}
-
-	// 发送交易
-	err = s.Client.SendTransaction(ctx, signedTx)
+	signedTx, job, err := s.enqueueSignedWith(ctx, sign, from, userID, "claimDailyReward", maxAttempts, func(nonce uint64) *types.Transaction {
+		return types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, data)
+	})
 	if err != nil {
-		releaseLock()
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("发送交易失败: %v", err))
-		return
+		return abort(err)
+	}
+	// 领取锁关联交易，后台对账在交易失败或被丢弃时释放
+	if claimDay != 0 {
+		if err := s.AuthService.LinkClaimLock(userID, claimDay, account, from.Hex(), signedTx.Hash().Hex(), job.ID); err != nil {
+			log.Printf("关联领取锁与交易 %s 失败: %v", signedTx.Hash().Hex(), err)
+		}
 	}
+	claim := &claimSubmission{Tx: signedTx, Job: job}
 
-	respondSuccess(w, ClaimResponse{
-		TxHash: signedTx.Hash().Hex(),
-		Status: "pending",
-	})
+	// 需要补贴：创建异步操作，由后台跟踪补贴与领取交易
+	if grant != nil {
+		op, err := s.Operations.CreateClaim(userID, from, account, claimDay, grant.ID, fundJob.ID, signedTx, job.ID)
+		if err != nil {
+			if _, cancelErr := s.Jobs.Cancel(job.ID); cancelErr != nil {
+				log.Printf("取消领取任务 %d 失败: %v", job.ID, cancelErr)
+			}
+			return abort(fmt.Errorf("创建领取操作失败"))
+		}
+		claim.Operation = op
+	}
+	return claim, nil
 }
 
 // 转账代币
@@ -600,9 +764,17 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "无效的请求体")
 		return
 	}
+	// 已启用提现白名单的用户只能转账到已绑定且已过冷静期的地址
+	if common.IsHexAddress(req.To) && !s.checkWithdrawAddress(w, userID, common.HexToAddress(req.To), true) {
+		return
+	}
+	s.transfer(w, r, userID, req)
+}
 
-	if req.To == "" || req.Amount == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "接收地址、金额和密码不能为空")
+// transfer 校验并发送代币转账（handleTransfer 与 handleWithdraw 共用）
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request, userID int64, req TransferRequest) {
+	if req.To == "" || req.Amount == "" {
+		respondError(w, http.StatusBadRequest, "接收地址和金额不能为空")
 		return
 	}
 
@@ -613,6 +785,35 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 	}
 	toAddress := common.HexToAddress(req.To)
 
+	// 解析金额
+	amount, ok := new(big.Int).SetString(req.Amount, 10)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的金额格式")
+		return
+	}
+
+	// 通行密钥确认（用户开启后为必需），确认成功时无需再提供两步验证码
+	passkeyConfirmed, ok := s.checkTransferPasskey(w, userID, req, toAddress, amount)
+	if !ok {
+		return
+	}
+
+	// 金额超过用户设置的阈值时需要两步验证码
+	needsTOTP, err := s.AuthService.TransferNeedsTOTP(userID, amount)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return
+	}
+	if needsTOTP && !passkeyConfirmed && !s.checkTOTP(w, r, userID, req.TOTPCode) {
+		return
+	}
+
+	// 自托管账户：返回未签名交易
+	if nonCustodial(r) {
+		s.respondUnsignedCall(w, r, userID, "transfer", toAddress, amount)
+		return
+	}
+
 	// 获取用户并解密私钥
 	user, err := s.AuthService.GetByID(userID)
 	if err != nil {
@@ -620,19 +821,20 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	privBytes, err := s.AuthService.DecryptPrivateKey(user, req.Password)
+	privBytes, address, err := s.signingKey(r, user, req.Password, req.Account)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "密码错误或解密失败")
+		respondSigningKeyError(w, err)
 		return
 	}
 
 	privateKey, err := crypto.ToECDSA(privBytes)
+	zeroBytes(privBytes)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "解析私钥失败")
 		return
 	}
 
-	fromAddress := common.HexToAddress(user.Address)
+	fromAddress := common.HexToAddress(address)
 
 	// 检查是否转账给自己
 	if fromAddress == toAddress {
@@ -640,20 +842,21 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 解析金额
-	amount, ok := new(big.Int).SetString(req.Amount, 10)
-	if !ok {
-		respondError(w, http.StatusBadRequest, "无效的金额格式")
-		return
-	}
-
 	contract := s.ContractAddress
 	ctx := context.Background()
 
+	// 需要 Gas 补贴时要求工作量证明（余额充足的转账不需要）
+	if !s.powSolved(r) {
+		if needs, err := s.needsTopUp(ctx, fromAddress); err == nil && needs {
+			respondPoWError(w, pow.ActionFaucet, pow.ErrRequired)
+			return
+		}
+	}
+
 	// 前置钩子：检查并自动转账 ETH（如果余额不足）
 	// 等待确认以确保ETH到账后再继续操作
-	if err := s.checkAndFundETH(ctx, fromAddress, true); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("自动转账 ETH 失败: %v", err))
+	if err := s.checkAndFundETH(ctx, userID, fromAddress, true); err != nil {
+		respondFundError(w, err)
 		return
 	}
 
@@ -668,20 +871,6 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取 nonce
-	nonce, err := s.Client.PendingNonceAt(ctx, fromAddress)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取 nonce 失败: %v", err))
-		return
-	}
-
-	// 获取链 ID
-	chainID, err := s.Client.NetworkID(ctx)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取链 ID 失败: %v", err))
-		return
-	}
-
 	// 获取 Gas 价格
 	gasPrice, err := s.Client.SuggestGasPrice(ctx)
 	if err != nil {
@@ -708,27 +897,17 @@ func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 创建交易
-	tx := types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, data)
-
-	// 签名交易
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("签名交易失败: %v", err))
-		return
-	}
-
-	// 发送交易
-	err = s.Client.SendTransaction(ctx, signedTx)
+	// 分配 nonce、签名并放入发送队列
+	signedTx, job, err := s.enqueueSigned(ctx, privateKey, fromAddress, userID, "transfer", 0, func(nonce uint64) *types.Transaction {
+		return types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, data)
+	})
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("发送交易失败: %v", err))
+		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	respondSuccess(w, ClaimResponse{
-		TxHash: signedTx.Hash().Hex(),
-		Status: "pending",
-	})
+	// 等待交易广播
+	s.respondQueuedTx(ctx, w, job, signedTx)
 }
 
 // 辅助函数：调用返回 string 的函数
@@ -830,30 +1009,42 @@ func (s *Server) callUint256WithParam(ctx context.Context, contract common.Addre
 	return value, nil
 }
 
+// authenticate 解析 Authorization 头并校验令牌及其会话是否仍然有效
+func (s *Server) authenticate(r *http.Request) (context.Context, string) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, "缺少认证令牌"
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, "无效的认证格式"
+	}
+
+	claims, err := auth.ValidateToken(parts[1])
+	if err != nil {
+		return nil, "无效或过期的令牌"
+	}
+	if claims.SessionID == "" || !s.AuthService.IsSessionActive(claims.UserID, claims.SessionID) {
+		return nil, "会话已注销，请重新登录"
+	}
+
+	// 将用户信息存储到 context
+	ctx := context.WithValue(r.Context(), contextKeyUserID, claims.UserID)
+	ctx = context.WithValue(ctx, contextKeyUserEmail, claims.Email)
+	ctx = context.WithValue(ctx, contextKeySessionID, claims.SessionID)
+	ctx = context.WithValue(ctx, contextKeyNonCustodial, claims.NonCustodial)
+	return ctx, ""
+}
+
 // authMiddleware JWT 认证中间件
 func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondError(w, http.StatusUnauthorized, "缺少认证令牌")
+		ctx, msg := s.authenticate(r)
+		if ctx == nil {
+			respondError(w, http.StatusUnauthorized, msg)
 			return
 		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			respondError(w, http.StatusUnauthorized, "无效的认证格式")
-			return
-		}
-
-		claims, err := auth.ValidateToken(parts[1])
-		if err != nil {
-			respondError(w, http.StatusUnauthorized, "无效或过期的令牌")
-			return
-		}
-
-		// 将用户信息存储到 context
-		ctx := context.WithValue(r.Context(), contextKeyUserID, claims.UserID)
-		ctx = context.WithValue(ctx, contextKeyUserEmail, claims.Email)
 		next(w, r.WithContext(ctx))
 	}
 }
@@ -861,25 +1052,39 @@ func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 // optionalAuthMiddleware 可选的 JWT 认证中间件（如果提供了 token 则验证，否则继续）
 func (s *Server) optionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				claims, err := auth.ValidateToken(parts[1])
-				if err == nil {
-					// 将用户信息存储到 context
-					ctx := context.WithValue(r.Context(), contextKeyUserID, claims.UserID)
-					ctx = context.WithValue(ctx, contextKeyUserEmail, claims.Email)
-					next(w, r.WithContext(ctx))
-					return
-				}
-			}
+		if ctx, _ := s.authenticate(r); ctx != nil {
+			next(w, r.WithContext(ctx))
+			return
 		}
 		// 如果没有有效的 token，继续执行（不设置 user_id）
 		next(w, r)
 	}
 }
 
+// adminMiddleware 管理员认证中间件（账户邮箱需在 ADMIN_EMAILS 中且已验证）
+// 以数据库中的邮箱为准，未验证的邮箱可能只是他人抢先注册，不授予管理员权限
+func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(contextKeyUserID).(int64)
+		user, err := s.AuthService.GetByID(userID)
+		if err != nil {
+			respondError(w, http.StatusForbidden, "需要管理员权限")
+			return
+		}
+		if user.NonCustodial || user.EmailVerifiedAt == nil {
+			respondError(w, http.StatusForbidden, "需要管理员权限（管理员邮箱必须先完成验证）")
+			return
+		}
+		for _, admin := range config.GetAdminEmails() {
+			if strings.EqualFold(admin, user.Email) {
+				next(w, r)
+				return
+			}
+		}
+		respondError(w, http.StatusForbidden, "需要管理员权限")
+	})
+}
+
 // 注册用户
 func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
@@ -892,6 +1097,17 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "邮箱和密码不能为空")
 		return
 	}
+	if err := auth.ValidateEmail(req.Email); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.allow(w, "register:ip:"+clientIP(r), registerIPRule) {
+		return
+	}
+	if len(req.EscrowTrustees) > 0 && (req.EscrowThreshold < 2 || req.EscrowThreshold > len(req.EscrowTrustees)) {
+		respondError(w, http.StatusBadRequest, "托管门限必须在 2 到受托人数量之间")
+		return
+	}
 
 	user, err := s.AuthService.Register(req.Email, req.Password)
 	if err != nil {
@@ -903,17 +1119,33 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email)
+	s.recordRegistration(r, user)
+
+	tokens, err := s.createSession(r, user)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "生成令牌失败")
 		return
 	}
+	go s.sendVerificationEmail(user)
+
+	// 注册时可选开启私钥托管；失败不影响注册结果，用户可稍后通过 /api/escrow/enroll 开启
+	escrowEnabled := false
+	if len(req.EscrowTrustees) > 0 {
+		if err := s.enrollEscrow(user, req.Password, req.EscrowTrustees, req.EscrowThreshold); err != nil {
+			log.Printf("用户 %d 注册时开启私钥托管失败: %v", user.ID, err)
+		} else {
+			escrowEnabled = true
+		}
+	}
 
 	respondSuccess(w, RegisterResponse{
-		UserID:  user.ID,
-		Email:   user.Email,
-		Address: user.Address,
-		Token:   token,
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresIn:     tokens.ExpiresIn,
+		EscrowEnabled: escrowEnabled,
 	})
 }
 
@@ -930,26 +1162,58 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 限流与失败锁定在 Argon2 之前检查
+	if !s.allow(w, loginIPKey(clientIP(r)), loginIPRule) || !s.allow(w, loginEmailKey(req.Email), loginEmailRule) {
+		return
+	}
+	if !s.checkLoginLockout(w, r, req.Email) {
+		return
+	}
+
 	user, err := s.AuthService.Authenticate(req.Email, req.Password)
 	if err != nil {
+		s.loginFailed(r, req.Email)
 		respondError(w, http.StatusUnauthorized, "邮箱或密码错误")
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email)
+	// 两步验证：密码正确后再要求验证码，验证码错误同样计入失败次数
+	totp, err := s.AuthService.GetTOTPStatus(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return
+	}
+	if totp.Enabled && totp.RequireForLogin && !s.checkTOTP(w, r, user.ID, req.TOTPCode) {
+		if req.TOTPCode != "" {
+			s.loginFailed(r, req.Email)
+		}
+		return
+	}
+	s.loginSucceeded(req.Email)
+
+	tokens, err := s.createSession(r, user)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "生成令牌失败")
 		return
 	}
 
 	respondSuccess(w, LoginResponse{
-		UserID:  user.ID,
-		Email:   user.Email,
-		Address: user.Address,
-		Token:   token,
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresIn:     tokens.ExpiresIn,
+		EmailVerified: user.EmailVerified(),
 	})
 }
 
+// JWKS 公钥集
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, http.StatusOK, map[string]interface{}{"keys": auth.JWKS()})
+}
+
 // 获取当前用户信息
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(contextKeyUserID).(int64)
@@ -960,8 +1224,45 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondSuccess(w, UserInfo{
-		UserID:  user.ID,
-		Email:   user.Email,
-		Address: user.Address,
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		NonCustodial:  user.NonCustodial,
+		EmailVerified: user.EmailVerified(),
 	})
 }
+
+// 修改密码（开启两步验证时需要验证码），成功后注销其他会话并锁定钱包
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.OldPassword == "" || req.NewPassword == "" {
+		respondError(w, http.StatusBadRequest, "原密码和新密码不能为空")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.OldPassword) || !s.requireTOTP(w, r, userID, req.TOTPCode) {
+		return
+	}
+	if err := s.AuthService.ChangePassword(user, req.OldPassword, req.NewPassword); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("修改密码失败: %v", err))
+		return
+	}
+
+	s.Unlocked.LockUser(userID)
+	if _, err := s.AuthService.RevokeOtherSessions(userID, sessionID); err != nil {
+		log.Printf("用户 %d 修改密码后注销会话失败: %v", userID, err)
+	}
+	respondSuccess(w, map[string]interface{}{"changed": true})
+}
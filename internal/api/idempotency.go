@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"lbtc/internal/idempotency"
+)
+
+// maxIdempotentBody 带 Idempotency-Key 的请求体上限，超出返回 413
+const maxIdempotentBody = 1 << 20
+
+// idempotencySecretFields 计算请求指纹时忽略的字段：密码不落库，验证码与通行密钥断言每次重试都可能不同
+var idempotencySecretFields = []string{"password", "totpCode", "passkey"}
+
+// requestFingerprint 计算请求方法、路径与请求体的 SHA-256；JSON 请求体按键排序后计算，忽略 idempotencySecretFields
+func requestFingerprint(r *http.Request, body []byte) string {
+	canonical := body
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err == nil {
+		for _, name := range idempotencySecretFields {
+			delete(fields, name)
+		}
+		if data, err := json.Marshal(fields); err == nil {
+			canonical = data
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(r.Method + "\n" + r.URL.Path + "\n"))
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder 在写出响应的同时保存状态码与响应体
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent 支持 Idempotency-Key 请求头：有效期内相同 key 的重复请求重放首次响应（带 Idempotent-Replayed: true），
+// 不会再次签名和发送交易；同一个 key 用于不同请求返回 422，首次请求仍在处理返回 409
+// 只保存成功（2xx）的响应，失败的请求没有发出交易，可以用同一个 key 重试
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			respondError(w, http.StatusBadRequest, "Idempotency-Key 不能超过 255 个字符")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+				return
+			}
+			respondError(w, http.StatusBadRequest, "读取请求体失败")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := r.Context().Value(contextKeyUserID).(int64)
+		record, err := s.Idempotency.Begin(userID, key, requestFingerprint(r, body))
+		if err != nil {
+			switch {
+			case errors.Is(err, idempotency.ErrMismatch):
+				respondError(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, idempotency.ErrInProgress):
+				respondError(w, http.StatusConflict, err.Error())
+			default:
+				respondError(w, http.StatusInternalServerError, "检查 Idempotency-Key 失败")
+			}
+			return
+		}
+		if record.Status == idempotency.StatusCompleted {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.Response)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		if rec.status >= 200 && rec.status < 300 {
+			err = s.Idempotency.Complete(record.ID, rec.status, rec.body.Bytes())
+		} else {
+			err = s.Idempotency.Release(record.ID)
+		}
+		if err != nil {
+			log.Printf("保存 Idempotency-Key %q 的结果失败: %v", key, err)
+		}
+	}
+}
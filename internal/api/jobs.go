@@ -0,0 +1,669 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/bonus"
+	"lbtc/internal/faucet"
+	"lbtc/internal/jobqueue"
+)
+
+// 链上交易任务类型
+const (
+	jobKindTxSend      = "tx.send"        // 广播预签名交易
+	jobKindFaucetTopUp = "faucet.topup"   // 发送 Gas 补贴（执行时由拥有者私钥签名）
+	jobKindBonus       = "bonus.transfer" // 发送连续领取奖金（执行时由拥有者私钥签名 QXB 转账）
+)
+
+// txSendWait 请求中等待交易任务广播的时间，超过后返回 202 与任务 ID
+const txSendWait = 10 * time.Second
+
+// claimFundedMaxAttempts 需要先补贴 Gas 的领取交易的最多发送次数（退避累计约 30 分钟，覆盖补贴确认时间）
+const claimFundedMaxAttempts = 10
+
+type txSendPayload struct {
+	RawTx  string `json:"rawTx"`
+	UserID int64  `json:"userId,omitempty"` // 非 0 时广播后记录到交易跟踪
+	Method string `json:"method,omitempty"`
+}
+
+type faucetTopUpPayload struct {
+	GrantID int64  `json:"grantId"`
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+}
+
+// signedTxState 补贴与奖金任务的检查点：重试时重发同一笔已签名交易，避免重复转账
+type signedTxState struct {
+	RawTx string `json:"rawTx"`
+}
+
+type bonusPayload struct {
+	PayoutID int64  `json:"payoutId"`
+	Address  string `json:"address"`
+	Amount   string `json:"amount"`
+}
+
+// registerJobs 注册链上交易任务的处理函数
+func (s *Server) registerJobs() {
+	s.Jobs.Register(jobKindTxSend, s.runTxSend)
+	s.Jobs.Register(jobKindFaucetTopUp, s.runFaucetTopUp)
+	s.Jobs.OnFailed(jobKindFaucetTopUp, func(job *jobqueue.JobModel) {
+		var p faucetTopUpPayload
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			log.Printf("解析补贴任务 %d 失败: %v", job.ID, err)
+			return
+		}
+		cause := errors.New("任务已取消")
+		if job.Status == jobqueue.StatusDead {
+			cause = errors.New(job.LastError)
+		}
+		s.releaseGrant(p.GrantID, cause)
+	})
+	s.Jobs.Register(jobKindBonus, s.runBonus)
+	s.Jobs.OnFailed(jobKindBonus, func(job *jobqueue.JobModel) {
+		var p bonusPayload
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			log.Printf("解析奖金任务 %d 失败: %v", job.ID, err)
+			return
+		}
+		cause := errors.New("任务已取消")
+		if job.Status == jobqueue.StatusDead {
+			cause = errors.New(job.LastError)
+		}
+		if err := s.Bonus.MarkFailed(p.PayoutID, cause); err != nil {
+			log.Printf("记录奖金 %d 失败状态出错: %v", p.PayoutID, err)
+		}
+	})
+}
+
+func encodeRawTx(tx *types.Transaction) (string, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(raw), nil
+}
+
+func decodeRawTx(raw string) (*types.Transaction, error) {
+	data, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// broadcast 广播已签名交易：节点已有该交易或交易已上链视为成功；nonce 被占用等无法通过重试恢复的错误标记为不可重试
+func (s *Server) broadcast(ctx context.Context, tx *types.Transaction) error {
+	err := s.Client.SendTransaction(ctx, tx)
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction"):
+		return nil
+	case strings.Contains(msg, "nonce too low"):
+		if _, receiptErr := s.Client.TransactionReceipt(ctx, tx.Hash()); receiptErr == nil {
+			return nil
+		} else if !errors.Is(receiptErr, ethereum.NotFound) {
+			return err
+		}
+		return jobqueue.Permanent(err)
+	case strings.Contains(msg, "replacement transaction underpriced"),
+		strings.Contains(msg, "invalid sender"),
+		strings.Contains(msg, "intrinsic gas too low"):
+		return jobqueue.Permanent(err)
+	}
+	return err
+}
+
+// runTxSend 执行 tx.send 任务
+func (s *Server) runTxSend(ctx context.Context, job *jobqueue.JobModel) (interface{}, error) {
+	var p txSendPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("解析任务参数失败: %v", err))
+	}
+	tx, err := decodeRawTx(p.RawTx)
+	if err != nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("解析交易失败: %v", err))
+	}
+	if err := s.broadcast(ctx, tx); err != nil {
+		return nil, err
+	}
+	if p.UserID != 0 {
+		if _, err := s.TxTracker.Track(p.UserID, tx, common.HexToAddress(job.Sender), p.Method); err != nil {
+			// 交易已发出，跟踪失败（如重试时已记录）不影响结果
+			log.Printf("记录交易 %s 失败: %v", tx.Hash().Hex(), err)
+		}
+	}
+	return jobqueue.TxResult{TxHash: tx.Hash().Hex()}, nil
+}
+
+// runFaucetTopUp 执行 faucet.topup 任务：首次执行时签名并保存检查点，之后的重试重发同一笔交易
+func (s *Server) runFaucetTopUp(ctx context.Context, job *jobqueue.JobModel) (interface{}, error) {
+	var p faucetTopUpPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("解析任务参数失败: %v", err))
+	}
+	if s.OwnerPrivateKey == nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("未配置拥有者私钥，无法自动转账 ETH"))
+	}
+
+	var state signedTxState
+	if job.State != "" {
+		if err := json.Unmarshal([]byte(job.State), &state); err != nil {
+			return nil, jobqueue.Permanent(fmt.Errorf("解析任务检查点失败: %v", err))
+		}
+	}
+	var signedTx *types.Transaction
+	if state.RawTx != "" {
+		tx, err := decodeRawTx(state.RawTx)
+		if err != nil {
+			return nil, jobqueue.Permanent(fmt.Errorf("解析交易失败: %v", err))
+		}
+		signedTx = tx
+	} else {
+		amount, ok := new(big.Int).SetString(p.Amount, 10)
+		if !ok {
+			return nil, jobqueue.Permanent(fmt.Errorf("无效的补贴金额: %s", p.Amount))
+		}
+		tx, err := s.signTopUp(ctx, common.HexToAddress(p.Address), amount)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := encodeRawTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Jobs.Checkpoint(job, signedTxState{RawTx: raw}); err != nil {
+			return nil, fmt.Errorf("保存任务检查点失败: %v", err)
+		}
+		signedTx = tx
+	}
+
+	if err := s.broadcast(ctx, signedTx); err != nil {
+		return nil, fmt.Errorf("发送 ETH 转账失败: %w", err)
+	}
+	txHash := signedTx.Hash().Hex()
+	if err := s.Faucet.MarkSent(p.GrantID, txHash); err != nil {
+		log.Printf("记录补贴 %d 交易哈希失败: %v", p.GrantID, err)
+	}
+	log.Printf("✅ 自动转账 ETH 已发送: %s -> %s, 金额: %s wei, txHash: %s",
+		job.Sender, p.Address, p.Amount, txHash)
+	return jobqueue.TxResult{TxHash: txHash}, nil
+}
+
+// signTopUp 用拥有者私钥签名 ETH 转账；拥有者地址的任务串行执行，直接使用节点的 pending nonce
+func (s *Server) signTopUp(ctx context.Context, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	ownerAddress := crypto.PubkeyToAddress(s.OwnerPrivateKey.PublicKey)
+
+	// 获取 nonce
+	nonce, err := s.Client.PendingNonceAt(ctx, ownerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("获取 nonce 失败: %v", err)
+	}
+
+	// 获取链 ID
+	chainID, err := s.Client.NetworkID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取链 ID 失败: %v", err)
+	}
+
+	// 获取 Gas 价格
+	gasPrice, err := s.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Gas 价格失败: %v", err)
+	}
+
+	// 创建 ETH 转账交易（普通转账，不是合约调用）
+	tx := types.NewTransaction(nonce, to, amount, 21000, gasPrice, nil)
+
+	// 签名交易
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), s.OwnerPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("签名交易失败: %v", err)
+	}
+	return signedTx, nil
+}
+
+// runBonus 执行 bonus.transfer 任务：首次执行时签名 QXB 转账并保存检查点，之后的重试重发同一笔交易
+func (s *Server) runBonus(ctx context.Context, job *jobqueue.JobModel) (interface{}, error) {
+	var p bonusPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("解析任务参数失败: %v", err))
+	}
+	if s.OwnerPrivateKey == nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("未配置拥有者私钥，无法发放奖金"))
+	}
+
+	var state signedTxState
+	if job.State != "" {
+		if err := json.Unmarshal([]byte(job.State), &state); err != nil {
+			return nil, jobqueue.Permanent(fmt.Errorf("解析任务检查点失败: %v", err))
+		}
+	}
+	var signedTx *types.Transaction
+	if state.RawTx != "" {
+		tx, err := decodeRawTx(state.RawTx)
+		if err != nil {
+			return nil, jobqueue.Permanent(fmt.Errorf("解析交易失败: %v", err))
+		}
+		signedTx = tx
+	} else {
+		amount, ok := new(big.Int).SetString(p.Amount, 10)
+		if !ok {
+			return nil, jobqueue.Permanent(fmt.Errorf("无效的奖金金额: %s", p.Amount))
+		}
+		tx, err := s.signBonus(ctx, common.HexToAddress(p.Address), amount)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := encodeRawTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Jobs.Checkpoint(job, signedTxState{RawTx: raw}); err != nil {
+			return nil, fmt.Errorf("保存任务检查点失败: %v", err)
+		}
+		signedTx = tx
+	}
+
+	if err := s.broadcast(ctx, signedTx); err != nil {
+		return nil, fmt.Errorf("发送奖金失败: %w", err)
+	}
+	txHash := signedTx.Hash().Hex()
+	if err := s.Bonus.MarkSent(p.PayoutID, txHash); err != nil {
+		log.Printf("记录奖金 %d 交易哈希失败: %v", p.PayoutID, err)
+	}
+	return jobqueue.TxResult{TxHash: txHash}, nil
+}
+
+// signBonus 用拥有者私钥签名 QXB 转账；拥有者地址的任务串行执行，直接使用节点的 pending nonce
+func (s *Server) signBonus(ctx context.Context, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	ownerAddress := crypto.PubkeyToAddress(s.OwnerPrivateKey.PublicKey)
+	contract := s.ContractAddress
+
+	data, err := s.Contract.ABI.Pack("transfer", to, amount)
+	if err != nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("打包调用失败: %v", err))
+	}
+	nonce, err := s.Client.PendingNonceAt(ctx, ownerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("获取 nonce 失败: %v", err)
+	}
+	chainID, err := s.Client.NetworkID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取链 ID 失败: %v", err)
+	}
+	gasPrice, err := s.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Gas 价格失败: %v", err)
+	}
+	// 拥有者 QXB 余额不足时估算失败，按退避重试，补足余额后可由管理员重试死信任务
+	gasLimit, err := s.Client.EstimateGas(ctx, ethereum.CallMsg{From: ownerAddress, To: &contract, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("估算 Gas 失败: %v", err)
+	}
+
+	tx := types.NewTransaction(nonce, contract, big.NewInt(0), gasLimit, gasPrice, data)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), s.OwnerPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("签名交易失败: %v", err)
+	}
+	return signedTx, nil
+}
+
+// enqueueBonus 将连续领取奖金放入任务队列（bonus.PayFunc）
+func (s *Server) enqueueBonus(payout *bonus.PayoutModel) (int64, error) {
+	if s.OwnerPrivateKey == nil {
+		return 0, fmt.Errorf("未配置拥有者私钥，无法发放奖金")
+	}
+	job, _, err := s.Jobs.Enqueue(jobqueue.Spec{
+		Kind:           jobKindBonus,
+		Sender:         crypto.PubkeyToAddress(s.OwnerPrivateKey.PublicKey).Hex(),
+		IdempotencyKey: fmt.Sprintf("bonus:payout:%d", payout.ID),
+		Payload: bonusPayload{
+			PayoutID: payout.ID,
+			Address:  payout.Address,
+			Amount:   payout.Amount,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("创建奖金任务失败: %v", err)
+	}
+	return job.ID, nil
+}
+
+// requeueBonus 重试发送失败的奖金：重新入队，同一笔奖金已有死信或已取消的任务时重新执行该任务
+func (s *Server) requeueBonus(payout *bonus.PayoutModel) (int64, error) {
+	jobID, err := s.enqueueBonus(payout)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Jobs.Retry(jobID); err != nil && !errors.Is(err, jobqueue.ErrNotRetryable) {
+		return 0, fmt.Errorf("重试奖金任务失败: %v", err)
+	}
+	return jobID, nil
+}
+
+// enqueueTopUp 将已预留额度的补贴放入任务队列，入队失败时释放额度
+func (s *Server) enqueueTopUp(grant *faucet.GrantModel) (*jobqueue.JobModel, error) {
+	job, _, err := s.Jobs.Enqueue(jobqueue.Spec{
+		Kind:           jobKindFaucetTopUp,
+		Sender:         crypto.PubkeyToAddress(s.OwnerPrivateKey.PublicKey).Hex(),
+		IdempotencyKey: fmt.Sprintf("faucet:grant:%d", grant.ID),
+		Payload: faucetTopUpPayload{
+			GrantID: grant.ID,
+			Address: grant.Address,
+			Amount:  grant.Amount,
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("创建补贴任务失败: %v", err)
+		s.releaseGrant(grant.ID, err)
+		return nil, err
+	}
+	return job, nil
+}
+
+// enqueueTx 将已签名交易放入发送队列；同一交易重复入队时返回已有任务
+func (s *Server) enqueueTx(tx *types.Transaction, from common.Address, userID int64, method string, maxAttempts int) (*jobqueue.JobModel, error) {
+	raw, err := encodeRawTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	nonce := tx.Nonce()
+	job, _, err := s.Jobs.Enqueue(jobqueue.Spec{
+		Kind:           jobKindTxSend,
+		Sender:         from.Hex(),
+		Nonce:          &nonce,
+		IdempotencyKey: "tx:" + tx.Hash().Hex(),
+		Payload:        txSendPayload{RawTx: raw, UserID: userID, Method: method},
+		MaxAttempts:    maxAttempts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建发送任务失败: %v", err)
+	}
+	return job, nil
+}
+
+// txSignFunc 对交易签名；自动领取的委托私钥只通过这种方式使用，不会离开 autoclaim 包
+type txSignFunc func(tx *types.Transaction, signer types.Signer) (*types.Transaction, error)
+
+// keySigner 使用私钥签名
+func keySigner(key *ecdsa.PrivateKey) txSignFunc {
+	return func(tx *types.Transaction, signer types.Signer) (*types.Transaction, error) {
+		return types.SignTx(tx, signer, key)
+	}
+}
+
+// enqueueSigned 在发送地址锁内分配 nonce、签名并放入发送队列
+func (s *Server) enqueueSigned(ctx context.Context, key *ecdsa.PrivateKey, from common.Address, userID int64, method string, maxAttempts int, build func(nonce uint64) *types.Transaction) (*types.Transaction, *jobqueue.JobModel, error) {
+	return s.enqueueSignedWith(ctx, keySigner(key), from, userID, method, maxAttempts, build)
+}
+
+// enqueueSignedWith 与 enqueueSigned 相同，由 sign 完成签名
+// nonce 取节点 pending nonce 与队列中尚未广播交易之后的较大值，保证同一地址的交易连续且按顺序广播
+func (s *Server) enqueueSignedWith(ctx context.Context, sign txSignFunc, from common.Address, userID int64, method string, maxAttempts int, build func(nonce uint64) *types.Transaction) (*types.Transaction, *jobqueue.JobModel, error) {
+	unlock := s.Jobs.LockSender(from.Hex())
+	defer unlock()
+
+	pending, err := s.Client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取 nonce 失败: %v", err)
+	}
+	nonce, err := s.Jobs.NextNonce(from.Hex(), pending)
+	if err != nil {
+		return nil, nil, fmt.Errorf("分配 nonce 失败: %v", err)
+	}
+	chainID, err := s.Client.NetworkID(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取链 ID 失败: %v", err)
+	}
+	signedTx, err := sign(build(nonce), types.NewEIP155Signer(chainID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("签名交易失败: %v", err)
+	}
+	job, err := s.enqueueTx(signedTx, from, userID, method, maxAttempts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signedTx, job, nil
+}
+
+// waitTxJob 在请求中短暂等待发送任务结束
+func (s *Server) waitTxJob(ctx context.Context, id int64) (*jobqueue.JobModel, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, txSendWait)
+	defer cancel()
+	return s.Jobs.Wait(waitCtx, id)
+}
+
+// respondQueuedTx 等待发送任务：已广播返回 200；进入死信返回 500；仍在排队或重试中返回 202 与任务 ID
+// 返回 false 表示交易确定不会发出
+func (s *Server) respondQueuedTx(ctx context.Context, w http.ResponseWriter, job *jobqueue.JobModel, tx *types.Transaction) bool {
+	job, err := s.waitTxJob(ctx, job.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询发送任务失败")
+		return true
+	}
+	switch job.Status {
+	case jobqueue.StatusSucceeded:
+		respondSuccess(w, ClaimResponse{
+			TxHash: tx.Hash().Hex(),
+			Status: "pending",
+		})
+		return true
+	case jobqueue.StatusDead, jobqueue.StatusCanceled:
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("发送交易失败: %s", job.LastError))
+		return false
+	}
+	respondJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Data: ClaimResponse{
+			TxHash: tx.Hash().Hex(),
+			Status: "queued",
+			JobID:  job.ID,
+		},
+	})
+	return true
+}
+
+// JobInfo 链上交易任务
+type JobInfo struct {
+	ID             int64           `json:"id"`
+	Kind           string          `json:"kind"`
+	Sender         string          `json:"sender,omitempty"`
+	Nonce          *uint64         `json:"nonce,omitempty"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	Status         string          `json:"status"` // queued / running / succeeded / dead / canceled
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"maxAttempts"`
+	RunAt          time.Time       `json:"runAt"`
+	LeaseExpiresAt *time.Time      `json:"leaseExpiresAt,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+	FinishedAt     *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// JobListResponse 任务列表与各状态数量
+type JobListResponse struct {
+	Counts map[string]int64 `json:"counts"`
+	Jobs   []JobInfo        `json:"jobs"`
+}
+
+func jobInfo(job *jobqueue.JobModel) JobInfo {
+	info := JobInfo{
+		ID:             job.ID,
+		Kind:           job.Kind,
+		Sender:         job.Sender,
+		Nonce:          job.Nonce,
+		Status:         job.Status,
+		Attempts:       job.Attempts,
+		MaxAttempts:    job.MaxAttempts,
+		RunAt:          job.RunAt,
+		LeaseExpiresAt: job.LeaseExpiresAt,
+		LastError:      job.LastError,
+		Payload:        json.RawMessage(job.Payload),
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+		FinishedAt:     job.FinishedAt,
+	}
+	if job.IdempotencyKey != nil {
+		info.IdempotencyKey = *job.IdempotencyKey
+	}
+	if job.Result != "" {
+		info.Result = json.RawMessage(job.Result)
+	}
+	return info
+}
+
+var jobStatuses = map[string]bool{
+	jobqueue.StatusQueued:    true,
+	jobqueue.StatusRunning:   true,
+	jobqueue.StatusSucceeded: true,
+	jobqueue.StatusDead:      true,
+	jobqueue.StatusCanceled:  true,
+}
+
+// 列出链上交易任务（管理员），可按 status / kind / sender 过滤
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && !jobStatuses[status] {
+		respondError(w, http.StatusBadRequest, "无效的任务状态")
+		return
+	}
+	sender := query.Get("sender")
+	if sender != "" {
+		if !common.IsHexAddress(sender) {
+			respondError(w, http.StatusBadRequest, "无效的地址")
+			return
+		}
+		sender = common.HexToAddress(sender).Hex()
+	}
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 1000 之间")
+			return
+		}
+		limit = n
+	}
+
+	counts, err := s.Jobs.Counts()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询任务失败")
+		return
+	}
+	jobs, err := s.Jobs.List(status, query.Get("kind"), sender, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询任务失败")
+		return
+	}
+	infos := make([]JobInfo, 0, len(jobs))
+	for i := range jobs {
+		infos = append(infos, jobInfo(&jobs[i]))
+	}
+	respondSuccess(w, JobListResponse{Counts: counts, Jobs: infos})
+}
+
+func jobID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id, err == nil && id > 0
+}
+
+func respondJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jobqueue.ErrNotRetryable), errors.Is(err, jobqueue.ErrNotCancelable):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "操作任务失败")
+	}
+}
+
+// 查询链上交易任务详情（管理员）
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := jobID(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的任务 ID")
+		return
+	}
+	job, err := s.Jobs.Get(id)
+	if err != nil {
+		respondJobError(w, err)
+		return
+	}
+	respondSuccess(w, jobInfo(job))
+}
+
+// 重新执行死信或已取消的任务（管理员）
+func (s *Server) handleRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := jobID(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的任务 ID")
+		return
+	}
+	job, err := s.Jobs.Retry(id)
+	if err != nil {
+		respondJobError(w, err)
+		return
+	}
+	s.recordJobAdmin(r, job, "重试")
+	respondSuccess(w, jobInfo(job))
+}
+
+// 取消等待执行的任务（管理员）
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := jobID(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的任务 ID")
+		return
+	}
+	job, err := s.Jobs.Cancel(id)
+	if err != nil {
+		respondJobError(w, err)
+		return
+	}
+	s.recordJobAdmin(r, job, "取消")
+	respondSuccess(w, jobInfo(job))
+}
+
+func (s *Server) recordJobAdmin(r *http.Request, job *jobqueue.JobModel, action string) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventJobAdmin,
+		UserID:  &userID,
+		Subject: fmt.Sprintf("job:%d", job.ID),
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("%s任务 %s", action, job.Kind),
+	})
+}
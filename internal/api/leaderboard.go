@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"lbtc/internal/claims"
+)
+
+// leaderboardWindows 排行榜可选的领取次数统计窗口（天，0 表示全部历史）
+var leaderboardWindows = map[string]int{"7d": 7, "30d": 30, "all": 0}
+
+// LeaderboardEntry 排行榜条目
+type LeaderboardEntry struct {
+	Rank    int    `json:"rank"` // 并列时名次相同
+	Address string `json:"address"`
+	Claims  int    `json:"claims"`            // 窗口内领取次数
+	Streak  int    `json:"streak"`            // 当前连续领取天数
+	Balance string `json:"balance,omitempty"` // QXB 最小单位，仅 by=balance 时返回
+}
+
+// Leaderboard 排行榜
+type Leaderboard struct {
+	By      string             `json:"by"`
+	Window  string             `json:"window"`
+	Total   int                `json:"total"` // 上榜地址总数
+	Entries []LeaderboardEntry `json:"entries"`
+	Self    *LeaderboardEntry  `json:"self,omitempty"` // 查询 address 的名次，未上榜时省略
+}
+
+// RewardDailyStats 每日领取统计
+type RewardDailyStats struct {
+	Day               int64  `json:"day"` // UTC 日
+	Claimers          int    `json:"claimers"`
+	NewClaimers       int    `json:"newClaimers"`
+	ReturningClaimers int    `json:"returningClaimers"`
+	Minted            string `json:"minted"`      // 当天通过领取铸造的 QXB 最小单位
+	TotalMinted       string `json:"totalMinted"` // 截至当天累计铸造
+}
+
+// RewardStats 领取统计
+type RewardStats struct {
+	TotalClaims   int                `json:"totalClaims"`
+	TotalClaimers int                `json:"totalClaimers"`
+	TotalMinted   string             `json:"totalMinted"`
+	Days          []RewardDailyStats `json:"days"`
+}
+
+func leaderboardEntry(e *claims.LeaderboardEntry, by string) LeaderboardEntry {
+	entry := LeaderboardEntry{Rank: e.Rank, Address: e.Address, Claims: e.Claims, Streak: e.Streak}
+	if by == claims.RankByBalance {
+		entry.Balance = e.Balance.String()
+	}
+	return entry
+}
+
+// 查询领取排行榜
+func (s *Server) handleRewardLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	by := query.Get("by")
+	switch by {
+	case "":
+		by = claims.RankByClaims
+	case claims.RankByClaims, claims.RankByStreak, claims.RankByBalance:
+	default:
+		respondError(w, http.StatusBadRequest, "by 必须是 claims、streak 或 balance")
+		return
+	}
+	window := query.Get("window")
+	if window == "" {
+		window = "30d"
+	}
+	windowDays, ok := leaderboardWindows[window]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "window 必须是 7d、30d 或 all")
+		return
+	}
+	limit := 50
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 500 之间")
+			return
+		}
+		limit = n
+	}
+	address := query.Get("address")
+	if address != "" && !common.IsHexAddress(address) {
+		respondError(w, http.StatusBadRequest, "无效的地址")
+		return
+	}
+
+	list, err := s.Claims.Leaderboard(by, windowDays)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询排行榜失败")
+		return
+	}
+	board := Leaderboard{By: by, Window: window, Total: len(list), Entries: make([]LeaderboardEntry, 0, limit)}
+	for i := range list {
+		if i < limit {
+			board.Entries = append(board.Entries, leaderboardEntry(&list[i], by))
+		}
+		if address != "" && strings.EqualFold(list[i].Address, address) {
+			self := leaderboardEntry(&list[i], by)
+			board.Self = &self
+		}
+	}
+	respondSuccess(w, board)
+}
+
+// 查询领取统计
+func (s *Server) handleRewardStats(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			respondError(w, http.StatusBadRequest, "days 必须在 1 到 365 之间")
+			return
+		}
+		days = n
+	}
+
+	stats, err := s.Claims.Stats(days)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询领取统计失败")
+		return
+	}
+	resp := RewardStats{
+		TotalClaims:   stats.TotalClaims,
+		TotalClaimers: stats.TotalClaimers,
+		TotalMinted:   stats.TotalMinted.String(),
+		Days:          make([]RewardDailyStats, 0, len(stats.Days)),
+	}
+	for _, d := range stats.Days {
+		resp.Days = append(resp.Days, RewardDailyStats{
+			Day:               d.Day,
+			Claimers:          d.Claimers,
+			NewClaimers:       d.NewClaimers,
+			ReturningClaimers: d.ReturningClaimers,
+			Minted:            d.Minted.String(),
+			TotalMinted:       d.TotalMinted.String(),
+		})
+	}
+	respondSuccess(w, resp)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/operation"
+)
+
+// OperationInfo 异步操作状态
+type OperationInfo struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Address    string    `json:"address"`
+	Status     string    `json:"status"` // funding / submitted / success / failed
+	FundTxHash string    `json:"fundTxHash,omitempty"`
+	TxHash     string    `json:"txHash"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func operationInfo(op *operation.OperationModel) OperationInfo {
+	return OperationInfo{
+		ID:         op.ID,
+		Kind:       op.Kind,
+		Address:    op.Address,
+		Status:     op.Status,
+		FundTxHash: op.FundTxHash,
+		TxHash:     op.TxHash,
+		Error:      op.Error,
+		CreatedAt:  op.CreatedAt,
+		UpdatedAt:  op.UpdatedAt,
+	}
+}
+
+// loadOperation 查询操作；登录用户创建的操作只有本人可见，未登录创建的操作凭 ID 查询
+func (s *Server) loadOperation(w http.ResponseWriter, r *http.Request) (*operation.OperationModel, bool) {
+	op, err := s.Operations.Get(mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return nil, false
+		}
+		respondError(w, http.StatusInternalServerError, "查询操作失败")
+		return nil, false
+	}
+	if op.UserID != 0 {
+		userID, _ := r.Context().Value(contextKeyUserID).(int64)
+		if userID != op.UserID {
+			respondError(w, http.StatusNotFound, operation.ErrNotFound.Error())
+			return nil, false
+		}
+	}
+	return op, true
+}
+
+// 查询异步操作状态
+func (s *Server) handleGetOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.loadOperation(w, r)
+	if !ok {
+		return
+	}
+	respondSuccess(w, operationInfo(op))
+}
+
+// 列出当前用户最近的异步操作
+func (s *Server) handleListOperations(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	ops, err := s.Operations.List(userID, 50)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询操作失败")
+		return
+	}
+	infos := make([]OperationInfo, 0, len(ops))
+	for i := range ops {
+		infos = append(infos, operationInfo(&ops[i]))
+	}
+	respondSuccess(w, infos)
+}
+
+// 订阅异步操作状态（Server-Sent Events）：状态变化时推送一条 OperationInfo，结束后关闭连接
+func (s *Server) handleOperationEvents(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.loadOperation(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "不支持流式响应")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func(op *operation.OperationModel) {
+		data, _ := json.Marshal(operationInfo(op))
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(op)
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	timeout := time.After(30 * time.Minute)
+	last := op.Status
+	for !op.Terminal() {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			return
+		case <-ticker.C:
+			next, err := s.Operations.Get(op.ID)
+			if err != nil {
+				return
+			}
+			op = next
+			if op.Status != last {
+				last = op.Status
+				send(op)
+			}
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/config"
+	"lbtc/internal/passkey"
+)
+
+// PasskeyRegisterBeginRequest 开始注册通行密钥请求
+type PasskeyRegisterBeginRequest struct {
+	Password string `json:"password"`
+	TOTPCode string `json:"totpCode,omitempty"` // 已开启两步验证时必填
+}
+
+// PasskeyRegisterFinishRequest 完成注册通行密钥请求
+type PasskeyRegisterFinishRequest struct {
+	CeremonyID string          `json:"ceremonyId"`
+	Name       string          `json:"name,omitempty"`
+	Credential json.RawMessage `json:"credential"` // navigator.credentials.create() 的结果（PublicKeyCredential JSON）
+}
+
+// PasskeyCeremonyResponse 通行密钥流程参数，options 原样传给 navigator.credentials.create()/get()
+type PasskeyCeremonyResponse struct {
+	CeremonyID string      `json:"ceremonyId"`
+	Options    interface{} `json:"options"`
+	Message    string      `json:"message,omitempty"` // 交易确认或重新认证时被承诺的参数，供前端展示
+}
+
+// PasskeyLoginFinishRequest 完成通行密钥登录请求
+type PasskeyLoginFinishRequest struct {
+	CeremonyID string          `json:"ceremonyId"`
+	Credential json.RawMessage `json:"credential"` // navigator.credentials.get() 的结果
+}
+
+// PasskeyConfirmBeginRequest 开始通行密钥交易确认请求（参数与转账请求一致）
+type PasskeyConfirmBeginRequest struct {
+	To      string `json:"to"`
+	Amount  string `json:"amount"`
+	Account string `json:"account,omitempty"`
+}
+
+// PasskeyConfirmation 转账请求中附带的通行密钥确认
+type PasskeyConfirmation struct {
+	CeremonyID string          `json:"ceremonyId"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// PasskeyPolicyRequest 设置通行密钥转账确认策略请求
+type PasskeyPolicyRequest struct {
+	RequireForTransfers bool   `json:"requireForTransfers"`
+	TOTPCode            string `json:"totpCode,omitempty"`
+
+	// 关闭转账确认时需要重新认证：密码（已开启两步验证时还需要 totpCode），或通行密钥断言
+	Password string               `json:"password,omitempty"`
+	Passkey  *PasskeyConfirmation `json:"passkey,omitempty"`
+}
+
+// PasskeyReauthRequest 删除通行密钥的重新认证：密码（已开启两步验证时还需要验证码），或通行密钥断言
+type PasskeyReauthRequest struct {
+	Password string               `json:"password,omitempty"`
+	TOTPCode string               `json:"totpCode,omitempty"`
+	Passkey  *PasskeyConfirmation `json:"passkey,omitempty"`
+}
+
+// PasskeyReauthBeginRequest 开始通行密钥重新认证请求
+type PasskeyReauthBeginRequest struct {
+	Action string `json:"action"`       // delete：删除通行密钥；disable：关闭转账确认
+	ID     int64  `json:"id,omitempty"` // action 为 delete 时要删除的通行密钥 ID
+}
+
+// PasskeyInfo 通行密钥信息
+type PasskeyInfo struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// PasskeysResponse 通行密钥列表与转账确认策略
+type PasskeysResponse struct {
+	Passkeys            []PasskeyInfo `json:"passkeys"`
+	RequireForTransfers bool          `json:"requireForTransfers"`
+}
+
+func passkeyInfo(m passkey.CredentialModel) PasskeyInfo {
+	return PasskeyInfo{ID: m.ID, Name: m.Name, CreatedAt: m.CreatedAt, LastUsedAt: m.LastUsedAt}
+}
+
+// transferMessage 生成通行密钥确认的交易参数；地址与金额规范化，保证开始确认与实际转账时结果一致
+func (s *Server) transferMessage(to common.Address, amount *big.Int, account string) string {
+	return passkey.TxMessage(config.GetChainID(), s.ContractAddress.Hex(), account, to.Hex(), amount.String())
+}
+
+// respondPasskeyError 将通行密钥流程的错误映射为 HTTP 状态码
+func respondPasskeyError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, passkey.ErrCeremonyNotFound), errors.Is(err, passkey.ErrTxMismatch):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, passkey.ErrVerificationFailed):
+		respondError(w, http.StatusUnauthorized, passkey.ErrVerificationFailed.Error())
+	case errors.Is(err, passkey.ErrCredentialNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("%s: %v", fallback, err)
+		respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// settingsMessage 生成设置变更的重新认证参数，断言只能用于这一项变更
+func settingsMessage(action string, id int64) (string, bool) {
+	switch action {
+	case "delete":
+		if id <= 0 {
+			return "", false
+		}
+		return passkey.SettingsMessage(config.GetChainID(), "delete passkey "+strconv.FormatInt(id, 10)), true
+	case "disable":
+		return passkey.SettingsMessage(config.GetChainID(), "disable transfer confirmation"), true
+	}
+	return "", false
+}
+
+// checkPassword 校验当前用户的密码（与登录共用限流与失败锁定），已开启两步验证时还需要验证码；返回 false 时已写入错误响应
+func (s *Server) checkPassword(w http.ResponseWriter, r *http.Request, userID int64, password, totpCode string) bool {
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return false
+	}
+	if !s.reauthenticate(w, r, user, password) {
+		return false
+	}
+	return s.requireTOTP(w, r, userID, totpCode)
+}
+
+// confirmSettingsChange 敏感的通行密钥设置变更前重新认证：通行密钥断言（challenge 承诺 message），或密码加两步验证码；
+// 返回 false 时已写入错误响应
+func (s *Server) confirmSettingsChange(w http.ResponseWriter, r *http.Request, userID int64, password, totpCode string, confirmation *PasskeyConfirmation, message string) bool {
+	if confirmation != nil {
+		if err := s.Passkeys.VerifyConfirmation(userID, confirmation.CeremonyID, message, confirmation.Credential); err != nil {
+			respondPasskeyError(w, err, "校验通行密钥失败")
+			return false
+		}
+		return true
+	}
+	if password == "" {
+		respondError(w, http.StatusUnauthorized, "需要密码或通行密钥确认")
+		return false
+	}
+	return s.checkPassword(w, r, userID, password, totpCode)
+}
+
+// checkTransferPasskey 校验转账的通行密钥确认；返回 confirmed=true 表示已确认（可替代两步验证码），
+// 返回 ok=false 时已写入错误响应
+func (s *Server) checkTransferPasskey(w http.ResponseWriter, userID int64, req TransferRequest, to common.Address, amount *big.Int) (confirmed, ok bool) {
+	if req.Passkey == nil {
+		required, err := s.Passkeys.RequiredForTransfers(userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "查询通行密钥策略失败")
+			return false, false
+		}
+		if required {
+			respondJSON(w, http.StatusUnauthorized, Response{
+				Success: false,
+				Data:    map[string]interface{}{"passkeyRequired": true},
+				Error:   "需要通行密钥确认",
+			})
+			return false, false
+		}
+		return false, true
+	}
+
+	message := s.transferMessage(to, amount, req.Account)
+	if err := s.Passkeys.VerifyConfirmation(userID, req.Passkey.CeremonyID, message, req.Passkey.Credential); err != nil {
+		respondPasskeyError(w, err, "校验通行密钥失败")
+		return false, false
+	}
+	return true, true
+}
+
+// 开始注册通行密钥（需要密码，已开启两步验证时还需要验证码）
+func (s *Server) handlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req PasskeyRegisterBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !s.checkPassword(w, r, userID, req.Password, req.TOTPCode) {
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+
+	id, creation, err := s.Passkeys.BeginRegistration(user.ID, user.Email)
+	if err != nil {
+		respondPasskeyError(w, err, "开始注册通行密钥失败")
+		return
+	}
+	respondSuccess(w, PasskeyCeremonyResponse{CeremonyID: id, Options: creation})
+}
+
+// 完成注册通行密钥
+func (s *Server) handlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req PasskeyRegisterFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.CeremonyID == "" || len(req.Credential) == 0 {
+		respondError(w, http.StatusBadRequest, "ceremonyId 和 credential 不能为空")
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+
+	cred, err := s.Passkeys.FinishRegistration(user.ID, req.CeremonyID, user.Email, req.Name, req.Credential)
+	if err != nil {
+		respondPasskeyError(w, err, "注册通行密钥失败")
+		return
+	}
+	respondSuccess(w, passkeyInfo(*cred))
+}
+
+// 列出通行密钥
+func (s *Server) handleListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	creds, err := s.Passkeys.List(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询通行密钥失败")
+		return
+	}
+	required, err := s.Passkeys.RequiredForTransfers(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询通行密钥策略失败")
+		return
+	}
+	infos := make([]PasskeyInfo, 0, len(creds))
+	for _, c := range creds {
+		infos = append(infos, passkeyInfo(c))
+	}
+	respondSuccess(w, PasskeysResponse{Passkeys: infos, RequireForTransfers: required})
+}
+
+// 删除通行密钥（需要重新认证）
+func (s *Server) handleDeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "无效的通行密钥 ID")
+		return
+	}
+	var req PasskeyReauthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	message, _ := settingsMessage("delete", id)
+	if !s.confirmSettingsChange(w, r, userID, req.Password, req.TOTPCode, req.Passkey, message) {
+		return
+	}
+	if err := s.Passkeys.Delete(userID, id); err != nil {
+		respondPasskeyError(w, err, "删除通行密钥失败")
+		return
+	}
+	respondSuccess(w, map[string]interface{}{"deleted": true})
+}
+
+// 设置转账是否必须使用通行密钥确认（关闭时需要重新认证）
+func (s *Server) handlePasskeyPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req PasskeyPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.RequireForTransfers {
+		if !s.requireTOTP(w, r, userID, req.TOTPCode) {
+			return
+		}
+	} else {
+		// 关闭转账确认会降低转账保护，与删除通行密钥一样需要重新认证
+		message, _ := settingsMessage("disable", 0)
+		if !s.confirmSettingsChange(w, r, userID, req.Password, req.TOTPCode, req.Passkey, message) {
+			return
+		}
+	}
+	if err := s.Passkeys.SetRequiredForTransfers(userID, req.RequireForTransfers); err != nil {
+		if errors.Is(err, passkey.ErrCredentialNotFound) {
+			respondError(w, http.StatusBadRequest, "请先注册通行密钥")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "设置通行密钥策略失败")
+		return
+	}
+	respondSuccess(w, map[string]interface{}{"requireForTransfers": req.RequireForTransfers})
+}
+
+// 开始通行密钥登录（可发现凭据，无需输入邮箱）
+func (s *Server) handlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	if !s.allow(w, "passkey:ip:"+clientIP(r), passkeyIPRule) {
+		return
+	}
+	id, assertion, err := s.Passkeys.BeginLogin()
+	if err != nil {
+		respondPasskeyError(w, err, "开始通行密钥登录失败")
+		return
+	}
+	respondSuccess(w, PasskeyCeremonyResponse{CeremonyID: id, Options: assertion})
+}
+
+// 完成通行密钥登录；通行密钥要求用户验证，本身即为多因素，不再要求两步验证码
+func (s *Server) handlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req PasskeyLoginFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.CeremonyID == "" || len(req.Credential) == 0 {
+		respondError(w, http.StatusBadRequest, "ceremonyId 和 credential 不能为空")
+		return
+	}
+
+	userID, err := s.Passkeys.FinishLogin(req.CeremonyID, req.Credential)
+	if err != nil {
+		respondPasskeyError(w, err, "通行密钥登录失败")
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	s.loginSucceeded(user.Email)
+
+	tokens, err := s.createSession(r, user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成令牌失败")
+		return
+	}
+	respondSuccess(w, LoginResponse{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresIn:     tokens.ExpiresIn,
+		EmailVerified: user.EmailVerified(),
+	})
+}
+
+// 开始通行密钥交易确认；challenge 承诺收款地址、金额与发送账户，断言只能用于这笔转账
+func (s *Server) handlePasskeyConfirmBegin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req PasskeyConfirmBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !common.IsHexAddress(req.To) {
+		respondError(w, http.StatusBadRequest, "无效的接收地址")
+		return
+	}
+	amount, ok := new(big.Int).SetString(req.Amount, 10)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的金额格式")
+		return
+	}
+
+	message := s.transferMessage(common.HexToAddress(req.To), amount, req.Account)
+	id, assertion, err := s.Passkeys.BeginConfirmation(userID, message)
+	if err != nil {
+		respondPasskeyError(w, err, "开始通行密钥确认失败")
+		return
+	}
+	respondSuccess(w, PasskeyCeremonyResponse{CeremonyID: id, Options: assertion, Message: message})
+}
+
+// 开始通行密钥重新认证（删除通行密钥、关闭转账确认前）；challenge 承诺具体的变更
+func (s *Server) handlePasskeyReauthBegin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req PasskeyReauthBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	message, ok := settingsMessage(req.Action, req.ID)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "action 必须为 delete（需要 id）或 disable")
+		return
+	}
+	id, assertion, err := s.Passkeys.BeginConfirmation(userID, message)
+	if err != nil {
+		respondPasskeyError(w, err, "开始通行密钥确认失败")
+		return
+	}
+	respondSuccess(w, PasskeyCeremonyResponse{CeremonyID: id, Options: assertion, Message: message})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+	"lbtc/internal/passkey"
+	"lbtc/internal/ratelimit"
+	"lbtc/internal/storage"
+)
+
+// newPasskeyTestServer 只初始化通行密钥管理接口用到的服务，返回服务器、用户 ID 和一个已保存的通行密钥 ID
+func newPasskeyTestServer(t *testing.T) (*Server, int64, int64) {
+	t.Helper()
+	db, err := storage.OpenGORM(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authService, err := auth.NewService(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := ratelimit.NewLimiter(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLogger, err := audit.NewLogger(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkeys, err := passkey.NewService(db, "example.com", "QXB", []string{"https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{AuthService: authService, Limiter: limiter, Audit: auditLogger, Passkeys: passkeys}
+
+	user, err := authService.Register("user@example.com", "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := passkey.CredentialModel{UserID: user.ID, CredentialID: "cred-1", Name: "MacBook", Data: "{}"}
+	if err := db.Create(&cred).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := passkeys.SetRequiredForTransfers(user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	return s, user.ID, cred.ID
+}
+
+func callPasskeyHandler(h http.HandlerFunc, userID int64, method, body string, vars map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), contextKeyUserID, userID))
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestDeletePasskeyRequiresReauth(t *testing.T) {
+	s, userID, credID := newPasskeyTestServer(t)
+	vars := map[string]string{"id": strconv.FormatInt(credID, 10)}
+
+	for _, body := range []string{`{}`, `{"password": "wrong password"}`} {
+		w := callPasskeyHandler(s.handleDeletePasskey, userID, http.MethodDelete, body, vars)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("body %s: status = %d, want 401", body, w.Code)
+		}
+	}
+	if creds, _ := s.Passkeys.List(userID); len(creds) != 1 {
+		t.Fatalf("passkey deleted without re-authentication")
+	}
+
+	w := callPasskeyHandler(s.handleDeletePasskey, userID, http.MethodDelete, `{"password": "correct horse battery"}`, vars)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if creds, _ := s.Passkeys.List(userID); len(creds) != 0 {
+		t.Fatalf("passkey not deleted")
+	}
+}
+
+func TestDeletePasskeyRejectsUnknownCeremony(t *testing.T) {
+	s, userID, credID := newPasskeyTestServer(t)
+	vars := map[string]string{"id": strconv.FormatInt(credID, 10)}
+
+	body := `{"passkey": {"ceremonyId": "00000000-0000-0000-0000-000000000000", "credential": {}}}`
+	w := callPasskeyHandler(s.handleDeletePasskey, userID, http.MethodDelete, body, vars)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if creds, _ := s.Passkeys.List(userID); len(creds) != 1 {
+		t.Fatalf("passkey deleted with an unknown ceremony")
+	}
+}
+
+func TestDisablePasskeyPolicyRequiresReauth(t *testing.T) {
+	s, userID, _ := newPasskeyTestServer(t)
+
+	w := callPasskeyHandler(s.handlePasskeyPolicy, userID, http.MethodPut, `{"requireForTransfers": false}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if required, _ := s.Passkeys.RequiredForTransfers(userID); !required {
+		t.Fatal("policy disabled without re-authentication")
+	}
+
+	w = callPasskeyHandler(s.handlePasskeyPolicy, userID, http.MethodPut,
+		`{"requireForTransfers": false, "password": "correct horse battery"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if required, _ := s.Passkeys.RequiredForTransfers(userID); required {
+		t.Fatal("policy still enabled")
+	}
+}
+
+func TestSettingsMessageBindsAction(t *testing.T) {
+	del1, ok := settingsMessage("delete", 1)
+	if !ok {
+		t.Fatal("delete 1 rejected")
+	}
+	del2, _ := settingsMessage("delete", 2)
+	disable, _ := settingsMessage("disable", 0)
+	if del1 == del2 || del1 == disable {
+		t.Fatal("settings messages must differ per action and passkey")
+	}
+	for _, tc := range []struct {
+		action string
+		id     int64
+	}{{"delete", 0}, {"rename", 1}, {"", 0}} {
+		if _, ok := settingsMessage(tc.action, tc.id); ok {
+			t.Fatalf("settingsMessage(%q, %d) accepted", tc.action, tc.id)
+		}
+	}
+}
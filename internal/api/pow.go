@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"lbtc/internal/pow"
+)
+
+// PoWChallenge 工作量证明挑战
+type PoWChallenge struct {
+	Enabled    bool       `json:"enabled"` // 为 false 时无需提交工作量证明
+	Action     string     `json:"action"`
+	Algorithm  string     `json:"algorithm,omitempty"` // sha256：SHA-256(token + ":" + solution) 至少有 difficulty 个前导零位
+	Token      string     `json:"token,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// 获取工作量证明挑战
+func (s *Server) handlePoWChallenge(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Query().Get("action")
+	if !pow.ValidAction(action) {
+		respondError(w, http.StatusBadRequest, "action 必须是 register、claim 或 faucet")
+		return
+	}
+	if !s.PoW.Enabled() {
+		respondSuccess(w, PoWChallenge{Enabled: false, Action: action})
+		return
+	}
+	challenge, err := s.PoW.Issue(action, clientIP(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成挑战失败")
+		return
+	}
+	respondSuccess(w, PoWChallenge{
+		Enabled:    true,
+		Action:     action,
+		Algorithm:  "sha256",
+		Token:      challenge.Token,
+		Difficulty: challenge.Difficulty,
+		ExpiresAt:  &challenge.ExpiresAt,
+	})
+}
+
+// proofOfWork 要求请求带有 action 的工作量证明（X-PoW-Token 与 X-PoW-Solution 请求头）；未启用时直接放行
+// optional 为 true 时没有请求头也放行，由处理函数在需要时（如需要 Gas 补贴）调用 powSolved 检查
+func (s *Server) proofOfWork(action string, optional bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.PoW.Enabled() {
+			next(w, r)
+			return
+		}
+		token, solution := r.Header.Get("X-PoW-Token"), r.Header.Get("X-PoW-Solution")
+		if optional && token == "" && solution == "" {
+			next(w, r)
+			return
+		}
+		if err := s.PoW.Verify(action, clientIP(r), token, solution); err != nil {
+			respondPoWError(w, action, err)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKeyPoW, true)))
+	}
+}
+
+// powSolved 请求是否已通过工作量证明（未启用时视为通过）
+func (s *Server) powSolved(r *http.Request) bool {
+	solved, _ := r.Context().Value(contextKeyPoW).(bool)
+	return solved || !s.PoW.Enabled()
+}
+
+// needsTopUp 地址的 ETH 余额是否低于补贴阈值（不检查额度）
+func (s *Server) needsTopUp(ctx context.Context, address common.Address) (bool, error) {
+	if s.OwnerPrivateKey == nil {
+		return false, nil
+	}
+	balance, err := s.Client.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return false, err
+	}
+	return s.Faucet.NeedsTopUp(balance), nil
+}
+
+func respondPoWError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, pow.ErrRequired), errors.Is(err, pow.ErrExpired):
+		respondError(w, http.StatusPreconditionRequired, fmt.Sprintf("%s（GET /api/pow/challenge?action=%s）", err.Error(), action))
+	case errors.Is(err, pow.ErrInvalid), errors.Is(err, pow.ErrInsufficient), errors.Is(err, pow.ErrReplayed):
+		respondError(w, http.StatusForbidden, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "校验工作量证明失败")
+	}
+}
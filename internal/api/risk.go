@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+	"lbtc/internal/risk"
+)
+
+// RiskReviewRequest 管理员审核请求
+type RiskReviewRequest struct {
+	Decision string `json:"decision"` // allow / block / reset（撤销决定，恢复按自动评估处理）
+	Note     string `json:"note,omitempty"`
+}
+
+// RiskAssessmentInfo 用户风险评估（管理员）
+type RiskAssessmentInfo struct {
+	UserID       int64                 `json:"userId"`
+	Email        string                `json:"email,omitempty"`
+	Score        int                   `json:"score"`
+	Status       string                `json:"status"`             // 自动评估：clear / flagged / blocked
+	Decision     string                `json:"decision,omitempty"` // 管理员决定：allow / block
+	Effective    string                `json:"effective"`          // 最终生效的状态
+	Signals      []risk.Signal         `json:"signals"`
+	Note         string                `json:"note,omitempty"`
+	ReviewedBy   string                `json:"reviewedBy,omitempty"`
+	ReviewedAt   *time.Time            `json:"reviewedAt,omitempty"`
+	ScoredAt     time.Time             `json:"scoredAt"`
+	Registration *RiskRegistrationInfo `json:"registration,omitempty"`
+}
+
+// RiskRegistrationInfo 注册信息（管理员）
+type RiskRegistrationInfo struct {
+	IP          string    `json:"ip,omitempty"`
+	IPPrefix    string    `json:"ipPrefix,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	EmailDomain string    `json:"emailDomain"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func riskAssessmentInfo(a *risk.AssessmentModel) RiskAssessmentInfo {
+	signals := a.DecodeSignals()
+	if signals == nil {
+		signals = []risk.Signal{}
+	}
+	return RiskAssessmentInfo{
+		UserID:     a.UserID,
+		Score:      a.Score,
+		Status:     a.Status,
+		Decision:   a.Decision,
+		Effective:  a.Effective(),
+		Signals:    signals,
+		Note:       a.Note,
+		ReviewedBy: a.ReviewedBy,
+		ReviewedAt: a.ReviewedAt,
+		ScoredAt:   a.ScoredAt,
+	}
+}
+
+// recordRegistration 记录注册 IP 与设备指纹并评分；失败只打印日志，不影响注册
+func (s *Server) recordRegistration(r *http.Request, user *auth.User) {
+	ip := clientIP(r)
+	fingerprint := risk.Fingerprint(r.Header.Get("X-Device-Fingerprint"), r.UserAgent(), r.Header.Get("Accept-Language"), ip)
+	if _, err := s.Risk.RecordRegistration(user.ID, user.Email, user.Address, ip, fingerprint); err != nil {
+		log.Printf("记录用户 %d 的注册风控信息失败: %v", user.ID, err)
+	}
+}
+
+// riskStatus 风控状态：登录用户按用户，未登录（直接提供私钥）按使用该地址的用户；查询失败时不限制
+func (s *Server) riskStatus(userID int64, address common.Address) string {
+	var status string
+	var err error
+	if userID > 0 {
+		status, err = s.Risk.Status(userID)
+	} else {
+		status, err = s.Risk.StatusByAddress(address.Hex())
+	}
+	if err != nil {
+		log.Printf("查询 %s 的风控状态失败: %v", address.Hex(), err)
+		return risk.StatusClear
+	}
+	return status
+}
+
+func riskUserID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id, err == nil && id > 0
+}
+
+// 管理员查询待审核的风控标记
+func (s *Server) handleRiskReviewQueue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "", risk.StatusFlagged, risk.StatusBlocked:
+	default:
+		respondError(w, http.StatusBadRequest, "status 必须是 flagged 或 blocked")
+		return
+	}
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 1000 之间")
+			return
+		}
+		limit = n
+	}
+
+	list, err := s.Risk.ReviewQueue(status, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询待审核账户失败")
+		return
+	}
+	infos := make([]RiskAssessmentInfo, 0, len(list))
+	for i := range list {
+		info := riskAssessmentInfo(&list[i])
+		if user, err := s.AuthService.GetByID(list[i].UserID); err == nil {
+			info.Email = user.Email
+		}
+		infos = append(infos, info)
+	}
+	respondSuccess(w, infos)
+}
+
+// 管理员查询用户的风险评估与注册信息
+func (s *Server) handleGetRiskAssessment(w http.ResponseWriter, r *http.Request) {
+	id, ok := riskUserID(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的用户 ID")
+		return
+	}
+	user, err := s.AuthService.GetByID(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+	a, reg, err := s.Risk.Get(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询风险评估失败")
+		return
+	}
+	if a == nil {
+		respondError(w, http.StatusNotFound, "该用户尚未评估")
+		return
+	}
+	info := riskAssessmentInfo(a)
+	info.Email = user.Email
+	if reg != nil {
+		info.Registration = &RiskRegistrationInfo{
+			IP:          reg.IP,
+			IPPrefix:    reg.IPPrefix,
+			Fingerprint: reg.Fingerprint,
+			EmailDomain: reg.EmailDomain,
+			CreatedAt:   reg.CreatedAt,
+		}
+	}
+	respondSuccess(w, info)
+}
+
+// 管理员审核：放行、封禁或撤销决定
+func (s *Server) handleReviewRisk(w http.ResponseWriter, r *http.Request) {
+	id, ok := riskUserID(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的用户 ID")
+		return
+	}
+	var req RiskReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	decision := req.Decision
+	switch decision {
+	case risk.DecisionAllow, risk.DecisionBlock:
+	case "reset":
+		decision = ""
+	default:
+		respondError(w, http.StatusBadRequest, "decision 必须是 allow、block 或 reset")
+		return
+	}
+	user, err := s.AuthService.GetByID(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	reviewer, _ := r.Context().Value(contextKeyUserEmail).(string)
+	a, err := s.Risk.Review(id, decision, req.Note, reviewer)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "保存审核结果失败")
+		return
+	}
+
+	adminID := r.Context().Value(contextKeyUserID).(int64)
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventRiskReview,
+		UserID:  &adminID,
+		Subject: user.Email,
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("审核用户 %d：%s（评分 %d，自动评估 %s）%s", id, req.Decision, a.Score, a.Status, req.Note),
+	})
+	info := riskAssessmentInfo(a)
+	info.Email = user.Email
+	respondSuccess(w, info)
+}
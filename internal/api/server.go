@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"crypto/ecdsa"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -14,9 +17,26 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gorilla/mux"
 
+	"lbtc/internal/audit"
 	"lbtc/internal/auth"
+	"lbtc/internal/autoclaim"
+	"lbtc/internal/bonus"
+	"lbtc/internal/claims"
 	"lbtc/internal/config"
+	"lbtc/internal/escrow"
+	"lbtc/internal/faucet"
+	"lbtc/internal/idempotency"
+	"lbtc/internal/jobqueue"
+	"lbtc/internal/mail"
+	"lbtc/internal/operation"
+	"lbtc/internal/passkey"
+	"lbtc/internal/pow"
+	"lbtc/internal/ratelimit"
+	"lbtc/internal/risk"
 	"lbtc/internal/storage"
+	"lbtc/internal/txtrack"
+	"lbtc/internal/unlock"
+	"lbtc/internal/walletlink"
 )
 
 // Server API 服务器结构
@@ -24,9 +44,27 @@ type Server struct {
 	Router          *mux.Router
 	Client          *ethclient.Client
 	Contract        *ContractService
-	ContractAddress common.Address    // 固定的合约地址
-	AuthService     *auth.Service     // 认证服务
-	OwnerPrivateKey *ecdsa.PrivateKey // 合约拥有者私钥（用于自动转账 ETH）
+	ContractAddress common.Address      // 固定的合约地址
+	AuthService     *auth.Service       // 认证服务
+	EscrowService   *escrow.Service     // 私钥托管服务
+	Unlocked        *unlock.Store       // 钱包解锁会话（内存）
+	TxTracker       *txtrack.Tracker    // 已广播交易的收据跟踪
+	WalletLinks     *walletlink.Service // 外部钱包绑定与提现白名单
+	Mailer          mail.Mailer         // 验证邮件与免密登录邮件
+	Limiter         *ratelimit.Limiter  // 登录/注册限流与失败锁定
+	Audit           *audit.Logger       // 安全审计事件
+	Passkeys        *passkey.Service    // 通行密钥登录与交易确认
+	SIWEDomain      string              // SIWE 消息中必须出现的 domain（来自配置，不信任请求 Host）
+	Faucet          *faucet.Service     // Gas 补贴策略与记录
+	Operations      *operation.Service  // 需要先补贴 Gas 的异步操作
+	Jobs            *jobqueue.Queue     // 链上交易任务队列（补贴、转账、领取、广播）
+	Idempotency     *idempotency.Store  // 转账与领取请求的 Idempotency-Key
+	PoW             *pow.Service        // 注册、领取与 Gas 补贴前的工作量证明
+	Claims          *claims.Service     // 链上领取事件索引与领取锁对账
+	AutoClaim       *autoclaim.Service  // 自动领取委托与调度
+	Bonus           *bonus.Service      // 连续领取里程碑奖金
+	Risk            *risk.Service       // 批量注册风险评分与审核
+	OwnerPrivateKey *ecdsa.PrivateKey   // 合约拥有者私钥（用于自动转账 ETH）
 }
 
 // ContractService 合约服务
@@ -120,6 +158,33 @@ const qxbABI = `[
 		"outputs": [{"name": "", "type": "string"}],
 		"type": "function"
 	},
+	{
+		"constant": false,
+		"inputs": [
+			{"name": "_to", "type": "address"},
+			{"name": "_value", "type": "uint256"}
+		],
+		"name": "transfer",
+		"outputs": [{"name": "success", "type": "bool"}],
+		"type": "function"
+	},
+	{
+		"constant": false,
+		"inputs": [
+			{"name": "_spender", "type": "address"},
+			{"name": "_value", "type": "uint256"}
+		],
+		"name": "approve",
+		"outputs": [{"name": "success", "type": "bool"}],
+		"type": "function"
+	},
+	{
+		"constant": false,
+		"inputs": [{"name": "_amount", "type": "uint256"}],
+		"name": "burn",
+		"outputs": [],
+		"type": "function"
+	},
 	{
 		"constant": false,
 		"inputs": [],
@@ -148,6 +213,151 @@ func NewServer(rpcURL string) *Server {
 		log.Fatalf("初始化认证服务失败: %v", err)
 	}
 
+	authService.AccessTokenTTL = config.GetAccessTokenTTL()
+	authService.RefreshTokenTTL = config.GetRefreshTokenTTL()
+	auth.SetArgonConcurrency(config.GetArgonMaxConcurrency())
+
+	// 初始化限流与审计日志
+	limiter, err := ratelimit.NewLimiter(db)
+	if err != nil {
+		log.Fatalf("初始化限流失败: %v", err)
+	}
+	go limiter.Run(context.Background(), 10*time.Minute)
+	auditLogger, err := audit.NewLogger(db)
+	if err != nil {
+		log.Fatalf("初始化审计日志失败: %v", err)
+	}
+
+	// 加载 JWT 签名密钥
+	keySet, err := auth.LoadKeySet(config.GetJWTKeysFile(), config.GetJWTSecret())
+	if err != nil {
+		log.Fatalf("加载 JWT 密钥失败: %v", err)
+	}
+	if keySet != nil {
+		auth.SetKeySet(keySet)
+	}
+
+	// 初始化私钥托管服务
+	escrowService, err := escrow.NewService(db, config.GetEscrowRecoveryDelay())
+	if err != nil {
+		log.Fatalf("初始化私钥托管服务失败: %v", err)
+	}
+
+	// 初始化外部钱包绑定服务
+	walletLinks, err := walletlink.NewService(db, config.GetWithdrawCoolingOff(), config.GetWithdrawMinCoolingOff())
+	if err != nil {
+		log.Fatalf("初始化钱包绑定服务失败: %v", err)
+	}
+
+	// 初始化通行密钥服务
+	passkeys, err := passkey.NewService(db, config.GetWebAuthnRPID(), config.GetWebAuthnRPName(), config.GetWebAuthnOrigins())
+	if err != nil {
+		log.Fatalf("初始化通行密钥服务失败: %v", err)
+	}
+
+	siweDomain := config.GetSIWEDomain()
+	if siweDomain == "" {
+		log.Fatalf("无法确定 SIWE domain：请设置 SIWE_DOMAIN 或有效的 APP_BASE_URL")
+	}
+
+	// 初始化 Gas 补贴策略
+	faucetService, err := faucet.NewService(db, faucet.Policy{
+		Threshold:       config.GetFaucetThreshold(),
+		TopUp:           config.GetFaucetTopUp(),
+		UserDailyBudget: config.GetFaucetUserDailyBudget(),
+		DailyBudget:     config.GetFaucetDailyBudget(),
+		TotalBudget:     config.GetFaucetTotalBudget(),
+		Cooldown:        config.GetFaucetCooldown(),
+	})
+	if err != nil {
+		log.Fatalf("初始化 Gas 补贴失败: %v", err)
+	}
+
+	// 初始化交易收据跟踪（后台轮询待确认交易）
+	txTracker, err := txtrack.NewTracker(db, client)
+	if err != nil {
+		log.Fatalf("初始化交易跟踪失败: %v", err)
+	}
+	go txTracker.Run(context.Background(), 15*time.Second)
+
+	// 初始化 Idempotency-Key 存储（定期清理过期记录）
+	idempotencyStore, err := idempotency.NewStore(db, config.GetIdempotencyKeyTTL())
+	if err != nil {
+		log.Fatalf("初始化幂等存储失败: %v", err)
+	}
+	go idempotencyStore.Run(context.Background(), time.Hour)
+
+	// 初始化工作量证明（定期清理已使用的挑战）
+	powSecret := []byte(config.GetPoWSecret())
+	if len(powSecret) == 0 && config.GetPoWDifficulty() > 0 {
+		powSecret = make([]byte, 32)
+		if _, err := rand.Read(powSecret); err != nil {
+			log.Fatalf("生成工作量证明密钥失败: %v", err)
+		}
+		log.Printf("警告: 未设置 POW_SECRET，已随机生成（重启后未完成的挑战失效，多实例部署需要设置相同的密钥）")
+	}
+	powService, err := pow.NewService(db, pow.Config{
+		Secret:        powSecret,
+		Difficulty:    config.GetPoWDifficulty(),
+		MaxDifficulty: config.GetPoWMaxDifficulty(),
+		TTL:           config.GetPoWTTL(),
+		TargetRate:    config.GetPoWTargetRate(),
+	})
+	if err != nil {
+		log.Fatalf("初始化工作量证明失败: %v", err)
+	}
+	go powService.Run(context.Background(), 10*time.Minute)
+
+	// 初始化链上交易任务队列（worker 在注册处理函数后启动）
+	jobs, err := jobqueue.NewQueue(db, config.GetJobMaxAttempts())
+	if err != nil {
+		log.Fatalf("初始化任务队列失败: %v", err)
+	}
+
+	// 初始化异步操作（重启后继续处理未完成的操作）
+	operations, err := operation.NewService(db, client, authService, faucetService, jobs)
+	if err != nil {
+		log.Fatalf("初始化异步操作失败: %v", err)
+	}
+	go operations.Run(context.Background(), 5*time.Second)
+
+	// 初始化领取事件索引与领取锁对账
+	claimsService, err := claims.NewService(db, client, common.HexToAddress(config.QXBContractAddress), authService, jobs, config.GetClaimIndexStartBlock())
+	if err != nil {
+		log.Fatalf("初始化领取对账失败: %v", err)
+	}
+	go claimsService.Run(context.Background(), 30*time.Second)
+
+	// 初始化连续领取奖金（发放在注册处理函数后启动）
+	milestones, err := bonus.ParseMilestones(config.GetStreakBonuses())
+	if err != nil {
+		log.Fatalf("解析 STREAK_BONUSES 失败: %v", err)
+	}
+	bonusService, err := bonus.NewService(db, claimsService, milestones)
+	if err != nil {
+		log.Fatalf("初始化连续领取奖金失败: %v", err)
+	}
+
+	// 初始化风控评分（每 10 分钟根据注册信息与链上事件重新评分）
+	riskService, err := risk.NewService(db, authService, claimsService, risk.Policy{
+		FlagScore:         config.GetRiskFlagScore(),
+		BlockScore:        config.GetRiskBlockScore(),
+		DisposableDomains: config.GetRiskDisposableDomains(),
+	})
+	if err != nil {
+		log.Fatalf("初始化风控评分失败: %v", err)
+	}
+	go riskService.Run(context.Background(), 10*time.Minute)
+
+	// 初始化自动领取（调度在注册处理函数后启动）
+	autoClaims, err := autoclaim.NewService(db, config.GetAutoClaimSecret(), common.HexToAddress(config.QXBContractAddress), config.GetAutoClaimJitter())
+	if err != nil {
+		log.Fatalf("初始化自动领取失败: %v", err)
+	}
+	if !autoClaims.Enabled() {
+		log.Printf("警告: 未配置 AUTOCLAIM_SECRET，自动领取不可用")
+	}
+
 	// 使用内置 ABI（包含最新接口）
 	contractABI, err := abi.JSON(strings.NewReader(qxbABI))
 	if err != nil {
@@ -167,7 +377,7 @@ func NewServer(rpcURL string) *Server {
 		}
 	}
 
-	return &Server{
+	s := &Server{
 		Router:          mux.NewRouter(),
 		Client:          client,
 		ContractAddress: common.HexToAddress(config.QXBContractAddress),
@@ -176,8 +386,34 @@ func NewServer(rpcURL string) *Server {
 			ABI:    contractABI,
 		},
 		AuthService:     authService,
+		EscrowService:   escrowService,
+		Unlocked:        unlock.NewStore(time.Minute),
+		TxTracker:       txTracker,
+		WalletLinks:     walletLinks,
+		Mailer:          newMailer(),
+		Limiter:         limiter,
+		Audit:           auditLogger,
+		Passkeys:        passkeys,
+		SIWEDomain:      siweDomain,
+		Faucet:          faucetService,
+		Operations:      operations,
+		Jobs:            jobs,
+		Idempotency:     idempotencyStore,
+		PoW:             powService,
+		Claims:          claimsService,
+		AutoClaim:       autoClaims,
+		Bonus:           bonusService,
+		Risk:            riskService,
 		OwnerPrivateKey: ownerPrivateKey,
 	}
+	s.registerJobs()
+	go jobs.Run(context.Background(), config.GetJobWorkers())
+	go autoClaims.Run(context.Background(), time.Minute, s.autoClaim)
+	if len(milestones) > 0 && ownerPrivateKey == nil {
+		log.Printf("警告: 未配置拥有者私钥，连续领取奖金将记录为发送失败")
+	}
+	go bonusService.Run(context.Background(), time.Minute, s.enqueueBonus)
+	return s
 }
 
 // SetupRoutes 设置路由
@@ -189,7 +425,7 @@ func (s *Server) SetupRoutes() {
 	s.Router.PathPrefix("/").Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Device-Fingerprint, X-PoW-Token, X-PoW-Solution")
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -199,6 +435,9 @@ func (s *Server) SetupRoutes() {
 	// 健康检查
 	s.Router.HandleFunc("/health", s.handleHealth).Methods("GET")
 
+	// JWT 公钥（供其他服务验证 QXB 令牌）
+	s.Router.HandleFunc("/.well-known/jwks.json", s.handleJWKS).Methods("GET")
+
 	// 代币信息 API
 	api := s.Router.PathPrefix("/api").Subrouter()
 	api.Use(corsMiddleware) // 子路由也需要 CORS
@@ -208,17 +447,118 @@ func (s *Server) SetupRoutes() {
 	api.HandleFunc("/token/balance/{address}", s.handleTokenBalance).Methods("GET")
 	api.HandleFunc("/resume", s.handleResume).Methods("GET")
 
+	// 工作量证明
+	api.HandleFunc("/pow/challenge", s.handlePoWChallenge).Methods("GET")
+
 	// 每日奖励相关
 	api.HandleFunc("/reward/status/{address}", s.handleRewardStatus).Methods("GET")
-	api.HandleFunc("/reward/claim", s.optionalAuthMiddleware(s.handleClaimReward)).Methods("POST")
+	api.HandleFunc("/reward/claim", s.optionalAuthMiddleware(s.idempotent(s.proofOfWork(pow.ActionClaim, false, s.handleClaimReward)))).Methods("POST")
+	api.HandleFunc("/reward/leaderboard", s.handleRewardLeaderboard).Methods("GET")
+	api.HandleFunc("/reward/stats", s.handleRewardStats).Methods("GET")
+	api.HandleFunc("/reward/auto-claim", s.authMiddleware(s.handleAutoClaimStatus)).Methods("GET")
+	api.HandleFunc("/reward/auto-claim", s.authMiddleware(s.custodialOnly(s.handleEnableAutoClaim))).Methods("POST")
+	api.HandleFunc("/reward/auto-claim", s.authMiddleware(s.handleRevokeAutoClaim)).Methods("DELETE")
+	api.HandleFunc("/reward/auto-claim/pause", s.authMiddleware(s.handlePauseAutoClaim)).Methods("POST")
+	api.HandleFunc("/reward/auto-claim/resume", s.authMiddleware(s.handleResumeAutoClaim)).Methods("POST")
+	api.HandleFunc("/reward/auto-claim/history", s.authMiddleware(s.handleAutoClaimHistory)).Methods("GET")
+
+	// 异步操作
+	api.HandleFunc("/operations", s.authMiddleware(s.handleListOperations)).Methods("GET")
+	api.HandleFunc("/operations/{id}", s.optionalAuthMiddleware(s.handleGetOperation)).Methods("GET")
+	api.HandleFunc("/operations/{id}/events", s.optionalAuthMiddleware(s.handleOperationEvents)).Methods("GET")
 
 	// 认证相关
-	api.HandleFunc("/auth/register", s.handleRegister).Methods("POST")
+	api.HandleFunc("/auth/register", s.proofOfWork(pow.ActionRegister, false, s.handleRegister)).Methods("POST")
 	api.HandleFunc("/auth/login", s.handleLogin).Methods("POST")
 	api.HandleFunc("/auth/me", s.authMiddleware(s.handleMe)).Methods("GET")
+	api.HandleFunc("/auth/register-import", s.proofOfWork(pow.ActionRegister, false, s.handleRegisterImport)).Methods("POST")
+	api.HandleFunc("/auth/siwe/nonce", s.handleSIWENonce).Methods("GET")
+	api.HandleFunc("/auth/siwe/verify", s.handleSIWEVerify).Methods("POST")
+	api.HandleFunc("/auth/refresh", s.handleRefresh).Methods("POST")
+	api.HandleFunc("/auth/verify-email", s.handleVerifyEmail).Methods("GET")
+	api.HandleFunc("/auth/verify-email/resend", s.authMiddleware(s.custodialOnly(s.handleResendVerification))).Methods("POST")
+	api.HandleFunc("/auth/magic-link", s.handleMagicLinkRequest).Methods("POST")
+	api.HandleFunc("/auth/magic-link/verify", s.handleMagicLinkLogin).Methods("POST")
+	api.HandleFunc("/auth/passkey/login/begin", s.handlePasskeyLoginBegin).Methods("POST")
+	api.HandleFunc("/auth/passkey/login/finish", s.handlePasskeyLoginFinish).Methods("POST")
+	api.HandleFunc("/auth/logout", s.authMiddleware(s.handleLogout)).Methods("POST")
+	api.HandleFunc("/auth/sessions", s.authMiddleware(s.handleListSessions)).Methods("GET")
+	api.HandleFunc("/auth/sessions", s.authMiddleware(s.handleRevokeOtherSessions)).Methods("DELETE")
+	api.HandleFunc("/auth/sessions/{id}", s.authMiddleware(s.handleRevokeSession)).Methods("DELETE")
+	api.HandleFunc("/auth/password", s.authMiddleware(s.custodialOnly(s.handleChangePassword))).Methods("POST")
+
+	// 两步验证（TOTP）
+	api.HandleFunc("/auth/totp", s.authMiddleware(s.handleTOTPStatus)).Methods("GET")
+	api.HandleFunc("/auth/totp/setup", s.authMiddleware(s.custodialOnly(s.handleTOTPSetup))).Methods("POST")
+	api.HandleFunc("/auth/totp/confirm", s.authMiddleware(s.handleTOTPConfirm)).Methods("POST")
+	api.HandleFunc("/auth/totp/policy", s.authMiddleware(s.handleTOTPPolicy)).Methods("PUT")
+	api.HandleFunc("/auth/totp/backup-codes", s.authMiddleware(s.handleTOTPBackupCodes)).Methods("POST")
+	api.HandleFunc("/auth/totp/disable", s.authMiddleware(s.handleTOTPDisable)).Methods("POST")
+
+	// 通行密钥（WebAuthn）
+	api.HandleFunc("/passkeys", s.authMiddleware(s.custodialOnly(s.handleListPasskeys))).Methods("GET")
+	api.HandleFunc("/passkeys/register/begin", s.authMiddleware(s.custodialOnly(s.handlePasskeyRegisterBegin))).Methods("POST")
+	api.HandleFunc("/passkeys/register/finish", s.authMiddleware(s.custodialOnly(s.handlePasskeyRegisterFinish))).Methods("POST")
+	api.HandleFunc("/passkeys/policy", s.authMiddleware(s.custodialOnly(s.handlePasskeyPolicy))).Methods("PUT")
+	api.HandleFunc("/passkeys/confirm/begin", s.authMiddleware(s.custodialOnly(s.handlePasskeyConfirmBegin))).Methods("POST")
+	api.HandleFunc("/passkeys/reauth/begin", s.authMiddleware(s.custodialOnly(s.handlePasskeyReauthBegin))).Methods("POST")
+	api.HandleFunc("/passkeys/{id}", s.authMiddleware(s.custodialOnly(s.handleDeletePasskey))).Methods("DELETE")
+
+	// 钱包相关
+	api.HandleFunc("/wallet/export", s.authMiddleware(s.custodialOnly(s.handleExportKeystore))).Methods("POST")
+	api.HandleFunc("/wallet/accounts", s.authMiddleware(s.handleListAccounts)).Methods("GET")
+	api.HandleFunc("/wallet/accounts", s.authMiddleware(s.custodialOnly(s.handleCreateAccount))).Methods("POST")
+	api.HandleFunc("/wallet/balance", s.authMiddleware(s.handleAccountBalance)).Methods("GET")
+	api.HandleFunc("/wallet/unlock", s.authMiddleware(s.custodialOnly(s.handleWalletUnlock))).Methods("POST")
+	api.HandleFunc("/wallet/lock", s.authMiddleware(s.handleWalletLock)).Methods("POST")
+	api.HandleFunc("/wallet/session", s.authMiddleware(s.handleWalletSession)).Methods("GET")
+
+	// 消息签名与验证
+	api.HandleFunc("/wallet/sign-message", s.authMiddleware(s.custodialOnly(s.handleSignMessage))).Methods("POST")
+	api.HandleFunc("/wallet/sign-typed-data", s.authMiddleware(s.custodialOnly(s.handleSignTypedData))).Methods("POST")
+	api.HandleFunc("/verify-signature", s.handleVerifySignature).Methods("POST")
+
+	// 外部钱包绑定与提现
+	api.HandleFunc("/wallet/links", s.authMiddleware(s.custodialOnly(s.handleListLinkedWallets))).Methods("GET")
+	api.HandleFunc("/wallet/links", s.authMiddleware(s.custodialOnly(s.handleLinkWallet))).Methods("POST")
+	api.HandleFunc("/wallet/links/challenge", s.authMiddleware(s.custodialOnly(s.handleLinkChallenge))).Methods("POST")
+	api.HandleFunc("/wallet/links/{id}", s.authMiddleware(s.custodialOnly(s.handleUnlinkWallet))).Methods("DELETE")
+	api.HandleFunc("/wallet/withdraw-policy", s.authMiddleware(s.custodialOnly(s.handleWithdrawPolicy))).Methods("PUT")
+	api.HandleFunc("/wallet/withdraw", s.authMiddleware(s.custodialOnly(s.verifiedOnly(s.idempotent(s.proofOfWork(pow.ActionFaucet, true, s.handleWithdraw)))))).Methods("POST")
 
 	// 代币转账（需要认证）
-	api.HandleFunc("/token/transfer", s.authMiddleware(s.handleTransfer)).Methods("POST")
+	api.HandleFunc("/token/transfer", s.authMiddleware(s.verifiedOnly(s.idempotent(s.proofOfWork(pow.ActionFaucet, true, s.handleTransfer))))).Methods("POST")
+
+	// 未签名交易构建与广播（自托管客户端）
+	api.HandleFunc("/tx/build/{action}", s.authMiddleware(s.handleBuildTx)).Methods("POST")
+	api.HandleFunc("/tx/broadcast", s.authMiddleware(s.verifiedOnly(s.handleBroadcastTx))).Methods("POST")
+	api.HandleFunc("/tx", s.authMiddleware(s.handleListTxs)).Methods("GET")
+	api.HandleFunc("/tx/{hash}", s.authMiddleware(s.handleGetTx)).Methods("GET")
+
+	// 私钥托管（Shamir 拆分）
+	api.HandleFunc("/escrow/trustees", s.authMiddleware(s.handleListTrustees)).Methods("GET")
+	api.HandleFunc("/escrow/enroll", s.authMiddleware(s.custodialOnly(s.handleEscrowEnroll))).Methods("POST")
+	api.HandleFunc("/escrow/status", s.authMiddleware(s.handleEscrowStatus)).Methods("GET")
+	api.HandleFunc("/escrow/recovery", s.handleStartRecovery).Methods("POST")
+	api.HandleFunc("/escrow/recovery/{id}", s.handleGetRecovery).Methods("GET")
+	api.HandleFunc("/escrow/recovery/{id}/shares", s.handleSubmitShare).Methods("POST")
+	api.HandleFunc("/escrow/recovery/{id}/cancel", s.optionalAuthMiddleware(s.handleCancelRecovery)).Methods("POST")
+	api.HandleFunc("/escrow/recovery/{id}/complete", s.handleCompleteRecovery).Methods("POST")
+
+	// 管理员
+	api.HandleFunc("/admin/escrow/trustees", s.adminMiddleware(s.handleAddTrustee)).Methods("POST")
+	api.HandleFunc("/admin/audit", s.adminMiddleware(s.handleListAuditEvents)).Methods("GET")
+	api.HandleFunc("/admin/faucet", s.adminMiddleware(s.handleFaucetBudget)).Methods("GET")
+	api.HandleFunc("/admin/faucet/grants", s.adminMiddleware(s.handleListFaucetGrants)).Methods("GET")
+	api.HandleFunc("/admin/bonus/payouts", s.adminMiddleware(s.handleListBonusPayouts)).Methods("GET")
+	api.HandleFunc("/admin/bonus/payouts/{id}/retry", s.adminMiddleware(s.handleRetryBonusPayout)).Methods("POST")
+	api.HandleFunc("/admin/risk/reviews", s.adminMiddleware(s.handleRiskReviewQueue)).Methods("GET")
+	api.HandleFunc("/admin/risk/users/{id}", s.adminMiddleware(s.handleGetRiskAssessment)).Methods("GET")
+	api.HandleFunc("/admin/risk/users/{id}/review", s.adminMiddleware(s.handleReviewRisk)).Methods("POST")
+	api.HandleFunc("/admin/jobs", s.adminMiddleware(s.handleListJobs)).Methods("GET")
+	api.HandleFunc("/admin/jobs/{id}", s.adminMiddleware(s.handleGetJob)).Methods("GET")
+	api.HandleFunc("/admin/jobs/{id}/retry", s.adminMiddleware(s.handleRetryJob)).Methods("POST")
+	api.HandleFunc("/admin/jobs/{id}/cancel", s.adminMiddleware(s.handleCancelJob)).Methods("POST")
 }
 
 // Response 通用响应结构
@@ -260,7 +600,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Device-Fingerprint, X-PoW-Token, X-PoW-Solution")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, Idempotent-Replayed")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
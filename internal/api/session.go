@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/auth"
+)
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse 刷新令牌响应
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// SessionInfo 登录会话（设备）信息
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// clientIP 返回请求来源 IP（不信任 X-Forwarded-For）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// createSession 为登录/注册的用户创建会话
+func (s *Server) createSession(r *http.Request, user *auth.User) (*auth.Tokens, error) {
+	return s.AuthService.CreateSession(user, r.UserAgent(), clientIP(r))
+}
+
+// 刷新 access token（刷新令牌同时轮换）
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+
+	tokens, err := s.AuthService.Refresh(req.RefreshToken, r.UserAgent(), clientIP(r))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "刷新令牌失败")
+		return
+	}
+
+	respondSuccess(w, TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// 退出登录（注销当前会话）
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	if err := s.AuthService.RevokeSession(userID, sessionID); err != nil && !errors.Is(err, auth.ErrSessionNotFound) {
+		respondError(w, http.StatusInternalServerError, "退出登录失败")
+		return
+	}
+	s.Unlocked.Lock(userID, sessionID)
+
+	respondSuccess(w, map[string]interface{}{"loggedOut": true})
+}
+
+// 列出当前有效的登录会话
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	sessions, err := s.AuthService.ListSessions(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取会话列表失败")
+		return
+	}
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		infos = append(infos, SessionInfo{
+			ID:         sess.ID,
+			UserAgent:  sess.UserAgent,
+			IP:         sess.IP,
+			CreatedAt:  sess.CreatedAt,
+			LastUsedAt: sess.LastUsedAt,
+			ExpiresAt:  sess.ExpiresAt,
+			Current:    sess.ID == sessionID,
+		})
+	}
+	respondSuccess(w, infos)
+}
+
+// 注销指定会话（例如丢失的设备）
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	sessionID := mux.Vars(r)["id"]
+	if err := s.AuthService.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "注销会话失败")
+		return
+	}
+	s.Unlocked.Lock(userID, sessionID)
+	respondSuccess(w, map[string]interface{}{"revoked": 1})
+}
+
+// 注销除当前会话以外的所有会话
+func (s *Server) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	n, err := s.AuthService.RevokeOtherSessions(userID, sessionID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "注销会话失败")
+		return
+	}
+	s.Unlocked.LockUserExcept(userID, sessionID)
+	respondSuccess(w, map[string]interface{}{"revoked": n})
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"lbtc/internal/config"
+)
+
+// SignMessageRequest EIP-191 personal_sign 签名请求
+type SignMessageRequest struct {
+	Message  string `json:"message"`
+	Encoding string `json:"encoding,omitempty"` // utf8（默认）或 hex
+	Password string `json:"password,omitempty"` // 未解锁钱包时必填
+	Account  string `json:"account,omitempty"`  // 子账户标签，默认主账户
+}
+
+// SignTypedDataRequest EIP-712 签名请求
+type SignTypedDataRequest struct {
+	TypedData apitypes.TypedData `json:"typedData"`
+	Password  string             `json:"password,omitempty"`
+	Account   string             `json:"account,omitempty"`
+}
+
+// SignatureResponse 签名结果
+type SignatureResponse struct {
+	Address   string `json:"address"`
+	Hash      string `json:"hash"`      // 实际被签名的 32 字节哈希
+	Signature string `json:"signature"` // 65 字节 r || s || v，v 为 27/28
+}
+
+// VerifySignatureRequest 验证签名请求（message 与 typedData 二选一）
+type VerifySignatureRequest struct {
+	Address   string              `json:"address"`
+	Signature string              `json:"signature"`
+	Message   *string             `json:"message,omitempty"`
+	Encoding  string              `json:"encoding,omitempty"`
+	TypedData *apitypes.TypedData `json:"typedData,omitempty"`
+}
+
+// VerifySignatureResponse 验证签名结果
+type VerifySignatureResponse struct {
+	Valid  bool   `json:"valid"`
+	Signer string `json:"signer"` // 从签名恢复出的地址
+}
+
+// messageHash 按 EIP-191（version 0x45）计算 personal_sign 哈希
+func messageHash(message, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "utf8":
+		return accounts.TextHash([]byte(message)), nil
+	case "hex":
+		data, err := hexutil.Decode(message)
+		if err != nil {
+			return nil, errors.New("message 不是有效的 0x 前缀 hex")
+		}
+		return accounts.TextHash(data), nil
+	default:
+		return nil, fmt.Errorf("不支持的编码 %q", encoding)
+	}
+}
+
+// typedDataHash 校验并计算 EIP-712 哈希
+func typedDataHash(typedData apitypes.TypedData) ([]byte, error) {
+	if typedData.PrimaryType == "" || len(typedData.Types) == 0 {
+		return nil, errors.New("typedData 缺少 types 或 primaryType")
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("typedData 无效: %v", err)
+	}
+	return hash, nil
+}
+
+// checkTypedDataPolicy 拒绝会授权他人动用资产的 EIP-712 消息（如 ERC-2612 / Permit2 授权），
+// 以及 domain 链 ID 与本服务不一致的消息
+func checkTypedDataPolicy(typedData apitypes.TypedData) error {
+	if strings.Contains(strings.ToLower(typedData.PrimaryType), "permit") {
+		return errors.New("不允许签名代币授权（Permit）类消息")
+	}
+	if chainID := typedData.Domain.ChainId; chainID != nil {
+		if (*big.Int)(chainID).Cmp(new(big.Int).SetUint64(config.GetChainID())) != 0 {
+			return errors.New("typedData 的 chainId 与当前网络不一致")
+		}
+	}
+	return nil
+}
+
+// signHash 使用托管私钥签名哈希，返回 v 为 27/28 的签名
+func (s *Server) signHash(w http.ResponseWriter, r *http.Request, password, account string, hash []byte) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+
+	privBytes, address, err := s.signingKey(r, user, password, account)
+	if err != nil {
+		respondSigningKeyError(w, err)
+		return
+	}
+	privateKey, err := crypto.ToECDSA(privBytes)
+	zeroBytes(privBytes)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "解析私钥失败")
+		return
+	}
+
+	sig, err := crypto.Sign(hash, privateKey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "签名失败")
+		return
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	respondSuccess(w, SignatureResponse{
+		Address:   common.HexToAddress(address).Hex(),
+		Hash:      hexutil.Encode(hash),
+		Signature: hexutil.Encode(sig),
+	})
+}
+
+// 使用托管私钥进行 personal_sign 签名
+func (s *Server) handleSignMessage(w http.ResponseWriter, r *http.Request) {
+	var req SignMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Message == "" {
+		respondError(w, http.StatusBadRequest, "message 不能为空")
+		return
+	}
+	hash, err := messageHash(req.Message, req.Encoding)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.signHash(w, r, req.Password, req.Account, hash)
+}
+
+// 使用托管私钥进行 EIP-712 签名
+func (s *Server) handleSignTypedData(w http.ResponseWriter, r *http.Request) {
+	var req SignTypedDataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if err := checkTypedDataPolicy(req.TypedData); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash, err := typedDataHash(req.TypedData)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.signHash(w, r, req.Password, req.Account, hash)
+}
+
+// 验证 personal_sign 或 EIP-712 签名（公开接口，仅支持普通外部账户）
+func (s *Server) handleVerifySignature(w http.ResponseWriter, r *http.Request) {
+	var req VerifySignatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !common.IsHexAddress(req.Address) {
+		respondError(w, http.StatusBadRequest, "无效的地址")
+		return
+	}
+	if (req.Message == nil) == (req.TypedData == nil) {
+		respondError(w, http.StatusBadRequest, "message 与 typedData 必须且只能提供一个")
+		return
+	}
+
+	var hash []byte
+	var err error
+	if req.Message != nil {
+		hash, err = messageHash(*req.Message, req.Encoding)
+	} else {
+		hash, err = typedDataHash(*req.TypedData)
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sig, err := hexutil.Decode(req.Signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		respondError(w, http.StatusBadRequest, "签名必须是 65 字节 hex")
+		return
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无法从签名恢复地址")
+		return
+	}
+
+	signer := crypto.PubkeyToAddress(*pub)
+	respondSuccess(w, VerifySignatureResponse{
+		Valid:  signer == common.HexToAddress(req.Address),
+		Signer: signer.Hex(),
+	})
+}
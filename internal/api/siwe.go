@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/config"
+)
+
+// SIWENonceResponse SIWE nonce 响应
+type SIWENonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	Domain    string    `json:"domain"`
+	ChainID   uint64    `json:"chainId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SIWEVerifyRequest SIWE 登录请求
+type SIWEVerifyRequest struct {
+	Message   string `json:"message"`   // EIP-4361 消息原文
+	Signature string `json:"signature"` // personal_sign 签名（hex）
+}
+
+// 获取 SIWE nonce
+func (s *Server) handleSIWENonce(w http.ResponseWriter, r *http.Request) {
+	if !s.allow(w, "siwe:nonce:ip:"+clientIP(r), siweIPRule) {
+		return
+	}
+	nonce, expiresAt, err := s.AuthService.NewSIWENonce()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成 nonce 失败")
+		return
+	}
+	respondSuccess(w, SIWENonceResponse{
+		Nonce:     nonce,
+		Domain:    s.SIWEDomain,
+		ChainID:   config.GetChainID(),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// SIWE 登录（首次登录自动创建自托管账户）
+func (s *Server) handleSIWEVerify(w http.ResponseWriter, r *http.Request) {
+	var req SIWEVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Message == "" || req.Signature == "" {
+		respondError(w, http.StatusBadRequest, "消息和签名不能为空")
+		return
+	}
+	if !s.allow(w, "siwe:ip:"+clientIP(r), siweIPRule) {
+		return
+	}
+	sig, err := hexutil.Decode(req.Signature)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "签名格式无效")
+		return
+	}
+
+	user, err := s.AuthService.LoginWithSIWE(req.Message, sig, s.SIWEDomain, config.GetChainID())
+	if err != nil {
+		if errors.Is(err, auth.ErrCustodialAddress) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tokens, err := s.createSession(r, user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成令牌失败")
+		return
+	}
+	respondSuccess(w, LoginResponse{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Address:       user.Address,
+		Token:         tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresIn:     tokens.ExpiresIn,
+		NonCustodial:  true,
+		EmailVerified: true,
+	})
+}
+
+// custodialOnly 拒绝自托管账户访问需要服务器私钥的接口
+func (s *Server) custodialOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if nonCustodial(r) {
+			respondError(w, http.StatusForbidden, auth.ErrNonCustodial.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+// nonCustodial 当前请求是否来自自托管账户
+func nonCustodial(r *http.Request) bool {
+	nc, _ := r.Context().Value(contextKeyNonCustodial).(bool)
+	return nc
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+	"lbtc/internal/ratelimit"
+)
+
+// 限流规则（滑动窗口，按 IP 与邮箱分别计数）
+var (
+	loginIPRule        = ratelimit.Rule{Limit: 30, Window: 5 * time.Minute}
+	loginEmailRule     = ratelimit.Rule{Limit: 20, Window: 15 * time.Minute}
+	registerIPRule     = ratelimit.Rule{Limit: 5, Window: time.Hour}
+	magicLinkIPRule    = ratelimit.Rule{Limit: 10, Window: time.Hour}
+	magicLinkEmailRule = ratelimit.Rule{Limit: 5, Window: time.Hour}
+	siweIPRule         = ratelimit.Rule{Limit: 30, Window: 5 * time.Minute}
+	passkeyIPRule      = ratelimit.Rule{Limit: 30, Window: 5 * time.Minute}
+	recoveryIPRule     = ratelimit.Rule{Limit: 5, Window: time.Hour}
+	recoveryEmailRule  = ratelimit.Rule{Limit: 3, Window: 24 * time.Hour}
+)
+
+// 连续登录失败策略：邮箱 3 次以内不延迟，之后 1s、2s、4s… 递增，10 次锁定 15 分钟（再次锁定时长翻倍）；
+// IP 的阈值更高，用于阻止同一来源轮换邮箱撞库
+var (
+	emailLockoutPolicy = ratelimit.LockoutPolicy{
+		FreeFailures:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockAfter:       10,
+		LockDuration:    15 * time.Minute,
+		MaxLockDuration: 24 * time.Hour,
+	}
+	ipLockoutPolicy = ratelimit.LockoutPolicy{
+		FreeFailures:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		LockAfter:       50,
+		LockDuration:    15 * time.Minute,
+		MaxLockDuration: 24 * time.Hour,
+	}
+)
+
+// 两步验证码连续错误策略（按用户计数，覆盖登录与所有敏感操作）：2 次以内不延迟，5 次锁定 15 分钟（再次锁定时长翻倍）
+var totpLockoutPolicy = ratelimit.LockoutPolicy{
+	FreeFailures:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockAfter:       5,
+	LockDuration:    15 * time.Minute,
+	MaxLockDuration: 24 * time.Hour,
+}
+
+// AuditEventInfo 审计事件
+type AuditEventInfo struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserID    *int64    `json:"userId,omitempty"`
+	Subject   string    `json:"subject"`
+	IP        string    `json:"ip"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func normalizeEmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginEmailKey(email string) string { return "login:email:" + normalizeEmailKey(email) }
+func loginIPKey(ip string) string       { return "login:ip:" + ip }
+func totpUserKey(userID int64) string   { return fmt.Sprintf("totp:user:%d", userID) }
+
+// respondLimited 限流或锁定时返回 429 与 Retry-After，其他错误返回 500
+func respondLimited(w http.ResponseWriter, err error) {
+	var limited *ratelimit.ErrLimited
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((limited.RetryAfter+time.Second-1)/time.Second), 10))
+		respondError(w, http.StatusTooManyRequests, limited.Error())
+		return
+	}
+	log.Printf("限流检查失败: %v", err)
+	respondError(w, http.StatusInternalServerError, "限流检查失败")
+}
+
+// allow 检查并记录一次限流计数，超限时返回 429
+func (s *Server) allow(w http.ResponseWriter, key string, rule ratelimit.Rule) bool {
+	if err := s.Limiter.Allow(key, rule); err != nil {
+		respondLimited(w, err)
+		return false
+	}
+	return true
+}
+
+// checkLoginLockout 在执行 Argon2 之前检查邮箱与 IP 是否处于延迟或锁定中
+func (s *Server) checkLoginLockout(w http.ResponseWriter, r *http.Request, email string) bool {
+	for _, key := range []string{loginEmailKey(email), loginIPKey(clientIP(r))} {
+		if err := s.Limiter.Check(key); err != nil {
+			respondLimited(w, err)
+			return false
+		}
+	}
+	return true
+}
+
+// loginFailed 记录一次登录失败（密码或两步验证码错误），触发锁定时写入审计事件
+func (s *Server) loginFailed(r *http.Request, email string) {
+	ip := clientIP(r)
+	for _, f := range []struct {
+		key    string
+		policy ratelimit.LockoutPolicy
+	}{
+		{loginEmailKey(email), emailLockoutPolicy},
+		{loginIPKey(ip), ipLockoutPolicy},
+	} {
+		locked, until, err := s.Limiter.Failure(f.key, f.policy)
+		if err != nil {
+			log.Printf("记录登录失败次数失败: %v", err)
+			continue
+		}
+		if !locked {
+			continue
+		}
+		event := audit.EventModel{
+			Type:    audit.EventLockout,
+			Subject: f.key,
+			IP:      ip,
+			Detail:  fmt.Sprintf("连续登录失败 %d 次，锁定至 %s", f.policy.LockAfter, until.UTC().Format(time.RFC3339)),
+		}
+		if f.key == loginEmailKey(email) {
+			if user, err := s.AuthService.GetByEmail(email); err == nil {
+				event.UserID = &user.ID
+			}
+		}
+		s.Audit.Record(event)
+	}
+}
+
+// loginSucceeded 登录成功后清除该邮箱的失败计数（IP 计数保留，避免用自己的账户重置撞库计数）
+func (s *Server) loginSucceeded(email string) {
+	if err := s.Limiter.Success(loginEmailKey(email)); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
+}
+
+// reauthenticate 敏感操作前校验当前用户的密码，与登录共用限流与失败锁定；返回 false 时已写入错误响应
+func (s *Server) reauthenticate(w http.ResponseWriter, r *http.Request, user *auth.User, password string) bool {
+	if !s.allow(w, loginIPKey(clientIP(r)), loginIPRule) || !s.allow(w, loginEmailKey(user.Email), loginEmailRule) {
+		return false
+	}
+	if !s.checkLoginLockout(w, r, user.Email) {
+		return false
+	}
+	if _, err := s.AuthService.Authenticate(user.Email, password); err != nil {
+		s.loginFailed(r, user.Email)
+		respondError(w, http.StatusUnauthorized, "密码错误")
+		return false
+	}
+	s.loginSucceeded(user.Email)
+	return true
+}
+
+// 列出审计事件（管理员）
+func (s *Server) handleListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			respondError(w, http.StatusBadRequest, "limit 必须在 1 到 1000 之间")
+			return
+		}
+		limit = n
+	}
+
+	events, err := s.Audit.List(r.URL.Query().Get("type"), limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询审计事件失败")
+		return
+	}
+	infos := make([]AuditEventInfo, 0, len(events))
+	for _, e := range events {
+		infos = append(infos, AuditEventInfo{
+			ID:        e.ID,
+			Type:      e.Type,
+			UserID:    e.UserID,
+			Subject:   e.Subject,
+			IP:        e.IP,
+			Detail:    e.Detail,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	respondSuccess(w, infos)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"lbtc/internal/audit"
+	"lbtc/internal/auth"
+)
+
+// TOTPSetupRequest 开始设置两步验证请求
+type TOTPSetupRequest struct {
+	Password string `json:"password"`
+}
+
+// TOTPSetupResponse 开始设置两步验证响应
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"` // base32 共享密钥，可手动输入验证器应用
+	URI    string `json:"uri"`    // otpauth:// 配置 URI，客户端渲染为二维码
+}
+
+// TOTPCodeRequest 携带验证码的请求
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPBackupCodesRequest 重新生成备用码请求
+type TOTPBackupCodesRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // 验证码或备用码
+}
+
+// TOTPDisableRequest 关闭两步验证请求
+type TOTPDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // 验证码或备用码
+}
+
+// TOTPPolicyRequest 更新两步验证策略请求
+type TOTPPolicyRequest struct {
+	Code              string `json:"code"`
+	RequireForLogin   bool   `json:"requireForLogin"`
+	TransferThreshold string `json:"transferThreshold"` // 代币最小单位，空表示所有转账都需要验证码
+}
+
+// TOTPStatusResponse 两步验证状态
+type TOTPStatusResponse struct {
+	Enabled              bool   `json:"enabled"`
+	RequireForLogin      bool   `json:"requireForLogin"`
+	TransferThreshold    string `json:"transferThreshold"`
+	BackupCodesRemaining int64  `json:"backupCodesRemaining"`
+}
+
+// BackupCodesResponse 备用码（仅返回一次）
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backupCodes"`
+}
+
+// requireTOTP 开启两步验证的用户必须提供有效的验证码或备用码；返回 false 时已写入错误响应
+func (s *Server) requireTOTP(w http.ResponseWriter, r *http.Request, userID int64, code string) bool {
+	enabled, err := s.AuthService.TOTPEnabled(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return false
+	}
+	if !enabled {
+		return true
+	}
+	return s.checkTOTP(w, r, userID, code)
+}
+
+// checkTOTP 校验验证码；连续错误按用户计数并延迟或锁定，返回 false 时已写入错误响应
+func (s *Server) checkTOTP(w http.ResponseWriter, r *http.Request, userID int64, code string) bool {
+	if code == "" {
+		respondTOTPRequired(w)
+		return false
+	}
+	key := totpUserKey(userID)
+	if err := s.Limiter.Check(key); err != nil {
+		respondLimited(w, err)
+		return false
+	}
+	if err := s.AuthService.VerifyTOTP(userID, code); err != nil {
+		if errors.Is(err, auth.ErrInvalidTOTP) || errors.Is(err, auth.ErrTOTPNotEnabled) {
+			s.totpFailed(r, userID)
+			respondError(w, http.StatusUnauthorized, auth.ErrInvalidTOTP.Error())
+			return false
+		}
+		respondError(w, http.StatusInternalServerError, "校验两步验证码失败")
+		return false
+	}
+	if err := s.Limiter.Success(key); err != nil {
+		log.Printf("清除两步验证失败次数失败: %v", err)
+	}
+	return true
+}
+
+// totpFailed 记录一次两步验证码错误，触发锁定时写入审计事件
+func (s *Server) totpFailed(r *http.Request, userID int64) {
+	key := totpUserKey(userID)
+	locked, until, err := s.Limiter.Failure(key, totpLockoutPolicy)
+	if err != nil {
+		log.Printf("记录两步验证失败次数失败: %v", err)
+		return
+	}
+	if !locked {
+		return
+	}
+	s.Audit.Record(audit.EventModel{
+		Type:    audit.EventLockout,
+		UserID:  &userID,
+		Subject: key,
+		IP:      clientIP(r),
+		Detail:  fmt.Sprintf("两步验证码连续错误 %d 次，锁定至 %s", totpLockoutPolicy.LockAfter, until.UTC().Format(time.RFC3339)),
+	})
+}
+
+func respondTOTPRequired(w http.ResponseWriter) {
+	respondJSON(w, http.StatusUnauthorized, Response{
+		Success: false,
+		Data:    map[string]interface{}{"totpRequired": true},
+		Error:   "需要两步验证码",
+	})
+}
+
+// 开始设置两步验证
+func (s *Server) handleTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TOTPSetupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+
+	secret, uri, err := s.AuthService.SetupTOTP(user)
+	if err != nil {
+		if errors.Is(err, auth.ErrTOTPAlreadyEnabled) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "设置两步验证失败")
+		return
+	}
+	respondSuccess(w, TOTPSetupResponse{Secret: secret, URI: uri})
+}
+
+// 确认并启用两步验证
+func (s *Server) handleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+
+	codes, err := s.AuthService.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTOTP):
+			respondError(w, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, auth.ErrTOTPNotEnabled):
+			respondError(w, http.StatusBadRequest, "请先调用 /api/auth/totp/setup")
+		case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+			respondError(w, http.StatusConflict, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, "启用两步验证失败")
+		}
+		return
+	}
+	respondSuccess(w, BackupCodesResponse{BackupCodes: codes})
+}
+
+// 查询两步验证状态
+func (s *Server) handleTOTPStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	status, err := s.AuthService.GetTOTPStatus(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return
+	}
+	respondSuccess(w, TOTPStatusResponse{
+		Enabled:              status.Enabled,
+		RequireForLogin:      status.RequireForLogin,
+		TransferThreshold:    status.TransferThreshold,
+		BackupCodesRemaining: status.BackupCodesRemaining,
+	})
+}
+
+// 更新两步验证策略
+func (s *Server) handleTOTPPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TOTPPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !s.checkTOTP(w, r, userID, req.Code) {
+		return
+	}
+	if err := s.AuthService.SetTOTPPolicy(userID, req.RequireForLogin, req.TransferThreshold); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.handleTOTPStatus(w, r)
+}
+
+// 重新生成备用码（需要密码和验证码）
+func (s *Server) handleTOTPBackupCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TOTPBackupCodesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+	if !s.checkTOTP(w, r, userID, req.Code) {
+		return
+	}
+	codes, err := s.AuthService.RegenerateBackupCodes(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成备用码失败")
+		return
+	}
+	respondSuccess(w, BackupCodesResponse{BackupCodes: codes})
+}
+
+// 关闭两步验证（需要密码和验证码）
+func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TOTPDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+	if !s.checkTOTP(w, r, userID, req.Code) {
+		return
+	}
+	if err := s.AuthService.DisableTOTP(userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "关闭两步验证失败")
+		return
+	}
+	respondSuccess(w, TOTPStatusResponse{})
+}
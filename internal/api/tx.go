@@ -0,0 +1,398 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/jobqueue"
+	"lbtc/internal/txtrack"
+)
+
+// UnsignedTx 未签名交易（字段格式与 eth_sendTransaction / eth_signTransaction 参数一致），
+// 供自托管用户在自己的钱包中签名
+type UnsignedTx struct {
+	Type                 string `json:"type"` // 0x2 = EIP-1559，0x0 = legacy
+	ChainID              string `json:"chainId"`
+	From                 string `json:"from"`
+	To                   string `json:"to"`
+	Nonce                string `json:"nonce"`
+	Gas                  string `json:"gas"`
+	GasPrice             string `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	Value                string `json:"value"`
+	Data                 string `json:"data"`
+}
+
+// UnsignedTxResponse 签名接口对自托管账户的响应
+type UnsignedTxResponse struct {
+	Status string      `json:"status"` // 固定为 "unsigned"
+	Tx     *UnsignedTx `json:"tx"`
+}
+
+// buildUnsignedTx 填好 nonce、Gas、费用和链 ID，生成调用合约的未签名交易
+// 链支持 EIP-1559 时 maxFeePerGas = 2 * baseFee + tip，否则使用 legacy gasPrice
+func (s *Server) buildUnsignedTx(ctx context.Context, from, to common.Address, data []byte) (*UnsignedTx, error) {
+	nonce, err := s.Client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("获取 nonce 失败: %v", err)
+	}
+	chainID, err := s.Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取链 ID 失败: %v", err)
+	}
+	gasLimit, err := s.Client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("估算 Gas 失败: %v", err)
+	}
+
+	tx := &UnsignedTx{
+		ChainID: hexutil.EncodeBig(chainID),
+		From:    from.Hex(),
+		To:      to.Hex(),
+		Nonce:   hexutil.EncodeUint64(nonce),
+		Gas:     hexutil.EncodeUint64(gasLimit),
+		Value:   "0x0",
+		Data:    hexutil.Encode(data),
+	}
+
+	head, err := s.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取最新区块失败: %v", err)
+	}
+	if head.BaseFee == nil {
+		gasPrice, err := s.Client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取 Gas 价格失败: %v", err)
+		}
+		tx.Type = "0x0"
+		tx.GasPrice = hexutil.EncodeBig(gasPrice)
+		return tx, nil
+	}
+
+	tip, err := s.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Gas 小费失败: %v", err)
+	}
+	maxFee := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	tx.Type = "0x2"
+	tx.MaxFeePerGas = hexutil.EncodeBig(maxFee)
+	tx.MaxPriorityFeePerGas = hexutil.EncodeBig(tip)
+	return tx, nil
+}
+
+// BuildTxRequest 构建未签名交易请求
+type BuildTxRequest struct {
+	Account string `json:"account,omitempty"` // 可选，子账户标签，默认主账户
+	To      string `json:"to,omitempty"`      // transfer 的接收地址
+	Spender string `json:"spender,omitempty"` // approve 的被授权地址
+	Amount  string `json:"amount,omitempty"`  // 代币数量（最小单位）
+}
+
+// BroadcastTxRequest 广播已签名交易请求
+type BroadcastTxRequest struct {
+	RawTx string `json:"rawTx"` // 已签名交易的 RLP 编码（hex）
+}
+
+// TrackedTx 被跟踪的交易
+type TrackedTx struct {
+	TxHash      string    `json:"txHash"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Method      string    `json:"method"`
+	Nonce       uint64    `json:"nonce"`
+	Status      string    `json:"status"`
+	BlockNumber uint64    `json:"blockNumber,omitempty"`
+	GasUsed     uint64    `json:"gasUsed,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// broadcastMethods 允许通过 /api/tx/broadcast 提交的 QXB 合约方法
+var broadcastMethods = map[string]bool{
+	"transfer":         true,
+	"approve":          true,
+	"burn":             true,
+	"claimDailyReward": true,
+}
+
+// unsignedCall 打包 QXB 合约调用并构建未签名交易；只读取链上状态，不补充 ETH
+func (s *Server) unsignedCall(ctx context.Context, from common.Address, method string, args ...interface{}) (*UnsignedTx, error) {
+	data, err := s.Contract.ABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("打包调用失败: %v", err)
+	}
+	return s.buildUnsignedTx(ctx, from, s.ContractAddress, data)
+}
+
+// respondUnsignedCall 为自托管用户的签名请求返回未签名交易
+func (s *Server) respondUnsignedCall(w http.ResponseWriter, r *http.Request, userID int64, method string, args ...interface{}) {
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	tx, err := s.unsignedCall(r.Context(), common.HexToAddress(user.Address), method, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondSuccess(w, UnsignedTxResponse{Status: "unsigned", Tx: tx})
+}
+
+// parseAmount 解析十进制代币数量
+func parseAmount(v string, allowZero bool) (*big.Int, bool) {
+	amount, ok := new(big.Int).SetString(v, 10)
+	if !ok || amount.Sign() < 0 || (!allowZero && amount.Sign() == 0) {
+		return nil, false
+	}
+	return amount, true
+}
+
+// parseRecipient 解析非零地址
+func parseRecipient(v string) (common.Address, bool) {
+	if !common.IsHexAddress(v) {
+		return common.Address{}, false
+	}
+	addr := common.HexToAddress(v)
+	return addr, addr != (common.Address{})
+}
+
+// 构建未签名交易：transfer / approve / burn / claim
+func (s *Server) handleBuildTx(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	action := mux.Vars(r)["action"]
+
+	var req BuildTxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	address, err := s.AuthService.AccountAddress(user, req.Account)
+	if err != nil {
+		if errors.Is(err, auth.ErrSubAccountNotFound) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "查询子账户失败")
+		return
+	}
+	from := common.HexToAddress(address)
+
+	var method string
+	var args []interface{}
+	switch action {
+	case "transfer":
+		to, ok := parseRecipient(req.To)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "无效的接收地址")
+			return
+		}
+		if to == from {
+			respondError(w, http.StatusBadRequest, "不能转账给自己")
+			return
+		}
+		amount, ok := parseAmount(req.Amount, false)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "无效的金额格式")
+			return
+		}
+		method, args = "transfer", []interface{}{to, amount}
+	case "approve":
+		spender, ok := parseRecipient(req.Spender)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "无效的授权地址")
+			return
+		}
+		amount, ok := parseAmount(req.Amount, true)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "无效的金额格式")
+			return
+		}
+		method, args = "approve", []interface{}{spender, amount}
+	case "burn":
+		amount, ok := parseAmount(req.Amount, false)
+		if !ok {
+			respondError(w, http.StatusBadRequest, "无效的金额格式")
+			return
+		}
+		method, args = "burn", []interface{}{amount}
+	case "claim":
+		method = "claimDailyReward"
+	default:
+		respondError(w, http.StatusNotFound, "不支持的交易类型")
+		return
+	}
+
+	tx, err := s.unsignedCall(r.Context(), from, method, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondSuccess(w, UnsignedTxResponse{Status: "unsigned", Tx: tx})
+}
+
+// 广播已签名交易：只接受用户自己的地址发往 QXB 合约的调用，并加入收据跟踪
+func (s *Server) handleBroadcastTx(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req BroadcastTxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	raw, err := hexutil.Decode(req.RawTx)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的交易编码")
+		return
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("解析交易失败: %v", err))
+		return
+	}
+
+	ctx := r.Context()
+	chainID, err := s.Client.ChainID(ctx)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("获取链 ID 失败: %v", err))
+		return
+	}
+	if !tx.Protected() || tx.ChainId().Cmp(chainID) != 0 {
+		respondError(w, http.StatusBadRequest, "交易链 ID 不匹配")
+		return
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "交易签名无效")
+		return
+	}
+	if tx.To() == nil || *tx.To() != s.ContractAddress {
+		respondError(w, http.StatusBadRequest, "交易目标必须是 QXB 合约")
+		return
+	}
+	if tx.Value().Sign() != 0 {
+		respondError(w, http.StatusBadRequest, "交易不能附带 ETH")
+		return
+	}
+	if len(tx.Data()) < 4 {
+		respondError(w, http.StatusBadRequest, "不支持的合约调用")
+		return
+	}
+	method, err := s.Contract.ABI.MethodById(tx.Data()[:4])
+	if err != nil || !broadcastMethods[method.Name] {
+		respondError(w, http.StatusBadRequest, "不支持的合约调用")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	owned, err := s.AuthService.OwnsAddress(user, from.Hex())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询账户失败")
+		return
+	}
+	if !owned {
+		respondError(w, http.StatusForbidden, "交易发送方不是当前用户的地址")
+		return
+	}
+
+	// 放入发送队列（同一地址按 nonce 顺序广播），重复提交同一交易返回已有任务
+	job, err := s.enqueueTx(tx, from, userID, method.Name, 0)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	job, err = s.waitTxJob(ctx, job.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询发送任务失败")
+		return
+	}
+	switch job.Status {
+	case jobqueue.StatusSucceeded:
+	case jobqueue.StatusDead, jobqueue.StatusCanceled:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("发送交易失败: %s", job.LastError))
+		return
+	default:
+		respondJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Data:    ClaimResponse{TxHash: tx.Hash().Hex(), Status: "queued", JobID: job.ID},
+		})
+		return
+	}
+	tracked, err := s.TxTracker.Get(userID, tx.Hash().Hex())
+	if err != nil {
+		// 交易已发出，跟踪失败不影响结果
+		respondSuccess(w, ClaimResponse{TxHash: tx.Hash().Hex(), Status: txtrack.StatusPending})
+		return
+	}
+	respondSuccess(w, trackedTxInfo(tracked))
+}
+
+// 查询已广播交易的状态
+func (s *Server) handleGetTx(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	tracked, err := s.TxTracker.Get(userID, mux.Vars(r)["hash"])
+	if err != nil {
+		if errors.Is(err, txtrack.ErrNotFound) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "查询交易失败")
+		return
+	}
+	respondSuccess(w, trackedTxInfo(tracked))
+}
+
+// 列出最近广播的交易
+func (s *Server) handleListTxs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	txs, err := s.TxTracker.List(userID, 50)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询交易失败")
+		return
+	}
+	infos := make([]TrackedTx, 0, len(txs))
+	for i := range txs {
+		infos = append(infos, trackedTxInfo(&txs[i]))
+	}
+	respondSuccess(w, infos)
+}
+
+func trackedTxInfo(m *txtrack.TxModel) TrackedTx {
+	return TrackedTx{
+		TxHash:      m.Hash,
+		From:        m.From,
+		To:          m.To,
+		Method:      m.Method,
+		Nonce:       m.Nonce,
+		Status:      m.Status,
+		BlockNumber: m.BlockNumber,
+		GasUsed:     m.GasUsed,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+}
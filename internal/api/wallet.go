@@ -0,0 +1,503 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/config"
+	"lbtc/internal/unlock"
+)
+
+// 钱包解锁会话的最长有效期
+const maxWalletUnlockTTL = 30 * time.Minute
+
+// 导入 keystore 时允许的 KDF 参数，防止恶意文件消耗过多内存/CPU；r、p、dklen 只接受标准值
+const (
+	maxKeystoreScryptN = keystore.StandardScryptN
+	maxKeystorePBKDF2C = 1 << 20
+	keystoreScryptR    = 8
+	keystoreScryptP    = 1
+	keystoreDKLen      = 32
+)
+
+// ExportKeystoreRequest 导出 keystore 请求
+type ExportKeystoreRequest struct {
+	Password   string `json:"password"`           // 账户密码，用于解密存储的私钥
+	Passphrase string `json:"passphrase"`         // keystore 文件的加密口令（由用户自选）
+	TOTPCode   string `json:"totpCode,omitempty"` // 开启两步验证时必填
+}
+
+// ExportKeystoreResponse 导出 keystore 响应
+type ExportKeystoreResponse struct {
+	Address  string          `json:"address"`
+	Keystore json.RawMessage `json:"keystore"` // Web3 Secret Storage（keystore v3）JSON
+}
+
+// RegisterImportRequest 导入已有私钥注册请求（keystore 与 privateKey 二选一）
+type RegisterImportRequest struct {
+	Email            string          `json:"email"`
+	Password         string          `json:"password"`
+	Keystore         json.RawMessage `json:"keystore,omitempty"`         // keystore v3 JSON（对象或字符串）
+	KeystorePassword string          `json:"keystorePassword,omitempty"` // keystore 口令
+	PrivateKey       string          `json:"privateKey,omitempty"`       // 原始私钥（hex）
+	Address          string          `json:"address,omitempty"`          // 可选，期望的地址，用于校验
+}
+
+// UnlockRequest 解锁钱包请求
+type UnlockRequest struct {
+	Password   string `json:"password"`
+	TTLSeconds int    `json:"ttlSeconds,omitempty"` // 可选，默认 WALLET_UNLOCK_TTL，最长 30 分钟
+}
+
+// UnlockStatus 钱包解锁状态
+type UnlockStatus struct {
+	Unlocked  bool   `json:"unlocked"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// SubAccountInfo 子账户信息
+type SubAccountInfo struct {
+	Label   string  `json:"label"`
+	Address string  `json:"address"`
+	Index   *uint32 `json:"index,omitempty"` // BIP-44 地址索引，主账户为空
+	Path    string  `json:"path,omitempty"`
+}
+
+// CreateSubAccountRequest 创建子账户请求
+type CreateSubAccountRequest struct {
+	Password string `json:"password"`
+	Label    string `json:"label"`
+}
+
+// AccountBalanceInfo 账户余额信息
+type AccountBalanceInfo struct {
+	Account string `json:"account"`
+	BalanceInfo
+}
+
+func subAccountInfo(sub *auth.SubAccountModel) SubAccountInfo {
+	index := sub.Index
+	return SubAccountInfo{
+		Label:   sub.Label,
+		Address: sub.Address,
+		Index:   &index,
+		Path:    auth.SubAccountPath(sub.Index),
+	}
+}
+
+// 列出账户（主账户 + HD 子账户）
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	subAccounts, err := s.AuthService.ListSubAccounts(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询子账户失败")
+		return
+	}
+
+	infos := []SubAccountInfo{{Label: auth.DefaultAccount, Address: user.Address}}
+	for i := range subAccounts {
+		infos = append(infos, subAccountInfo(&subAccounts[i]))
+	}
+	respondSuccess(w, infos)
+}
+
+// 创建子账户
+func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req CreateSubAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Password == "" || req.Label == "" {
+		respondError(w, http.StatusBadRequest, "密码和标签不能为空")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+
+	sub, err := s.AuthService.CreateSubAccount(user, req.Password, req.Label)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("创建子账户失败: %v", err))
+		return
+	}
+	respondSuccess(w, subAccountInfo(sub))
+}
+
+// 查询账户余额（?account=子账户标签，默认主账户）
+func (s *Server) handleAccountBalance(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	account := r.URL.Query().Get("account")
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	address, err := s.AuthService.AccountAddress(user, account)
+	if err != nil {
+		if errors.Is(err, auth.ErrSubAccountNotFound) {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "查询子账户失败")
+		return
+	}
+
+	info, err := s.balanceInfo(r.Context(), address)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("查询余额失败: %v", err))
+		return
+	}
+	if auth.IsDefaultAccount(account) {
+		account = auth.DefaultAccount
+	}
+	respondSuccess(w, AccountBalanceInfo{Account: account, BalanceInfo: *info})
+}
+
+// 导出 keystore v3
+func (s *Server) handleExportKeystore(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req ExportKeystoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Password == "" || req.Passphrase == "" {
+		respondError(w, http.StatusBadRequest, "密码和导出口令不能为空")
+		return
+	}
+	if len(req.Passphrase) < 8 {
+		respondError(w, http.StatusBadRequest, "导出口令至少 8 位")
+		return
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) || !s.requireTOTP(w, r, userID, req.TOTPCode) {
+		return
+	}
+
+	privBytes, err := s.AuthService.DecryptPrivateKey(user, req.Password)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "密码错误或解密失败")
+		return
+	}
+	privateKey, err := crypto.ToECDSA(privBytes)
+	zeroBytes(privBytes)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "解析私钥失败")
+		return
+	}
+
+	key := &keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		PrivateKey: privateKey,
+	}
+	keyJSON, err := keystore.EncryptKey(key, req.Passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成 keystore 失败")
+		return
+	}
+
+	respondSuccess(w, ExportKeystoreResponse{
+		Address:  key.Address.Hex(),
+		Keystore: keyJSON,
+	})
+}
+
+// 使用已有私钥（keystore 或原始私钥）注册
+func (s *Server) handleRegisterImport(w http.ResponseWriter, r *http.Request) {
+	var req RegisterImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Email == "" || req.Password == "" {
+		respondError(w, http.StatusBadRequest, "邮箱和密码不能为空")
+		return
+	}
+	if err := auth.ValidateEmail(req.Email); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.allow(w, "register:ip:"+clientIP(r), registerIPRule) {
+		return
+	}
+
+	var privBytes []byte
+	var expected string
+	switch {
+	case len(req.Keystore) > 0:
+		keyJSON, addr, err := parseKeystoreJSON(req.Keystore)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var key *keystore.Key
+		auth.WithKDFSlot(func() {
+			key, err = keystore.DecryptKey(keyJSON, req.KeystorePassword)
+		})
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "keystore 口令错误或文件无效")
+			return
+		}
+		privBytes = crypto.FromECDSA(key.PrivateKey)
+		expected = addr
+	case req.PrivateKey != "":
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(req.PrivateKey, "0x"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "无效的私钥")
+			return
+		}
+		privBytes = crypto.FromECDSA(key)
+	default:
+		respondError(w, http.StatusBadRequest, "必须提供 keystore 或私钥")
+		return
+	}
+	defer zeroBytes(privBytes)
+
+	key, err := crypto.ToECDSA(privBytes)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的私钥")
+		return
+	}
+	derived := crypto.PubkeyToAddress(key.PublicKey)
+	for _, want := range []string{expected, req.Address} {
+		if want == "" {
+			continue
+		}
+		if !common.IsHexAddress(want) || common.HexToAddress(want) != derived {
+			respondError(w, http.StatusBadRequest, "私钥与地址不匹配")
+			return
+		}
+	}
+
+	user, err := s.AuthService.RegisterWithKey(req.Email, req.Password, privBytes)
+	if err != nil {
+		if errors.Is(err, auth.ErrAddressTaken) {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			respondError(w, http.StatusConflict, "邮箱已被注册")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("注册失败: %v", err))
+		return
+	}
+	s.recordRegistration(r, user)
+
+	tokens, err := s.createSession(r, user)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成令牌失败")
+		return
+	}
+	go s.sendVerificationEmail(user)
+
+	respondSuccess(w, RegisterResponse{
+		UserID:       user.ID,
+		Email:        user.Email,
+		Address:      user.Address,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// parseKeystoreJSON 解析 keystore（支持 JSON 对象或 JSON 字符串），校验 KDF 参数并返回其中记录的地址
+func parseKeystoreJSON(raw json.RawMessage) ([]byte, string, error) {
+	keyJSON := []byte(raw)
+	var asString string
+	if err := json.Unmarshal(raw, &asString); err == nil {
+		keyJSON = []byte(asString)
+	}
+
+	var header struct {
+		Address string `json:"address"`
+		Version int    `json:"version"`
+		Crypto  struct {
+			KDF       string `json:"kdf"`
+			KDFParams struct {
+				N     int `json:"n"`
+				R     int `json:"r"`
+				P     int `json:"p"`
+				C     int `json:"c"`
+				DKLen int `json:"dklen"`
+			} `json:"kdfparams"`
+		} `json:"crypto"`
+	}
+	if err := json.Unmarshal(keyJSON, &header); err != nil {
+		return nil, "", errors.New("keystore 格式无效")
+	}
+	if header.Version != 3 {
+		return nil, "", errors.New("仅支持 keystore v3")
+	}
+	params := header.Crypto.KDFParams
+	if params.DKLen != keystoreDKLen {
+		return nil, "", errors.New("keystore dklen 参数无效")
+	}
+	switch header.Crypto.KDF {
+	case "scrypt":
+		if params.N > maxKeystoreScryptN {
+			return nil, "", errors.New("keystore scrypt 参数过大")
+		}
+		if params.R != keystoreScryptR || params.P != keystoreScryptP {
+			return nil, "", errors.New("keystore scrypt 参数无效")
+		}
+	case "pbkdf2":
+		if params.C > maxKeystorePBKDF2C {
+			return nil, "", errors.New("keystore pbkdf2 参数过大")
+		}
+	default:
+		return nil, "", errors.New("不支持的 keystore KDF")
+	}
+
+	addr := header.Address
+	if addr != "" && !strings.HasPrefix(addr, "0x") {
+		addr = "0x" + addr
+	}
+	return keyJSON, addr, nil
+}
+
+// signingKey 获取账户选择器对应的签名私钥：提供密码时解密存储的私钥，否则使用当前 JWT 的解锁会话
+func (s *Server) signingKey(r *http.Request, user *auth.User, password, account string) ([]byte, string, error) {
+	if user.NonCustodial {
+		return nil, "", auth.ErrNonCustodial
+	}
+	if password != "" {
+		return s.AuthService.AccountKey(user, password, account)
+	}
+	sessionID, _ := r.Context().Value(contextKeySessionID).(string)
+	secret, err := s.Unlocked.Get(user.ID, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer zeroBytes(secret)
+	return s.AuthService.SubAccountKey(user, secret, account)
+}
+
+// respondSigningKeyError 将 signingKey 的错误转换为响应
+func respondSigningKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrSubAccountNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, unlock.ErrLocked):
+		respondError(w, http.StatusUnauthorized, "需要密码或先解锁钱包")
+	case errors.Is(err, auth.ErrNonCustodial):
+		respondError(w, http.StatusForbidden, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, "密码错误或解密失败")
+	}
+}
+
+// 解锁钱包：解密一次私钥并在内存中保留一段时间，后续签名请求无需再提供密码
+func (s *Server) handleWalletUnlock(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.Password == "" {
+		respondError(w, http.StatusBadRequest, "密码不能为空")
+		return
+	}
+
+	ttl := config.GetWalletUnlockTTL()
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > maxWalletUnlockTTL {
+		ttl = maxWalletUnlockTTL
+	}
+
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if !s.reauthenticate(w, r, user, req.Password) {
+		return
+	}
+	// 会话保存主私钥与助记词熵，子账户签名也无需再输入密码
+	secret, err := s.AuthService.WalletSecret(user, req.Password)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "密码错误或解密失败")
+		return
+	}
+	defer zeroBytes(secret)
+
+	expiresAt, err := s.Unlocked.Unlock(userID, sessionID, secret, ttl)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "解锁钱包失败")
+		return
+	}
+	respondSuccess(w, UnlockStatus{
+		Unlocked:  true,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// 锁定钱包：立即清除当前登录会话的解锁会话
+func (s *Server) handleWalletLock(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	s.Unlocked.Lock(userID, sessionID)
+	respondSuccess(w, UnlockStatus{Unlocked: false})
+}
+
+// 查询钱包解锁状态
+func (s *Server) handleWalletSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+	sessionID := r.Context().Value(contextKeySessionID).(string)
+
+	expiresAt, ok := s.Unlocked.Status(userID, sessionID)
+	if !ok {
+		respondSuccess(w, UnlockStatus{Unlocked: false})
+		return
+	}
+	respondSuccess(w, UnlockStatus{
+		Unlocked:  true,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// zeroBytes 清零敏感数据
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+
+	"lbtc/internal/walletlink"
+)
+
+// LinkChallengeRequest 获取绑定挑战请求
+type LinkChallengeRequest struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+}
+
+// LinkChallengeResponse 绑定挑战
+type LinkChallengeResponse struct {
+	Message   string    `json:"message"` // 使用外部钱包 personal_sign 签名的原文
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// LinkWalletRequest 绑定外部钱包请求
+type LinkWalletRequest struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+	Password  string `json:"password,omitempty"` // 未开启两步验证时必填
+	TOTPCode  string `json:"totpCode,omitempty"` // 开启两步验证时必填
+}
+
+// UnlinkWalletRequest 解除绑定请求
+type UnlinkWalletRequest struct {
+	Password string `json:"password,omitempty"` // 未开启两步验证时必填
+	TOTPCode string `json:"totpCode,omitempty"` // 开启两步验证时必填
+}
+
+// LinkedWalletInfo 已绑定的外部钱包
+type LinkedWalletInfo struct {
+	ID       int64     `json:"id"`
+	Address  string    `json:"address"`
+	Label    string    `json:"label"`
+	LinkedAt time.Time `json:"linkedAt"`
+	UsableAt time.Time `json:"usableAt"` // 冷静期结束、可以提现的时间
+}
+
+// WithdrawPolicyInfo 提现冷静期策略
+type WithdrawPolicyInfo struct {
+	CoolingOffSeconds        int64      `json:"coolingOffSeconds"`
+	PendingCoolingOffSeconds *int64     `json:"pendingCoolingOffSeconds,omitempty"`
+	PendingEffectiveAt       *time.Time `json:"pendingEffectiveAt,omitempty"`
+}
+
+// LinkedWalletsResponse 绑定列表
+type LinkedWalletsResponse struct {
+	Policy  WithdrawPolicyInfo `json:"policy"`
+	Wallets []LinkedWalletInfo `json:"wallets"`
+}
+
+// WithdrawPolicyRequest 修改冷静期请求
+type WithdrawPolicyRequest struct {
+	CoolingOffSeconds int64  `json:"coolingOffSeconds"`
+	Password          string `json:"password,omitempty"` // 未开启两步验证时必填
+	TOTPCode          string `json:"totpCode,omitempty"` // 开启两步验证时必填
+}
+
+func policyInfo(p *walletlink.Policy) WithdrawPolicyInfo {
+	info := WithdrawPolicyInfo{CoolingOffSeconds: int64(p.CoolingOff / time.Second)}
+	if p.PendingCoolingOff != nil {
+		pending := int64(*p.PendingCoolingOff / time.Second)
+		info.PendingCoolingOffSeconds = &pending
+		info.PendingEffectiveAt = p.PendingEffectAt
+	}
+	return info
+}
+
+// confirmWithdrawChange 修改提现白名单前的二次验证：开启两步验证时校验验证码，否则校验密码；
+// 返回 false 时已写入错误响应
+func (s *Server) confirmWithdrawChange(w http.ResponseWriter, r *http.Request, userID int64, password, totpCode string) bool {
+	enabled, err := s.AuthService.TOTPEnabled(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询两步验证状态失败")
+		return false
+	}
+	if enabled {
+		return s.checkTOTP(w, r, userID, totpCode)
+	}
+	if password == "" {
+		respondError(w, http.StatusUnauthorized, "需要密码")
+		return false
+	}
+	user, err := s.AuthService.GetByID(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户信息失败")
+		return false
+	}
+	return s.reauthenticate(w, r, user, password)
+}
+
+// checkWithdrawAddress 校验接收地址已绑定且已过冷静期；onlyRestricted 为 true 时只对已启用白名单的用户校验，
+// 返回 false 时已写入错误响应
+func (s *Server) checkWithdrawAddress(w http.ResponseWriter, userID int64, to common.Address, onlyRestricted bool) bool {
+	if onlyRestricted {
+		restricted, err := s.WalletLinks.Restricted(userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "查询提现策略失败")
+			return false
+		}
+		if !restricted {
+			return true
+		}
+	}
+	if err := s.WalletLinks.CheckWithdraw(userID, to); err != nil {
+		if errors.Is(err, walletlink.ErrNotLinked) || errors.Is(err, walletlink.ErrCoolingOff) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return false
+		}
+		respondError(w, http.StatusInternalServerError, "检查提现地址失败")
+		return false
+	}
+	return true
+}
+
+// 获取绑定挑战
+func (s *Server) handleLinkChallenge(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req LinkChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	address, ok := parseRecipient(req.Address)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的地址")
+		return
+	}
+
+	challenge, err := s.WalletLinks.NewChallenge(userID, address, req.Label)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成绑定挑战失败")
+		return
+	}
+	respondSuccess(w, LinkChallengeResponse{Message: challenge.Message, ExpiresAt: challenge.ExpiresAt})
+}
+
+// 提交签名，绑定外部钱包（需要密码或两步验证码）
+func (s *Server) handleLinkWallet(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req LinkWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	address, ok := parseRecipient(req.Address)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "无效的地址")
+		return
+	}
+	sig, err := hexutil.Decode(req.Signature)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "签名格式无效")
+		return
+	}
+	if !s.confirmWithdrawChange(w, r, userID, req.Password, req.TOTPCode) {
+		return
+	}
+
+	linked, err := s.WalletLinks.Link(userID, address, sig)
+	if err != nil {
+		if errors.Is(err, walletlink.ErrChallengeNotFound) || errors.Is(err, walletlink.ErrInvalidSignature) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "绑定外部钱包失败")
+		return
+	}
+	policy, err := s.WalletLinks.GetPolicy(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询提现策略失败")
+		return
+	}
+	respondSuccess(w, LinkedWalletInfo{
+		ID:       linked.ID,
+		Address:  linked.Address,
+		Label:    linked.Label,
+		LinkedAt: linked.LinkedAt,
+		UsableAt: s.WalletLinks.UsableAt(linked, policy),
+	})
+}
+
+// 列出绑定的外部钱包和冷静期策略
+func (s *Server) handleListLinkedWallets(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	wallets, err := s.WalletLinks.List(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询绑定钱包失败")
+		return
+	}
+	policy, err := s.WalletLinks.GetPolicy(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询提现策略失败")
+		return
+	}
+
+	infos := make([]LinkedWalletInfo, 0, len(wallets))
+	for i := range wallets {
+		infos = append(infos, LinkedWalletInfo{
+			ID:       wallets[i].ID,
+			Address:  wallets[i].Address,
+			Label:    wallets[i].Label,
+			LinkedAt: wallets[i].LinkedAt,
+			UsableAt: s.WalletLinks.UsableAt(&wallets[i], policy),
+		})
+	}
+	respondSuccess(w, LinkedWalletsResponse{Policy: policyInfo(policy), Wallets: infos})
+}
+
+// 解除绑定（需要密码或两步验证码）
+func (s *Server) handleUnlinkWallet(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的绑定 ID")
+		return
+	}
+	var req UnlinkWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !s.confirmWithdrawChange(w, r, userID, req.Password, req.TOTPCode) {
+		return
+	}
+	if err := s.WalletLinks.Unlink(userID, id); err != nil {
+		if errors.Is(err, walletlink.ErrNotLinked) {
+			respondError(w, http.StatusNotFound, "绑定不存在")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "解除绑定失败")
+		return
+	}
+	respondSuccess(w, map[string]interface{}{"unlinked": true})
+}
+
+// 修改提现冷静期（延长立即生效，缩短在当前冷静期结束后生效）
+func (s *Server) handleWithdrawPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req WithdrawPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if req.CoolingOffSeconds < 0 {
+		respondError(w, http.StatusBadRequest, "冷静期不能为负数")
+		return
+	}
+	if !s.confirmWithdrawChange(w, r, userID, req.Password, req.TOTPCode) {
+		return
+	}
+
+	policy, err := s.WalletLinks.SetCoolingOff(userID, time.Duration(req.CoolingOffSeconds)*time.Second)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondSuccess(w, policyInfo(policy))
+}
+
+// 提现到已绑定且已过冷静期的外部钱包（其余校验与转账相同）
+func (s *Server) handleWithdraw(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(contextKeyUserID).(int64)
+
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体")
+		return
+	}
+	if !common.IsHexAddress(req.To) {
+		respondError(w, http.StatusBadRequest, "无效的接收地址")
+		return
+	}
+	if !s.checkWithdrawAddress(w, userID, common.HexToAddress(req.To), false) {
+		return
+	}
+	s.transfer(w, r, userID, req)
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计事件类型
+const (
+	EventLockout    = "auth.lockout"     // 连续失败触发临时锁定
+	EventJobAdmin   = "admin.job"        // 管理员重试或取消链上交易任务
+	EventAutoClaim  = "reward.autoclaim" // 开启、暂停、恢复或撤销自动领取
+	EventRiskReview = "risk.review"      // 管理员审核风控标记的账户
+	EventRecovery   = "escrow.recovery"  // 发起、取消或完成私钥恢复
+	EventBonusAdmin = "admin.bonus"      // 管理员重试发送失败的连续领取奖金
+)
+
+// EventModel GORM 审计事件模型
+type EventModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Type      string    `gorm:"index;not null;column:type"`
+	UserID    *int64    `gorm:"index;column:user_id"`
+	Subject   string    `gorm:"column:subject"` // 事件对象，如邮箱或 IP
+	IP        string    `gorm:"column:ip"`
+	Detail    string    `gorm:"column:detail"`
+	CreatedAt time.Time `gorm:"index;not null;column:created_at"`
+}
+
+// TableName 指定表名
+func (EventModel) TableName() string {
+	return "audit_events"
+}
+
+// Logger 审计日志：写入数据库并同时打印到日志
+type Logger struct {
+	db *gorm.DB
+}
+
+// NewLogger 创建审计日志并初始化表结构
+func NewLogger(db *gorm.DB) (*Logger, error) {
+	if err := db.AutoMigrate(&EventModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Logger{db: db}, nil
+}
+
+// Record 记录审计事件，写库失败只打印日志
+func (l *Logger) Record(event EventModel) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	log.Printf("[audit] %s subject=%s ip=%s %s", event.Type, event.Subject, event.IP, event.Detail)
+	if err := l.db.Create(&event).Error; err != nil {
+		log.Printf("写入审计事件失败: %v", err)
+	}
+}
+
+// List 按时间倒序列出审计事件，eventType 为空时不过滤
+func (l *Logger) List(eventType string, limit int) ([]EventModel, error) {
+	q := l.db.Order("id DESC").Limit(limit)
+	if eventType != "" {
+		q = q.Where("type = ?", eventType)
+	}
+	var events []EventModel
+	err := q.Find(&events).Error
+	return events, err
+}
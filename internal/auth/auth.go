@@ -3,32 +3,52 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrAddressTaken 地址已被其他账户使用
+	ErrAddressTaken = errors.New("该地址已被其他账户使用")
+	// ErrNonCustodial 自托管（SIWE）账户，服务器没有私钥
+	ErrNonCustodial = errors.New("自托管账户不支持此操作，请使用自己的钱包签名")
+)
+
 // User 用户模型（兼容旧代码）
 type User struct {
-	ID            int64
-	Email         string
-	Address       string
-	EncPrivKeyB64 string
-	EncSaltB64    string
-	PassSaltB64   string
-	PasswordHash  string
-	CreatedAt     time.Time
+	ID              int64
+	Email           string
+	Address         string
+	EncPrivKeyB64   string
+	EncSaltB64      string
+	PassSaltB64     string
+	PasswordHash    string
+	EncSeedB64      string
+	EncSeedSalt     string
+	NonCustodial    bool
+	EmailVerifiedAt *time.Time
+	CreatedAt       time.Time
 }
 
 // Service 负责用户注册/登录以及密钥管理
 type Service struct {
 	db *gorm.DB
+
+	AccessTokenTTL  time.Duration // access token 有效期
+	RefreshTokenTTL time.Duration // 会话（刷新令牌）有效期
 }
 
 // NewService 创建服务并初始化表结构
 func NewService(db *gorm.DB) (*Service, error) {
-	s := &Service{db: db}
+	s := &Service{
+		db:              db,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+	}
 	if err := s.initSchema(); err != nil {
 		return nil, err
 	}
@@ -37,23 +57,85 @@ func NewService(db *gorm.DB) (*Service, error) {
 
 func (s *Service) initSchema() error {
 	// 使用 GORM AutoMigrate 自动创建表
-	if err := s.db.AutoMigrate(&UserModel{}, &ClaimLockModel{}); err != nil {
+	if err := s.migrateClaimLocks(); err != nil {
+		return fmt.Errorf("迁移领取锁表失败: %w", err)
+	}
+	// 邮箱验证上线前注册的用户视为已验证
+	m := s.db.Migrator()
+	legacyUsers := m.HasTable(&UserModel{}) && !m.HasColumn(&UserModel{}, "email_verified_at")
+	if err := s.db.AutoMigrate(&UserModel{}, &ClaimLockModel{}, &SubAccountModel{}, &SessionModel{}, &TOTPModel{}, &BackupCodeModel{}, &SIWENonceModel{}, &MagicLinkModel{}); err != nil {
 		return fmt.Errorf("自动迁移表结构失败: %w", err)
 	}
+	if legacyUsers {
+		if err := s.db.Exec("UPDATE users SET email_verified_at = created_at").Error; err != nil {
+			return fmt.Errorf("迁移邮箱验证状态失败: %w", err)
+		}
+	}
 	return nil
 }
 
+// migrateClaimLocks 旧版 claim_locks 主键为 (user_id, claim_day)，
+// SQLite 无法直接添加主键列，因此重建表并把旧记录归到主账户
+func (s *Service) migrateClaimLocks() error {
+	m := s.db.Migrator()
+	if !m.HasTable(&ClaimLockModel{}) || m.HasColumn(&ClaimLockModel{}, "account") {
+		return nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().RenameTable("claim_locks", "claim_locks_old"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().CreateTable(&ClaimLockModel{}); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO claim_locks (user_id, claim_day, account, created_at) SELECT user_id, claim_day, '', created_at FROM claim_locks_old").Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("claim_locks_old")
+	})
+}
+
 // Register 注册并返回用户与地址
+// 主私钥由新生成的 BIP-39 助记词按 m/44'/60'/0'/0/0 派生，与 spending 子账户相同
 func (s *Service) Register(email, password string) (*User, error) {
-	// 生成密钥对
-	key, err := crypto.GenerateKey()
+	mnemonic, err := newMnemonic()
+	if err != nil {
+		return nil, fmt.Errorf("生成助记词失败: %w", err)
+	}
+	privBytes, err := deriveSubAccountKey(mnemonic, 0)
 	if err != nil {
 		return nil, fmt.Errorf("生成密钥失败: %w", err)
 	}
-	privBytes := crypto.FromECDSA(key)
+	return s.createUser(email, password, privBytes, mnemonic)
+}
+
+// RegisterWithKey 使用已有私钥注册（用于导入 keystore 或原始私钥）
+// 导入的私钥作为主账户，另外生成新的助记词用于子账户
+func (s *Service) RegisterWithKey(email, password string, privBytes []byte) (*User, error) {
+	mnemonic, err := newMnemonic()
+	if err != nil {
+		return nil, fmt.Errorf("生成助记词失败: %w", err)
+	}
+	return s.createUser(email, password, privBytes, mnemonic)
+}
+
+func (s *Service) createUser(email, password string, privBytes []byte, mnemonic string) (*User, error) {
+	key, err := crypto.ToECDSA(privBytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
 	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
 
-	passHash, passSalt, err := hashPassword(password)
+	// 同一地址只能绑定一个账户
+	var count int64
+	if err := s.db.Model(&UserModel{}).Where("address = ?", address).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAddressTaken
+	}
+
+	passHash, err := hashPassword(password)
 	if err != nil {
 		return nil, fmt.Errorf("密码哈希失败: %w", err)
 	}
@@ -62,29 +144,53 @@ func (s *Service) Register(email, password string) (*User, error) {
 		return nil, fmt.Errorf("加密私钥失败: %w", err)
 	}
 
+	encSeed, seedSalt, err := encryptMnemonic(password, mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("加密助记词失败: %w", err)
+	}
+	subAccounts, err := defaultSubAccounts(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("派生子账户失败: %w", err)
+	}
+
 	userModel := &UserModel{
 		Email:         email,
 		Address:       address,
 		EncPrivKeyB64: encPriv,
 		EncSaltB64:    encSalt,
-		PassSaltB64:   passSalt,
+		PassSaltB64:   "", // 新格式的盐包含在 PasswordHash 中
 		PasswordHash:  passHash,
+		EncSeedB64:    encSeed,
+		EncSeedSalt:   seedSalt,
 		CreatedAt:     time.Now(),
 	}
 
-	if err := s.db.Create(userModel).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(userModel).Error; err != nil {
+			return err
+		}
+		for i := range subAccounts {
+			subAccounts[i].UserID = userModel.ID
+		}
+		return tx.Create(&subAccounts).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &User{
-		ID:            userModel.ID,
-		Email:         userModel.Email,
-		Address:       userModel.Address,
-		EncPrivKeyB64: userModel.EncPrivKeyB64,
-		EncSaltB64:    userModel.EncSaltB64,
-		PassSaltB64:   userModel.PassSaltB64,
-		PasswordHash:  userModel.PasswordHash,
-		CreatedAt:     userModel.CreatedAt,
+		ID:              userModel.ID,
+		Email:           userModel.Email,
+		Address:         userModel.Address,
+		EncPrivKeyB64:   userModel.EncPrivKeyB64,
+		EncSaltB64:      userModel.EncSaltB64,
+		PassSaltB64:     userModel.PassSaltB64,
+		PasswordHash:    userModel.PasswordHash,
+		EncSeedB64:      userModel.EncSeedB64,
+		EncSeedSalt:     userModel.EncSeedSalt,
+		NonCustodial:    userModel.NonCustodial,
+		EmailVerifiedAt: userModel.EmailVerifiedAt,
+		CreatedAt:       userModel.CreatedAt,
 	}, nil
 }
 
@@ -102,18 +208,86 @@ func (s *Service) Authenticate(email, password string) (*User, error) {
 		return nil, errors.New("用户不存在或密码错误")
 	}
 
+	// 参数过旧时透明升级密码哈希与私钥、助记词加密；失败不影响本次登录
+	if needsRehash(userModel.PasswordHash) || needsRehash(userModel.EncSaltB64) ||
+		(userModel.EncSeedB64 != "" && needsRehash(userModel.EncSeedSalt)) {
+		if err := s.upgradeCredentials(&userModel, password); err != nil {
+			log.Printf("用户 %d 升级 Argon2 参数失败: %v", userModel.ID, err)
+		}
+	}
+	// 旧用户补齐助记词与默认子账户；失败不影响本次登录
+	if !userModel.NonCustodial && userModel.EncSeedB64 == "" {
+		if err := s.upgradeSeed(&userModel, password); err != nil {
+			log.Printf("用户 %d 生成助记词失败: %v", userModel.ID, err)
+		}
+	}
+
 	return &User{
-		ID:            userModel.ID,
-		Email:         userModel.Email,
-		Address:       userModel.Address,
-		EncPrivKeyB64: userModel.EncPrivKeyB64,
-		EncSaltB64:    userModel.EncSaltB64,
-		PassSaltB64:   userModel.PassSaltB64,
-		PasswordHash:  userModel.PasswordHash,
-		CreatedAt:     userModel.CreatedAt,
+		ID:              userModel.ID,
+		Email:           userModel.Email,
+		Address:         userModel.Address,
+		EncPrivKeyB64:   userModel.EncPrivKeyB64,
+		EncSaltB64:      userModel.EncSaltB64,
+		PassSaltB64:     userModel.PassSaltB64,
+		PasswordHash:    userModel.PasswordHash,
+		EncSeedB64:      userModel.EncSeedB64,
+		EncSeedSalt:     userModel.EncSeedSalt,
+		NonCustodial:    userModel.NonCustodial,
+		EmailVerifiedAt: userModel.EmailVerifiedAt,
+		CreatedAt:       userModel.CreatedAt,
 	}, nil
 }
 
+// upgradeCredentials 使用当前 Argon2 参数重新生成密码哈希并重新加密私钥与助记词
+func (s *Service) upgradeCredentials(userModel *UserModel, password string) error {
+	privBytes, err := decryptPrivateKey(password, userModel.EncPrivKeyB64, userModel.EncSaltB64)
+	if err != nil {
+		return fmt.Errorf("解密私钥失败: %w", err)
+	}
+	defer zero(privBytes)
+
+	passHash, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("密码哈希失败: %w", err)
+	}
+	encPriv, encSalt, err := encryptPrivateKey(password, privBytes)
+	if err != nil {
+		return fmt.Errorf("加密私钥失败: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"enc_priv_key":  encPriv,
+		"enc_salt":      encSalt,
+		"pass_salt":     "",
+		"password_hash": passHash,
+	}
+	if userModel.EncSeedB64 != "" {
+		mnemonic, err := decryptMnemonic(password, userModel.EncSeedB64, userModel.EncSeedSalt)
+		if err != nil {
+			return err
+		}
+		encSeed, seedSalt, err := encryptMnemonic(password, mnemonic)
+		if err != nil {
+			return fmt.Errorf("加密助记词失败: %w", err)
+		}
+		updates["enc_seed"] = encSeed
+		updates["enc_seed_salt"] = seedSalt
+		userModel.EncSeedB64 = encSeed
+		userModel.EncSeedSalt = seedSalt
+	}
+	// 仅在密文未被并发修改时更新
+	if err := s.db.Model(&UserModel{}).
+		Where("id = ? AND enc_priv_key = ?", userModel.ID, userModel.EncPrivKeyB64).
+		Updates(updates).Error; err != nil {
+		return err
+	}
+	userModel.EncPrivKeyB64 = encPriv
+	userModel.EncSaltB64 = encSalt
+	userModel.PassSaltB64 = ""
+	userModel.PasswordHash = passHash
+	return nil
+}
+
 // GetByID 获取用户
 func (s *Service) GetByID(id int64) (*User, error) {
 	var userModel UserModel
@@ -122,27 +296,58 @@ func (s *Service) GetByID(id int64) (*User, error) {
 	}
 
 	return &User{
-		ID:            userModel.ID,
-		Email:         userModel.Email,
-		Address:       userModel.Address,
-		EncPrivKeyB64: userModel.EncPrivKeyB64,
-		EncSaltB64:    userModel.EncSaltB64,
-		PassSaltB64:   userModel.PassSaltB64,
-		PasswordHash:  userModel.PasswordHash,
-		CreatedAt:     userModel.CreatedAt,
+		ID:              userModel.ID,
+		Email:           userModel.Email,
+		Address:         userModel.Address,
+		EncPrivKeyB64:   userModel.EncPrivKeyB64,
+		EncSaltB64:      userModel.EncSaltB64,
+		PassSaltB64:     userModel.PassSaltB64,
+		PasswordHash:    userModel.PasswordHash,
+		EncSeedB64:      userModel.EncSeedB64,
+		EncSeedSalt:     userModel.EncSeedSalt,
+		NonCustodial:    userModel.NonCustodial,
+		EmailVerifiedAt: userModel.EmailVerifiedAt,
+		CreatedAt:       userModel.CreatedAt,
 	}, nil
 }
 
 // DecryptPrivateKey 使用用户密码解密私钥
 func (s *Service) DecryptPrivateKey(u *User, password string) ([]byte, error) {
+	if u.NonCustodial {
+		return nil, ErrNonCustodial
+	}
 	// 密码错误会导致解密失败，直接返回错误
 	return decryptPrivateKey(password, u.EncPrivKeyB64, u.EncSaltB64)
 }
 
-// IsClaimLocked 检查用户在指定日期是否已提交领取
-func (s *Service) IsClaimLocked(userID, claimDay int64) (bool, error) {
+// lockAccount 领取锁使用的账户：地址与主账户相同的子账户（新用户的 spending）以及 default 都归为主账户 ""，
+// 保证同一链上地址每天只有一把领取锁
+func (s *Service) lockAccount(userID int64, account string) (string, error) {
+	if IsDefaultAccount(account) {
+		return "", nil
+	}
+	var count int64
+	err := s.db.Model(&SubAccountModel{}).
+		Where("user_id = ? AND label = ? AND address IN (?)", userID, account,
+			s.db.Model(&UserModel{}).Select("address").Where("id = ?", userID)).
+		Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", nil
+	}
+	return account, nil
+}
+
+// IsClaimLocked 检查用户的账户（account 为子账户标签，空表示主账户）在指定日期是否已提交领取
+func (s *Service) IsClaimLocked(userID, claimDay int64, account string) (bool, error) {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return false, err
+	}
 	var lock ClaimLockModel
-	err := s.db.Where("user_id = ? AND claim_day = ?", userID, claimDay).First(&lock).Error
+	err = s.db.Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).First(&lock).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return false, nil
@@ -152,18 +357,234 @@ func (s *Service) IsClaimLocked(userID, claimDay int64) (bool, error) {
 	return true, nil
 }
 
-// AddClaimLock 为用户在指定日期添加领取锁
-func (s *Service) AddClaimLock(userID, claimDay int64) error {
+// AddClaimLock 为用户的账户在指定日期添加领取锁
+func (s *Service) AddClaimLock(userID, claimDay int64, account string) error {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return err
+	}
 	lock := &ClaimLockModel{
 		UserID:    userID,
 		ClaimDay:  claimDay,
+		Account:   account,
+		Status:    ClaimLockPending,
 		CreatedAt: time.Now(),
 	}
 	// 使用 FirstOrCreate 实现 INSERT OR IGNORE 的效果
-	return s.db.Where("user_id = ? AND claim_day = ?", userID, claimDay).FirstOrCreate(lock).Error
+	return s.db.Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).FirstOrCreate(lock).Error
+}
+
+// RemoveClaimLock 删除用户的账户在指定日期的领取锁（用于失败回滚）
+func (s *Service) RemoveClaimLock(userID, claimDay int64, account string) error {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return err
+	}
+	return s.db.Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).Delete(&ClaimLockModel{}).Error
+}
+
+// LinkClaimLock 将领取锁关联到已签名的领取交易，供对账任务根据链上结果确认或释放
+func (s *Service) LinkClaimLock(userID, claimDay int64, account, address, txHash string, jobID int64) error {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.db.Model(&ClaimLockModel{}).
+		Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).
+		Updates(map[string]interface{}{"address": address, "tx_hash": txHash, "job_id": jobID, "linked_at": now}).Error
+}
+
+// PendingClaimLocks 列出 sinceDay 及之后仍在等待链上结果的领取锁
+func (s *Service) PendingClaimLocks(sinceDay int64, limit int) ([]ClaimLockModel, error) {
+	var locks []ClaimLockModel
+	err := s.db.Where("status = ? AND claim_day >= ?", ClaimLockPending, sinceDay).
+		Order("created_at").Limit(limit).Find(&locks).Error
+	return locks, err
+}
+
+// ConfirmClaimLock 确认领取锁（链上已领取），txHash 为空时保留原值
+func (s *Service) ConfirmClaimLock(userID, claimDay int64, account, txHash string) error {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{"status": ClaimLockConfirmed}
+	if txHash != "" {
+		fields["tx_hash"] = txHash
+	}
+	return s.db.Model(&ClaimLockModel{}).
+		Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).
+		Updates(fields).Error
+}
+
+// EnsureClaimLock 按链上领取事件补齐领取锁：不存在时创建，已存在时标记为已确认
+func (s *Service) EnsureClaimLock(userID, claimDay int64, account, address, txHash string) error {
+	account, err := s.lockAccount(userID, account)
+	if err != nil {
+		return err
+	}
+	lock := &ClaimLockModel{
+		UserID:    userID,
+		ClaimDay:  claimDay,
+		Account:   account,
+		Status:    ClaimLockConfirmed,
+		Address:   address,
+		TxHash:    txHash,
+		CreatedAt: time.Now(),
+	}
+	return s.db.Where("user_id = ? AND claim_day = ? AND account = ?", userID, claimDay, account).
+		Assign(map[string]interface{}{"status": ClaimLockConfirmed, "address": address, "tx_hash": txHash}).
+		FirstOrCreate(lock).Error
+}
+
+// AccountRef 用户的一个账户（Account 为子账户标签，空表示主账户）
+type AccountRef struct {
+	UserID  int64
+	Account string
+}
+
+// AccountsByAddress 查找使用该地址的所有账户；与主账户地址相同的子账户（新用户的 spending）只返回主账户
+func (s *Service) AccountsByAddress(address string) ([]AccountRef, error) {
+	var refs []AccountRef
+	var users []UserModel
+	if err := s.db.Select("id").Where("LOWER(address) = LOWER(?)", address).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	mainUsers := make(map[int64]bool, len(users))
+	for _, u := range users {
+		refs = append(refs, AccountRef{UserID: u.ID})
+		mainUsers[u.ID] = true
+	}
+	var subs []SubAccountModel
+	if err := s.db.Where("address = ?", common.HexToAddress(address).Hex()).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		if !mainUsers[sub.UserID] {
+			refs = append(refs, AccountRef{UserID: sub.UserID, Account: sub.Label})
+		}
+	}
+	return refs, nil
+}
+
+// UserSummary 用户的邮箱与全部链上地址（主账户与子账户）
+type UserSummary struct {
+	ID        int64
+	Email     string
+	Addresses []string // 校验和地址
+	CreatedAt time.Time
+}
+
+// ListUserSummaries 按 ID 升序列出 ID 大于 afterID 的用户，最多 limit 个
+func (s *Service) ListUserSummaries(afterID int64, limit int) ([]UserSummary, error) {
+	var users []UserModel
+	if err := s.db.Select("id", "email", "address", "created_at").Where("id > ?", afterID).Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	var subs []SubAccountModel
+	if err := s.db.Where("user_id IN ?", ids).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	summaries := make([]UserSummary, 0, len(users))
+	index := make(map[int64]int, len(users))
+	for _, u := range users {
+		index[u.ID] = len(summaries)
+		summaries = append(summaries, UserSummary{
+			ID:        u.ID,
+			Email:     u.Email,
+			Addresses: []string{common.HexToAddress(u.Address).Hex()},
+			CreatedAt: u.CreatedAt,
+		})
+	}
+	for _, sub := range subs {
+		summary := &summaries[index[sub.UserID]]
+		address := common.HexToAddress(sub.Address).Hex()
+		if address != summary.Addresses[0] {
+			summary.Addresses = append(summary.Addresses, address)
+		}
+	}
+	return summaries, nil
+}
+
+// ResetPassword 使用新密码重新加密私钥与助记词并更新密码哈希（用于托管恢复等无法提供旧密码的场景）
+// secret 为 WalletSecret 返回的钱包密钥材料；不含助记词熵时助记词无法恢复，已有子账户将不能再签名
+func (s *Service) ResetPassword(userID int64, newPassword string, secret []byte) error {
+	var userModel UserModel
+	if err := s.db.First(&userModel, userID).Error; err != nil {
+		return err
+	}
+	privBytes, mnemonic, err := splitWalletSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	key, err := crypto.ToECDSA(privBytes)
+	if err != nil {
+		return fmt.Errorf("解析私钥失败: %w", err)
+	}
+	if crypto.PubkeyToAddress(key.PublicKey).Hex() != userModel.Address {
+		return errors.New("私钥与用户地址不匹配")
+	}
+
+	passHash, err := hashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("密码哈希失败: %w", err)
+	}
+	encPriv, encSalt, err := encryptPrivateKey(newPassword, privBytes)
+	if err != nil {
+		return fmt.Errorf("加密私钥失败: %w", err)
+	}
+	updates := map[string]interface{}{
+		"enc_priv_key":  encPriv,
+		"enc_salt":      encSalt,
+		"pass_salt":     "",
+		"password_hash": passHash,
+	}
+
+	switch {
+	case mnemonic != "":
+		encSeed, seedSalt, err := encryptMnemonic(newPassword, mnemonic)
+		if err != nil {
+			return fmt.Errorf("加密助记词失败: %w", err)
+		}
+		updates["enc_seed"] = encSeed
+		updates["enc_seed_salt"] = seedSalt
+	case userModel.EncSeedB64 != "":
+		log.Printf("警告: 用户 %d 重置密码时未提供助记词，已有子账户无法再签名（请重新开启私钥托管以包含助记词）", userID)
+		updates["enc_seed"] = ""
+		updates["enc_seed_salt"] = ""
+	}
+
+	return s.db.Model(&userModel).Updates(updates).Error
 }
 
-// RemoveClaimLock 删除用户在指定日期的领取锁（用于失败回滚）
-func (s *Service) RemoveClaimLock(userID, claimDay int64) error {
-	return s.db.Where("user_id = ? AND claim_day = ?", userID, claimDay).Delete(&ClaimLockModel{}).Error
+// GetByEmail 根据邮箱获取用户
+func (s *Service) GetByEmail(email string) (*User, error) {
+	var userModel UserModel
+	if err := s.db.Where("email = ?", email).First(&userModel).Error; err != nil {
+		return nil, err
+	}
+
+	return &User{
+		ID:              userModel.ID,
+		Email:           userModel.Email,
+		Address:         userModel.Address,
+		EncPrivKeyB64:   userModel.EncPrivKeyB64,
+		EncSaltB64:      userModel.EncSaltB64,
+		PassSaltB64:     userModel.PassSaltB64,
+		PasswordHash:    userModel.PasswordHash,
+		EncSeedB64:      userModel.EncSeedB64,
+		EncSeedSalt:     userModel.EncSeedSalt,
+		NonCustodial:    userModel.NonCustodial,
+		EmailVerifiedAt: userModel.EmailVerifiedAt,
+		CreatedAt:       userModel.CreatedAt,
+	}, nil
 }
@@ -4,22 +4,56 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
-// argon2 参数
-const (
-	argonTime    uint32 = 1
-	argonMemory  uint32 = 64 * 1024
-	argonThreads uint8  = 1
-	argonKeyLen  uint32 = 32
+// argonParams argon2id 参数
+type argonParams struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+}
+
+var (
+	// legacyArgonParams 早期版本使用的固定参数（未随哈希保存）
+	legacyArgonParams = argonParams{Time: 1, Memory: 64 * 1024, Threads: 1, KeyLen: 32}
+
+	// currentArgonParams 新哈希/密文使用的参数；调高后，旧用户会在下次登录时自动升级
+	currentArgonParams = argonParams{Time: 3, Memory: 64 * 1024, Threads: 2, KeyLen: 32}
 )
 
-func deriveKey(password string, salt []byte) []byte {
-	return argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+const phcPrefix = "$argon2id$"
+
+// argonSlots 限制同时运行的 Argon2 计算数量（每次占用 Memory KiB 内存），超出的请求排队等待
+var argonSlots = make(chan struct{}, runtime.NumCPU())
+
+// SetArgonConcurrency 设置同时运行的 Argon2 计算上限，需在开始处理请求前调用
+func SetArgonConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	argonSlots = make(chan struct{}, n)
+}
+
+func deriveKey(password string, salt []byte, p argonParams) []byte {
+	argonSlots <- struct{}{}
+	defer func() { <-argonSlots }()
+	return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+}
+
+// WithKDFSlot 在同一并发上限内执行其他耗内存的 KDF 计算（如导入 keystore 时的 scrypt）
+func WithKDFSlot(fn func()) {
+	argonSlots <- struct{}{}
+	defer func() { <-argonSlots }()
+	fn()
 }
 
 func randomBytes(n int) ([]byte, error) {
@@ -28,42 +62,89 @@ func randomBytes(n int) ([]byte, error) {
 	return b, err
 }
 
-// hashPassword 返回 hash 和 salt（均 base64）
-func hashPassword(password string) (hashB64, saltB64 string, err error) {
-	salt, err := randomBytes(16)
+// encodeParams 生成 PHC 风格的参数与盐：$argon2id$v=19$m=65536,t=3,p=2$<salt>
+func encodeParams(p argonParams, salt []byte) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s",
+		phcPrefix, argon2.Version, p.Memory, p.Time, p.Threads, base64.RawStdEncoding.EncodeToString(salt))
+}
+
+// decodeParams 解析 PHC 风格字符串，返回参数、盐以及盐之后的剩余字段
+// 不带 $argon2id$ 前缀的值视为旧格式（纯 base64 盐 + legacyArgonParams）
+func decodeParams(spec string) (argonParams, []byte, []string, error) {
+	if !strings.HasPrefix(spec, phcPrefix) {
+		salt, err := base64.StdEncoding.DecodeString(spec)
+		return legacyArgonParams, salt, nil, err
+	}
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, [hash]
+	fields := strings.Split(spec, "$")
+	if len(fields) < 5 {
+		return argonParams{}, nil, nil, errors.New("无效的 argon2 参数")
+	}
+	var version int
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argonParams{}, nil, nil, errors.New("不支持的 argon2 版本")
+	}
+	p := argonParams{KeyLen: 32}
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return argonParams{}, nil, nil, errors.New("无效的 argon2 参数")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
 	if err != nil {
-		return "", "", err
+		return argonParams{}, nil, nil, err
 	}
-	key := deriveKey(password, salt)
-	return base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString(salt), nil
+	return p, salt, fields[5:], nil
+}
+
+// needsRehash 判断哈希或密文的参数是否落后于 currentArgonParams
+func needsRehash(spec string) bool {
+	p, _, _, err := decodeParams(spec)
+	return err != nil || p != currentArgonParams
 }
 
-func verifyPassword(password, hashB64, saltB64 string) bool {
-	salt, err := base64.StdEncoding.DecodeString(saltB64)
+// hashPassword 返回 PHC 格式的密码哈希（包含参数和盐）
+func hashPassword(password string) (string, error) {
+	salt, err := randomBytes(16)
 	if err != nil {
-		return false
+		return "", err
 	}
-	expected, err := base64.StdEncoding.DecodeString(hashB64)
-	if err != nil {
-		return false
+	key := deriveKey(password, salt, currentArgonParams)
+	return encodeParams(currentArgonParams, salt) + "$" + base64.RawStdEncoding.EncodeToString(key), nil
+}
+
+// verifyPassword 使用哈希中记录的参数校验密码；旧格式的哈希与盐分别存放（saltB64）
+func verifyPassword(password, hash, saltB64 string) bool {
+	var p argonParams
+	var salt, expected []byte
+	var err error
+	if strings.HasPrefix(hash, phcPrefix) {
+		var rest []string
+		p, salt, rest, err = decodeParams(hash)
+		if err != nil || len(rest) != 1 {
+			return false
+		}
+		expected, err = base64.RawStdEncoding.DecodeString(rest[0])
+	} else {
+		p = legacyArgonParams
+		if salt, err = base64.StdEncoding.DecodeString(saltB64); err == nil {
+			expected, err = base64.StdEncoding.DecodeString(hash)
+		}
 	}
-	key := deriveKey(password, salt)
-	if len(key) != len(expected) {
+	if err != nil || len(expected) == 0 {
 		return false
 	}
-	var diff byte
-	for i := range key {
-		diff |= key[i] ^ expected[i]
-	}
-	return diff == 0
+	p.KeyLen = uint32(len(expected))
+	key := deriveKey(password, salt, p)
+	return subtle.ConstantTimeCompare(key, expected) == 1
 }
 
-func encryptPrivateKey(password string, plaintext []byte) (cipherB64, saltB64 string, err error) {
+// encryptPrivateKey 使用当前参数加密私钥，返回密文与 PHC 风格的参数/盐
+func encryptPrivateKey(password string, plaintext []byte) (cipherB64, saltSpec string, err error) {
 	salt, err := randomBytes(16)
 	if err != nil {
 		return "", "", err
 	}
-	key := deriveKey(password, salt)
+	key := deriveKey(password, salt, currentArgonParams)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", "", err
@@ -77,11 +158,11 @@ func encryptPrivateKey(password string, plaintext []byte) (cipherB64, saltB64 st
 		return "", "", err
 	}
 	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), base64.StdEncoding.EncodeToString(salt), nil
+	return base64.StdEncoding.EncodeToString(ciphertext), encodeParams(currentArgonParams, salt), nil
 }
 
-func decryptPrivateKey(password, cipherB64, saltB64 string) ([]byte, error) {
-	salt, err := base64.StdEncoding.DecodeString(saltB64)
+func decryptPrivateKey(password, cipherB64, saltSpec string) ([]byte, error) {
+	p, salt, _, err := decodeParams(saltSpec)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +170,7 @@ func decryptPrivateKey(password, cipherB64, saltB64 string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	key := deriveKey(password, salt)
+	key := deriveKey(password, salt, p)
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// EmailVerifyPurpose 邮箱验证令牌的用途（JWT aud）
+	EmailVerifyPurpose = "email-verify"
+
+	emailVerifyTTL      = 48 * time.Hour
+	magicLinkTTL        = 15 * time.Minute
+	magicLinkMinBetween = time.Minute // 同一用户两次申请免密登录的最短间隔
+)
+
+var (
+	// ErrInvalidEmail 邮箱格式无效
+	ErrInvalidEmail = errors.New("邮箱格式无效")
+	// ErrEmailNotVerified 邮箱未验证
+	ErrEmailNotVerified = errors.New("请先验证邮箱")
+	// ErrInvalidEmailToken 验证链接无效或已过期
+	ErrInvalidEmailToken = errors.New("验证链接无效或已过期")
+	// ErrInvalidMagicLink 登录链接无效、已过期或已使用
+	ErrInvalidMagicLink = errors.New("登录链接无效、已过期或已使用")
+	// ErrMagicLinkThrottled 申请过于频繁
+	ErrMagicLinkThrottled = errors.New("登录链接申请过于频繁，请稍后再试")
+)
+
+// EmailVerified 邮箱是否已验证；自托管账户没有邮箱，视为已验证
+func (u *User) EmailVerified() bool {
+	return u.NonCustodial || u.EmailVerifiedAt != nil
+}
+
+// ValidateEmail 检查邮箱格式（只接受纯地址，不接受 "Name <addr>" 形式）
+func ValidateEmail(email string) error {
+	if len(email) > 254 {
+		return ErrInvalidEmail
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return ErrInvalidEmail
+	}
+	// .invalid 域名保留给自托管账户
+	if strings.HasSuffix(strings.ToLower(email), ".invalid") {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// EmailVerificationToken 生成邮箱验证链接中的签名令牌
+func (s *Service) EmailVerificationToken(u *User) (string, error) {
+	return GenerateActionToken(EmailVerifyPurpose, u.ID, u.Email, emailVerifyTTL)
+}
+
+// VerifyEmail 校验验证令牌并标记邮箱已验证（重复验证不报错）
+func (s *Service) VerifyEmail(token string) (*User, error) {
+	claims, err := ValidateActionToken(token, EmailVerifyPurpose)
+	if err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+	u, err := s.GetByID(claims.UserID)
+	if err != nil || u.Email != claims.Email {
+		return nil, ErrInvalidEmailToken
+	}
+	if err := s.markEmailVerified(s.db, u.ID); err != nil {
+		return nil, err
+	}
+	return s.GetByID(u.ID)
+}
+
+func (s *Service) markEmailVerified(db *gorm.DB, userID int64) error {
+	return db.Model(&UserModel{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error
+}
+
+// NewMagicLink 为邮箱对应的托管账户生成免密登录令牌（15 分钟有效，一次性），
+// 同一用户之前未使用的链接作废
+func (s *Service) NewMagicLink(email string) (string, *User, error) {
+	u, err := s.GetByEmail(email)
+	if err != nil {
+		return "", nil, err
+	}
+	if u.NonCustodial {
+		return "", nil, ErrNonCustodial
+	}
+
+	now := time.Now()
+	var recent int64
+	if err := s.db.Model(&MagicLinkModel{}).
+		Where("user_id = ? AND created_at > ?", u.ID, now.Add(-magicLinkMinBetween)).
+		Count(&recent).Error; err != nil {
+		return "", nil, err
+	}
+	if recent > 0 {
+		return "", nil, ErrMagicLinkThrottled
+	}
+
+	token, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("生成登录令牌失败: %w", err)
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND (used_at IS NULL OR expires_at < ?)", u.ID, now).
+			Delete(&MagicLinkModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&MagicLinkModel{
+			TokenHash: tokenHash,
+			UserID:    u.ID,
+			ExpiresAt: now.Add(magicLinkTTL),
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, u, nil
+}
+
+// PeekMagicLink 返回登录令牌对应的用户，不消耗令牌（用于先检查两步验证）
+func (s *Service) PeekMagicLink(token string) (*User, error) {
+	var link MagicLinkModel
+	err := s.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashRefreshToken(token), time.Now()).
+		First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(link.UserID)
+}
+
+// ConsumeMagicLink 使用登录令牌；能收到链接说明邮箱有效，同时标记邮箱已验证
+func (s *Service) ConsumeMagicLink(token string) (*User, error) {
+	var userID int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var link MagicLinkModel
+		if err := tx.Where("token_hash = ?", hashRefreshToken(token)).First(&link).Error; err != nil {
+			return ErrInvalidMagicLink
+		}
+		now := time.Now()
+		// 条件更新保证并发请求中只有一个成功
+		result := tx.Model(&MagicLinkModel{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", link.TokenHash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMagicLink
+		}
+		userID = link.UserID
+		return s.markEmailVerified(tx, link.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(userID)
+}
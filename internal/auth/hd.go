@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// 默认子账户标签，按顺序对应 BIP-44 地址索引 0、1、2
+var defaultSubAccountLabels = []string{"spending", "savings", "rewards"}
+
+// hdKey BIP-32 扩展私钥
+type hdKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// newMnemonic 生成 12 个单词的 BIP-39 助记词
+func newMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// hdMaster 由 BIP-39 助记词生成 BIP-32 主密钥
+func hdMaster(mnemonic string) (*hdKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return &hdKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child 派生子私钥（index >= 0x80000000 为硬化派生）
+func (k *hdKey) child(index uint32) (*hdKey, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, k.key...)
+	} else {
+		priv, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = crypto.CompressPubkey(&priv.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errors.New("派生索引无效")
+	}
+	childKey := il.Add(il, new(big.Int).SetBytes(k.key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, errors.New("派生索引无效")
+	}
+	return &hdKey{key: math.PaddedBigBytes(childKey, 32), chainCode: sum[32:]}, nil
+}
+
+// subAccountPath 子账户的 BIP-44 派生路径 m/44'/60'/0'/0/index
+func subAccountPath(index uint32) accounts.DerivationPath {
+	path := make(accounts.DerivationPath, len(accounts.DefaultRootDerivationPath))
+	copy(path, accounts.DefaultRootDerivationPath)
+	return append(path, index)
+}
+
+// deriveSubAccountKey 由助记词派生指定索引的私钥
+func deriveSubAccountKey(mnemonic string, index uint32) ([]byte, error) {
+	key, err := hdMaster(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range subAccountPath(index) {
+		if key, err = key.child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key.key, nil
+}
+
+// walletKeyLen 主私钥长度；钱包密钥材料为主私钥 || 助记词熵（没有助记词时只有主私钥）
+const walletKeyLen = 32
+
+// encryptMnemonic 与私钥相同，使用密码经 Argon2id 派生的密钥加密助记词，返回密文与 PHC 风格的参数/盐
+func encryptMnemonic(password, mnemonic string) (cipherB64, saltSpec string, err error) {
+	return encryptPrivateKey(password, []byte(mnemonic))
+}
+
+func decryptMnemonic(password, cipherB64, saltSpec string) (string, error) {
+	plain, err := decryptPrivateKey(password, cipherB64, saltSpec)
+	if err != nil {
+		return "", fmt.Errorf("解密助记词失败: %w", err)
+	}
+	defer zero(plain)
+	return string(plain), nil
+}
+
+// walletSecret 拼接主私钥与助记词熵
+func walletSecret(mainPriv []byte, mnemonic string) ([]byte, error) {
+	secret := append([]byte(nil), mainPriv...)
+	if mnemonic == "" {
+		return secret, nil
+	}
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		zero(secret)
+		return nil, err
+	}
+	defer zero(entropy)
+	return append(secret, entropy...), nil
+}
+
+// splitWalletSecret 拆分钱包密钥材料，返回的主私钥与 secret 共用内存
+func splitWalletSecret(secret []byte) ([]byte, string, error) {
+	if len(secret) < walletKeyLen {
+		return nil, "", errors.New("钱包密钥材料无效")
+	}
+	if len(secret) == walletKeyLen {
+		return secret, "", nil
+	}
+	mnemonic, err := bip39.NewMnemonic(secret[walletKeyLen:])
+	if err != nil {
+		return nil, "", fmt.Errorf("助记词熵无效: %w", err)
+	}
+	return secret[:walletKeyLen], mnemonic, nil
+}
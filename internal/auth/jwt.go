@@ -1,52 +1,322 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-in-production") // TODO: 从配置读取
-
 // Claims JWT claims
 type Claims struct {
+	UserID       int64  `json:"user_id"`
+	Email        string `json:"email"`
+	SessionID    string `json:"sid,omitempty"`
+	NonCustodial bool   `json:"nc,omitempty"` // SIWE 自托管账户，签名接口只返回未签名交易
+	jwt.RegisteredClaims
+}
+
+// jwtKey 一个签名/验证密钥
+type jwtKey struct {
+	kid    string
+	method jwt.SigningMethod
+	sign   interface{} // 签名密钥，仅验证的密钥为 nil
+	verify interface{}
+}
+
+// KeySet JWT 密钥集：使用 active 密钥签名，接受集合内任意密钥签发的令牌（便于轮换）
+type KeySet struct {
+	active string
+	keys   map[string]*jwtKey
+}
+
+// keySetFile 密钥集配置文件格式（JWT_KEYS_FILE）
+//
+//	{
+//	  "active": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-09", "alg": "HS256", "secret": "..."},
+//	    {"kid": "2026-10", "alg": "EdDSA", "privateKeyFile": "/etc/qxb/jwt-ed25519.pem"},
+//	    {"kid": "legacy-es", "alg": "ES256", "publicKey": "-----BEGIN PUBLIC KEY-----..."}
+//	  ]
+//	}
+type keySetFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		Kid            string `json:"kid"`
+		Alg            string `json:"alg"`
+		Secret         string `json:"secret,omitempty"`
+		PrivateKey     string `json:"privateKey,omitempty"`
+		PrivateKeyFile string `json:"privateKeyFile,omitempty"`
+		PublicKey      string `json:"publicKey,omitempty"`
+	} `json:"keys"`
+}
+
+var (
+	keySetMu     sync.RWMutex
+	activeKeySet *KeySet
+)
+
+// SetKeySet 设置全局使用的 JWT 密钥集
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	activeKeySet = ks
+}
+
+func currentKeySet() *KeySet {
+	keySetMu.RLock()
+	ks := activeKeySet
+	keySetMu.RUnlock()
+	if ks != nil {
+		return ks
+	}
+
+	// 未配置时使用进程内随机密钥（重启后令牌失效），仅适合本地开发
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	if activeKeySet == nil {
+		secret := make([]byte, 32)
+		_, _ = rand.Read(secret)
+		activeKeySet, _ = NewHMACKeySet("dev", secret)
+		log.Printf("警告: 未配置 JWT_KEYS_FILE 或 JWT_SECRET，使用临时随机密钥，重启后所有令牌失效")
+	}
+	return activeKeySet
+}
+
+// NewHMACKeySet 创建只包含一个 HS256 密钥的密钥集（兼容 JWT_SECRET）
+func NewHMACKeySet(kid string, secret []byte) (*KeySet, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("HS256 密钥至少 32 字节")
+	}
+	return &KeySet{
+		active: kid,
+		keys: map[string]*jwtKey{
+			kid: {kid: kid, method: jwt.SigningMethodHS256, sign: secret, verify: secret},
+		},
+	}, nil
+}
+
+// LoadKeySet 从配置加载密钥集：优先 JWT_KEYS_FILE，其次 JWT_SECRET；都未配置时返回 nil
+func LoadKeySet(keysFile, secret string) (*KeySet, error) {
+	if keysFile == "" {
+		if secret == "" {
+			return nil, nil
+		}
+		return NewHMACKeySet("default", []byte(secret))
+	}
+
+	data, err := os.ReadFile(keysFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 JWT 密钥文件失败: %w", err)
+	}
+	var file keySetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析 JWT 密钥文件失败: %w", err)
+	}
+
+	ks := &KeySet{active: file.Active, keys: make(map[string]*jwtKey)}
+	for _, k := range file.Keys {
+		if k.Kid == "" || ks.keys[k.Kid] != nil {
+			return nil, fmt.Errorf("JWT 密钥 kid 为空或重复: %q", k.Kid)
+		}
+		privPEM := []byte(k.PrivateKey)
+		if k.PrivateKeyFile != "" {
+			if privPEM, err = os.ReadFile(k.PrivateKeyFile); err != nil {
+				return nil, fmt.Errorf("读取 JWT 私钥 %s 失败: %w", k.Kid, err)
+			}
+		}
+
+		key := &jwtKey{kid: k.Kid}
+		switch k.Alg {
+		case "HS256":
+			if len(k.Secret) < 32 {
+				return nil, fmt.Errorf("JWT 密钥 %s: HS256 密钥至少 32 字节", k.Kid)
+			}
+			key.method = jwt.SigningMethodHS256
+			key.sign, key.verify = []byte(k.Secret), []byte(k.Secret)
+		case "EdDSA":
+			key.method = jwt.SigningMethodEdDSA
+			if len(privPEM) > 0 {
+				priv, err := jwt.ParseEdPrivateKeyFromPEM(privPEM)
+				if err != nil {
+					return nil, fmt.Errorf("JWT 密钥 %s: %w", k.Kid, err)
+				}
+				key.sign, key.verify = priv, priv.(ed25519.PrivateKey).Public()
+			} else if key.verify, err = jwt.ParseEdPublicKeyFromPEM([]byte(k.PublicKey)); err != nil {
+				return nil, fmt.Errorf("JWT 密钥 %s: %w", k.Kid, err)
+			}
+		case "ES256":
+			key.method = jwt.SigningMethodES256
+			if len(privPEM) > 0 {
+				priv, err := jwt.ParseECPrivateKeyFromPEM(privPEM)
+				if err != nil {
+					return nil, fmt.Errorf("JWT 密钥 %s: %w", k.Kid, err)
+				}
+				key.sign, key.verify = priv, &priv.PublicKey
+			} else if key.verify, err = jwt.ParseECPublicKeyFromPEM([]byte(k.PublicKey)); err != nil {
+				return nil, fmt.Errorf("JWT 密钥 %s: %w", k.Kid, err)
+			}
+			if pub := key.verify.(*ecdsa.PublicKey); pub.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("JWT 密钥 %s: ES256 需要 P-256 曲线", k.Kid)
+			}
+		default:
+			return nil, fmt.Errorf("JWT 密钥 %s: 不支持的算法 %q", k.Kid, k.Alg)
+		}
+		ks.keys[k.Kid] = key
+	}
+
+	if active := ks.keys[ks.active]; active == nil || active.sign == nil {
+		return nil, fmt.Errorf("active 密钥 %q 不存在或缺少私钥", ks.active)
+	}
+	return ks, nil
+}
+
+// GenerateToken 生成绑定到登录会话的 JWT access token
+func GenerateToken(userID int64, email, sessionID string, nonCustodial bool, ttl time.Duration) (string, error) {
+	ks := currentKeySet()
+	key := ks.keys[ks.active]
+
+	claims := Claims{
+		UserID:       userID,
+		Email:        email,
+		SessionID:    sessionID,
+		NonCustodial: nonCustodial,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.sign)
+}
+
+// keyFunc 按 kid 选择验证密钥，算法必须与密钥一致
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := ks.keys[kid]
+	if key == nil {
+		return nil, errors.New("unknown kid")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.verify, nil
+}
+
+// ValidateToken 验证 JWT access token；带 aud 的用途令牌（如邮件链接）不能当作 access token
+func ValidateToken(tokenString string) (*Claims, error) {
+	ks := currentKeySet()
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, ks.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "EdDSA", "ES256"}))
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// ActionClaims 用途令牌的 claims，aud 为用途（如 email-verify）
+type ActionClaims struct {
 	UserID int64  `json:"user_id"`
 	Email  string `json:"email"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT token
-func GenerateToken(userID int64, email string) (string, error) {
-	claims := Claims{
+// GenerateActionToken 生成只能用于指定用途的签名令牌
+func GenerateActionToken(purpose string, userID int64, email string, ttl time.Duration) (string, error) {
+	ks := currentKeySet()
+	key := ks.keys[ks.active]
+
+	claims := ActionClaims{
 		UserID: userID,
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			Audience:  jwt.ClaimStrings{purpose},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.sign)
 }
 
-// ValidateToken 验证 JWT token
-func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return jwtSecret, nil
-	})
+// ValidateActionToken 验证用途令牌，aud 必须与 purpose 一致
+func ValidateActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	ks := currentKeySet()
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, ks.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "EdDSA", "ES256"}),
+		jwt.WithAudience(purpose), jwt.WithExpirationRequired())
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*ActionClaims); ok && token.Valid {
 		return claims, nil
 	}
 	return nil, errors.New("invalid token")
 }
 
+// JWK JSON Web Key（仅公钥）
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
 
+// JWKS 返回密钥集中所有非对称密钥的公钥，HS256 密钥不会公开
+func JWKS() []JWK {
+	ks := currentKeySet()
+	jwks := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		switch pub := key.verify.(type) {
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+				Kid: key.kid,
+				Alg: key.method.Alg(),
+				Use: "sig",
+			})
+		case *ecdsa.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "EC",
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(padCoord(pub.X)),
+				Y:   base64.RawURLEncoding.EncodeToString(padCoord(pub.Y)),
+				Kid: key.kid,
+				Alg: key.method.Alg(),
+				Use: "sig",
+			})
+		}
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
 
+// padCoord P-256 坐标固定为 32 字节
+func padCoord(v *big.Int) []byte {
+	b := make([]byte, 32)
+	return v.FillBytes(b)
+}
@@ -6,14 +6,18 @@ import (
 
 // UserModel GORM 用户模型
 type UserModel struct {
-	ID            int64     `gorm:"primaryKey;autoIncrement"`
-	Email         string    `gorm:"uniqueIndex;not null;column:email"`
-	Address       string    `gorm:"not null;column:address"`
-	EncPrivKeyB64 string    `gorm:"not null;column:enc_priv_key"`
-	EncSaltB64    string    `gorm:"not null;column:enc_salt"`
-	PassSaltB64   string    `gorm:"not null;column:pass_salt"`
-	PasswordHash  string    `gorm:"not null;column:password_hash"`
-	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	ID              int64      `gorm:"primaryKey;autoIncrement"`
+	Email           string     `gorm:"uniqueIndex;not null;column:email"`
+	Address         string     `gorm:"not null;column:address"`
+	EncPrivKeyB64   string     `gorm:"not null;column:enc_priv_key"`
+	EncSaltB64      string     `gorm:"not null;column:enc_salt"`                    // PHC 风格的 argon2 参数与盐（旧数据为纯 base64 盐）
+	PassSaltB64     string     `gorm:"not null;column:pass_salt"`                   // 仅旧格式使用，新格式的盐包含在 PasswordHash 中
+	PasswordHash    string     `gorm:"not null;column:password_hash"`               // PHC 格式 $argon2id$v=19$m=..,t=..,p=..$salt$hash
+	EncSeedB64      string     `gorm:"column:enc_seed"`                             // HD 钱包助记词（与私钥相同，使用密码经 Argon2id 派生的密钥加密）
+	EncSeedSalt     string     `gorm:"column:enc_seed_salt"`                        // 助记词加密的 PHC 风格参数与盐
+	NonCustodial    bool       `gorm:"not null;default:false;column:non_custodial"` // SIWE 登录的自托管账户，服务器不保存私钥
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at"`                    // 邮箱验证时间，未验证为 NULL
+	CreatedAt       time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
 }
 
 // TableName 指定表名
@@ -21,14 +25,116 @@ func (UserModel) TableName() string {
 	return "users"
 }
 
+// SubAccountModel GORM HD 子账户模型
+type SubAccountModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"uniqueIndex:idx_sub_account_label;uniqueIndex:idx_sub_account_index;not null;column:user_id"`
+	Label     string    `gorm:"uniqueIndex:idx_sub_account_label;not null;column:label"`
+	Index     uint32    `gorm:"uniqueIndex:idx_sub_account_index;not null;column:derivation_index"`
+	Address   string    `gorm:"not null;column:address"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (SubAccountModel) TableName() string {
+	return "sub_accounts"
+}
+
+// 领取锁状态
+const (
+	ClaimLockPending   = "pending"   // 已提交，等待链上结果
+	ClaimLockConfirmed = "confirmed" // 链上已领取
+)
+
 // ClaimLockModel GORM 领取锁模型
 type ClaimLockModel struct {
-	UserID    int64     `gorm:"primaryKey;column:user_id"`
-	ClaimDay  int64     `gorm:"primaryKey;column:claim_day"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	UserID    int64      `gorm:"primaryKey;column:user_id"`
+	ClaimDay  int64      `gorm:"primaryKey;column:claim_day"`
+	Account   string     `gorm:"primaryKey;column:account"` // 子账户标签，空字符串表示主账户
+	Status    string     `gorm:"index;not null;default:pending;column:status"`
+	Address   string     `gorm:"column:address"`   // 领取地址
+	TxHash    string     `gorm:"column:tx_hash"`   // 领取交易，签名前为空
+	JobID     int64      `gorm:"column:job_id"`    // 发送领取交易的任务
+	LinkedAt  *time.Time `gorm:"column:linked_at"` // 关联交易的时间
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
 }
 
 // TableName 指定表名
 func (ClaimLockModel) TableName() string {
 	return "claim_locks"
 }
+
+// SessionModel GORM 登录会话模型（每台设备一条，刷新令牌仅保存哈希）
+type SessionModel struct {
+	ID              string     `gorm:"primaryKey;column:id"` // 会话 ID，写入 access token 的 sid
+	UserID          int64      `gorm:"index;not null;column:user_id"`
+	RefreshHash     string     `gorm:"uniqueIndex;not null;column:refresh_hash"` // 当前刷新令牌的 SHA-256
+	PrevRefreshHash string     `gorm:"index;column:prev_refresh_hash"`           // 上一个刷新令牌，再次出现说明令牌被盗用
+	UserAgent       string     `gorm:"column:user_agent"`
+	IP              string     `gorm:"column:ip"`
+	CreatedAt       time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	LastUsedAt      time.Time  `gorm:"column:last_used_at"`
+	ExpiresAt       time.Time  `gorm:"not null;column:expires_at"`
+	RevokedAt       *time.Time `gorm:"column:revoked_at"`
+}
+
+// TableName 指定表名
+func (SessionModel) TableName() string {
+	return "sessions"
+}
+
+// TOTPModel GORM 两步验证（RFC 6238）模型
+type TOTPModel struct {
+	UserID            int64      `gorm:"primaryKey;column:user_id"`
+	Secret            string     `gorm:"not null;column:secret"` // base32 编码的共享密钥
+	Enabled           bool       `gorm:"not null;default:false;column:enabled"`
+	LastUsedStep      int64      `gorm:"not null;default:0;column:last_used_step"` // 最近一次使用的时间步，防止验证码重放
+	RequireForLogin   bool       `gorm:"not null;default:true;column:require_for_login"`
+	TransferThreshold string     `gorm:"column:transfer_threshold"` // 转账金额（最小单位）超过该值时需要验证码，空表示所有转账都需要
+	CreatedAt         time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	EnabledAt         *time.Time `gorm:"column:enabled_at"`
+}
+
+// TableName 指定表名
+func (TOTPModel) TableName() string {
+	return "user_totp"
+}
+
+// BackupCodeModel GORM 两步验证备用码模型（仅保存哈希，每个只能使用一次）
+type BackupCodeModel struct {
+	ID       int64      `gorm:"primaryKey;autoIncrement"`
+	UserID   int64      `gorm:"index;not null;column:user_id"`
+	CodeHash string     `gorm:"not null;column:code_hash"`
+	UsedAt   *time.Time `gorm:"column:used_at"`
+}
+
+// TableName 指定表名
+func (BackupCodeModel) TableName() string {
+	return "totp_backup_codes"
+}
+
+// SIWENonceModel GORM SIWE 登录 nonce 模型（一次性）
+type SIWENonceModel struct {
+	Nonce     string    `gorm:"primaryKey;column:nonce"`
+	ExpiresAt time.Time `gorm:"index;not null;column:expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (SIWENonceModel) TableName() string {
+	return "siwe_nonces"
+}
+
+// MagicLinkModel GORM 免密登录链接模型（令牌仅保存哈希，使用一次后失效）
+type MagicLinkModel struct {
+	TokenHash string     `gorm:"primaryKey;column:token_hash"`
+	UserID    int64      `gorm:"index;not null;column:user_id"`
+	ExpiresAt time.Time  `gorm:"not null;column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (MagicLinkModel) TableName() string {
+	return "magic_links"
+}
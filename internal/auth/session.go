@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌无效、已过期或会话已注销
+	ErrInvalidRefreshToken = errors.New("无效或过期的刷新令牌")
+	// ErrSessionNotFound 会话不存在或已注销
+	ErrSessionNotFound = errors.New("会话不存在或已注销")
+)
+
+// Tokens 一次登录/刷新签发的令牌
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token 剩余秒数
+	SessionID    string
+}
+
+// newRefreshToken 生成随机刷新令牌及其哈希（数据库只保存哈希）
+func newRefreshToken() (string, string, error) {
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession 为登录的设备创建会话并签发 access/refresh token
+func (s *Service) CreateSession(u *User, userAgent, ip string) (*Tokens, error) {
+	refresh, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+	now := time.Now()
+	session := &SessionModel{
+		ID:          uuid.NewString(),
+		UserID:      u.ID,
+		RefreshHash: refreshHash,
+		UserAgent:   userAgent,
+		IP:          ip,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(s.RefreshTokenTTL),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	return s.issueTokens(u, session.ID, refresh)
+}
+
+func (s *Service) issueTokens(u *User, sessionID, refresh string) (*Tokens, error) {
+	access, err := GenerateToken(u.ID, u.Email, sessionID, u.NonCustodial, s.AccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(s.AccessTokenTTL / time.Second),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌对；刷新令牌每次使用后轮换，
+// 已轮换掉的旧令牌再次出现时视为泄露，直接注销整个会话
+func (s *Service) Refresh(refreshToken, userAgent, ip string) (*Tokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	hash := hashRefreshToken(refreshToken)
+	now := time.Now()
+
+	var session SessionModel
+	err := s.db.Where("refresh_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var reused SessionModel
+		if s.db.Where("prev_refresh_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error == nil {
+			log.Printf("会话 %s（用户 %d）的旧刷新令牌被重复使用，注销该会话", reused.ID, reused.UserID)
+			s.db.Model(&reused).Update("revoked_at", now)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetByID(session.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	refresh, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+	// 以旧哈希为条件更新，并发刷新时只有一个请求能成功
+	result := s.db.Model(&SessionModel{}).
+		Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_hash":      refreshHash,
+			"prev_refresh_hash": hash,
+			"last_used_at":      now,
+			"user_agent":        userAgent,
+			"ip":                ip,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s.issueTokens(user, session.ID, refresh)
+}
+
+// IsSessionActive 检查会话是否仍然有效（未注销且未过期）
+func (s *Service) IsSessionActive(userID int64, sessionID string) bool {
+	var count int64
+	s.db.Model(&SessionModel{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// ListSessions 列出用户当前有效的会话
+func (s *Service) ListSessions(userID int64) ([]SessionModel, error) {
+	var sessions []SessionModel
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession 注销指定会话
+func (s *Service) RevokeSession(userID int64, sessionID string) error {
+	result := s.db.Model(&SessionModel{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 注销用户除 keepID 以外的所有会话（keepID 为空时全部注销），返回注销数量
+func (s *Service) RevokeOtherSessions(userID int64, keepID string) (int64, error) {
+	result := s.db.Model(&SessionModel{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+const (
+	siweNonceTTL  = 10 * time.Minute
+	siweClockSkew = time.Minute
+	siweHeader    = " wants you to sign in with your Ethereum account:"
+)
+
+var (
+	// ErrInvalidSIWE SIWE 消息或签名无效
+	ErrInvalidSIWE = errors.New("无效的 SIWE 消息或签名")
+	// ErrCustodialAddress 地址属于托管账户，不能通过 SIWE 登录
+	ErrCustodialAddress = errors.New("该地址属于托管账户，请使用邮箱和密码登录")
+)
+
+// SIWEMessage EIP-4361 消息
+type SIWEMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        uint64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseSIWEMessage 解析 EIP-4361 文本消息
+func ParseSIWEMessage(msg string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], siweHeader) {
+		return nil, errors.New("SIWE 消息头无效")
+	}
+	m := &SIWEMessage{Domain: strings.TrimSuffix(lines[0], siweHeader)}
+	if m.Domain == "" {
+		return nil, errors.New("SIWE 消息缺少 domain")
+	}
+	if !common.IsHexAddress(lines[1]) || !strings.HasPrefix(lines[1], "0x") {
+		return nil, errors.New("SIWE 消息地址无效")
+	}
+	m.Address = common.HexToAddress(lines[1])
+
+	// 地址之后为空行，可选的 statement 后再接一个空行
+	i := 2
+	if lines[i] != "" {
+		return nil, errors.New("SIWE 消息格式无效")
+	}
+	i++
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		m.Statement = lines[i]
+		i++
+		if i >= len(lines) || lines[i] != "" {
+			return nil, errors.New("SIWE 消息格式无效")
+		}
+		i++
+	}
+
+	var err error
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			if i < len(lines) && lines[i] != "" {
+				return nil, errors.New("SIWE 消息格式无效")
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			if line == "" && i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("SIWE 消息字段无效: %q", line)
+		}
+		switch key {
+		case "URI":
+			m.URI = value
+		case "Version":
+			m.Version = value
+		case "Chain ID":
+			m.ChainID, err = strconv.ParseUint(value, 10, 64)
+		case "Nonce":
+			m.Nonce = value
+		case "Issued At":
+			m.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			m.ExpirationTime = &t
+		case "Not Before":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			m.NotBefore = &t
+		case "Request ID":
+			m.RequestID = value
+		default:
+			return nil, fmt.Errorf("SIWE 消息字段未知: %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SIWE 字段 %s 无效: %w", key, err)
+		}
+	}
+
+	if m.URI == "" || m.Version == "" || m.ChainID == 0 || m.Nonce == "" || m.IssuedAt.IsZero() {
+		return nil, errors.New("SIWE 消息缺少必填字段")
+	}
+	return m, nil
+}
+
+// NewSIWENonce 生成一次性 nonce（10 分钟内有效）
+func (s *Service) NewSIWENonce() (string, time.Time, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nonce := hex.EncodeToString(b)
+	expiresAt := time.Now().Add(siweNonceTTL)
+	if err := s.db.Create(&SIWENonceModel{Nonce: nonce, ExpiresAt: expiresAt, CreatedAt: time.Now()}).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	// 顺便清理过期 nonce
+	s.db.Where("expires_at < ?", time.Now()).Delete(&SIWENonceModel{})
+	return nonce, expiresAt, nil
+}
+
+// consumeSIWENonce 删除 nonce，只有第一次使用成功
+func (s *Service) consumeSIWENonce(nonce string) bool {
+	result := s.db.Where("nonce = ? AND expires_at > ?", nonce, time.Now()).Delete(&SIWENonceModel{})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// LoginWithSIWE 校验 SIWE 消息（domain、链 ID、有效期、nonce）与 personal_sign 签名，
+// 首次登录时自动创建自托管账户
+func (s *Service) LoginWithSIWE(message string, signature []byte, domain string, chainID uint64) (*User, error) {
+	m, err := ParseSIWEMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(m.Domain, domain) {
+		return nil, fmt.Errorf("SIWE domain 不匹配: %s", m.Domain)
+	}
+	if m.Version != "1" {
+		return nil, errors.New("不支持的 SIWE 版本")
+	}
+	if m.ChainID != chainID {
+		return nil, fmt.Errorf("SIWE 链 ID 不匹配: %d", m.ChainID)
+	}
+	now := time.Now()
+	if m.IssuedAt.After(now.Add(siweClockSkew)) || m.IssuedAt.Before(now.Add(-siweNonceTTL)) {
+		return nil, errors.New("SIWE 消息签发时间无效")
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return nil, errors.New("SIWE 消息已过期")
+	}
+	if m.NotBefore != nil && now.Add(siweClockSkew).Before(*m.NotBefore) {
+		return nil, errors.New("SIWE 消息尚未生效")
+	}
+
+	if len(signature) != crypto.SignatureLength {
+		return nil, ErrInvalidSIWE
+	}
+	sig := common.CopyBytes(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != m.Address {
+		return nil, ErrInvalidSIWE
+	}
+
+	// 签名验证通过后再消耗 nonce，防止他人用无效签名耗尽合法 nonce
+	if !s.consumeSIWENonce(m.Nonce) {
+		return nil, errors.New("SIWE nonce 无效或已使用")
+	}
+	return s.findOrCreateSIWEUser(m.Address)
+}
+
+// siweEmail 自托管账户没有邮箱，使用保留域名 .invalid 生成唯一标识（RFC 2606，不会被投递）
+func siweEmail(address common.Address) string {
+	return strings.ToLower(address.Hex()) + "@siwe.invalid"
+}
+
+func (s *Service) findOrCreateSIWEUser(address common.Address) (*User, error) {
+	var existing UserModel
+	err := s.db.Where("address = ?", address.Hex()).First(&existing).Error
+	if err == nil {
+		if !existing.NonCustodial {
+			return nil, ErrCustodialAddress
+		}
+		return s.GetByID(existing.ID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	userModel := UserModel{
+		Email:        siweEmail(address),
+		Address:      address.Hex(),
+		NonCustodial: true,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.db.Create(&userModel).Error; err != nil {
+		return nil, fmt.Errorf("创建自托管账户失败: %w", err)
+	}
+	return s.GetByID(userModel.ID)
+}
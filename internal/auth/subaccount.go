@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+// DefaultAccount 主账户选择器（空字符串同样表示主账户）
+const DefaultAccount = "default"
+
+var (
+	// ErrSubAccountNotFound 子账户不存在
+	ErrSubAccountNotFound = errors.New("子账户不存在")
+
+	subAccountLabelPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
+// IsDefaultAccount 判断账户选择器是否指向主账户
+func IsDefaultAccount(account string) bool {
+	return account == "" || account == DefaultAccount
+}
+
+// defaultSubAccounts 由助记词派生默认子账户（spending / savings / rewards）
+func defaultSubAccounts(mnemonic string) ([]SubAccountModel, error) {
+	subAccounts := make([]SubAccountModel, 0, len(defaultSubAccountLabels))
+	for i, label := range defaultSubAccountLabels {
+		address, err := subAccountAddress(mnemonic, uint32(i))
+		if err != nil {
+			return nil, err
+		}
+		subAccounts = append(subAccounts, SubAccountModel{
+			Label:     label,
+			Index:     uint32(i),
+			Address:   address,
+			CreatedAt: time.Now(),
+		})
+	}
+	return subAccounts, nil
+}
+
+func subAccountAddress(mnemonic string, index uint32) (string, error) {
+	privBytes, err := deriveSubAccountKey(mnemonic, index)
+	if err != nil {
+		return "", err
+	}
+	key, err := crypto.ToECDSA(privBytes)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey).Hex(), nil
+}
+
+// SubAccountPath 返回子账户的派生路径字符串
+func SubAccountPath(index uint32) string {
+	return subAccountPath(index).String()
+}
+
+// ListSubAccounts 列出用户的子账户
+func (s *Service) ListSubAccounts(userID int64) ([]SubAccountModel, error) {
+	var subAccounts []SubAccountModel
+	err := s.db.Where("user_id = ?", userID).Order("derivation_index").Find(&subAccounts).Error
+	return subAccounts, err
+}
+
+// GetSubAccount 根据标签获取子账户
+func (s *Service) GetSubAccount(userID int64, label string) (*SubAccountModel, error) {
+	var sub SubAccountModel
+	if err := s.db.Where("user_id = ? AND label = ?", userID, label).First(&sub).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubAccountNotFound
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// CreateSubAccount 在下一个地址索引上派生新的子账户
+// 旧用户没有助记词时会先生成一份（使用密码加密保存）并派生默认子账户
+func (s *Service) CreateSubAccount(u *User, password, label string) (*SubAccountModel, error) {
+	if IsDefaultAccount(label) || !subAccountLabelPattern.MatchString(label) {
+		return nil, errors.New("子账户标签只能包含小写字母、数字、- 和 _，且不能为 default")
+	}
+	if _, err := s.GetSubAccount(u.ID, label); err == nil {
+		return nil, errors.New("子账户标签已存在")
+	}
+
+	// 先校验密码：新生成的助记词同样使用该密码加密
+	mainPriv, err := s.DecryptPrivateKey(u, password)
+	if err != nil {
+		return nil, errors.New("密码错误或解密失败")
+	}
+	zero(mainPriv)
+
+	var mnemonic string
+	if u.EncSeedB64 == "" {
+		if mnemonic, err = s.provisionSeed(u, password); err != nil {
+			return nil, err
+		}
+		if _, err := s.GetSubAccount(u.ID, label); err == nil {
+			return nil, errors.New("子账户标签已存在")
+		}
+	} else if mnemonic, err = s.seedMnemonic(u, password); err != nil {
+		return nil, err
+	}
+
+	var next struct{ Max *uint32 }
+	if err := s.db.Model(&SubAccountModel{}).Select("MAX(derivation_index) AS max").Where("user_id = ?", u.ID).Scan(&next).Error; err != nil {
+		return nil, err
+	}
+	var index uint32
+	if next.Max != nil {
+		index = *next.Max + 1
+	}
+
+	address, err := subAccountAddress(mnemonic, index)
+	if err != nil {
+		return nil, fmt.Errorf("派生子账户失败: %w", err)
+	}
+	sub := &SubAccountModel{
+		UserID:    u.ID,
+		Label:     label,
+		Index:     index,
+		Address:   address,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// provisionSeed 为没有助记词的旧用户生成助记词（使用密码加密保存），没有子账户时同时派生默认子账户
+func (s *Service) provisionSeed(u *User, password string) (string, error) {
+	mnemonic, err := newMnemonic()
+	if err != nil {
+		return "", fmt.Errorf("生成助记词失败: %w", err)
+	}
+	encSeed, seedSalt, err := encryptMnemonic(password, mnemonic)
+	if err != nil {
+		return "", fmt.Errorf("加密助记词失败: %w", err)
+	}
+	subAccounts, err := defaultSubAccounts(mnemonic)
+	if err != nil {
+		return "", fmt.Errorf("派生子账户失败: %w", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserModel{}).Where("id = ? AND (enc_seed IS NULL OR enc_seed = '')", u.ID).
+			Updates(map[string]interface{}{"enc_seed": encSeed, "enc_seed_salt": seedSalt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("助记词已存在，请重试")
+		}
+		// 助记词丢失后重新生成时保留原有子账户记录，不再派生默认子账户
+		var count int64
+		if err := tx.Model(&SubAccountModel{}).Where("user_id = ?", u.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		for i := range subAccounts {
+			subAccounts[i].UserID = u.ID
+		}
+		return tx.Create(&subAccounts).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	u.EncSeedB64 = encSeed
+	u.EncSeedSalt = seedSalt
+	return mnemonic, nil
+}
+
+// seedMnemonic 使用密码解密用户的助记词
+func (s *Service) seedMnemonic(u *User, password string) (string, error) {
+	if u.EncSeedB64 == "" {
+		return "", errors.New("用户尚未生成助记词")
+	}
+	return decryptMnemonic(password, u.EncSeedB64, u.EncSeedSalt)
+}
+
+// upgradeSeed 登录时为没有助记词的旧用户补齐助记词与默认子账户
+func (s *Service) upgradeSeed(userModel *UserModel, password string) error {
+	u := &User{ID: userModel.ID}
+	if _, err := s.provisionSeed(u, password); err != nil {
+		return err
+	}
+	userModel.EncSeedB64 = u.EncSeedB64
+	userModel.EncSeedSalt = u.EncSeedSalt
+	return nil
+}
+
+// WalletSecret 使用密码解密钱包密钥材料：主私钥 || 助记词熵（没有助记词时只有主私钥）
+// 用于解锁会话与私钥托管，由此可以派生主账户与全部子账户的私钥；调用方用完后应清零
+func (s *Service) WalletSecret(u *User, password string) ([]byte, error) {
+	mainPriv, err := s.DecryptPrivateKey(u, password)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(mainPriv)
+	if u.EncSeedB64 == "" {
+		return append([]byte(nil), mainPriv...), nil
+	}
+	mnemonic, err := s.seedMnemonic(u, password)
+	if err != nil {
+		return nil, err
+	}
+	return walletSecret(mainPriv, mnemonic)
+}
+
+// AccountAddress 返回账户选择器对应的地址（无需密码）
+func (s *Service) AccountAddress(u *User, account string) (string, error) {
+	if IsDefaultAccount(account) {
+		return u.Address, nil
+	}
+	sub, err := s.GetSubAccount(u.ID, account)
+	if err != nil {
+		return "", err
+	}
+	return sub.Address, nil
+}
+
+// AccountKey 使用密码解密并返回账户选择器对应的私钥与地址
+func (s *Service) AccountKey(u *User, password, account string) ([]byte, string, error) {
+	if IsDefaultAccount(account) {
+		mainPriv, err := s.DecryptPrivateKey(u, password)
+		if err != nil {
+			return nil, "", err
+		}
+		return mainPriv, u.Address, nil
+	}
+	if _, err := s.GetSubAccount(u.ID, account); err != nil {
+		return nil, "", err
+	}
+	secret, err := s.WalletSecret(u, password)
+	if err != nil {
+		return nil, "", err
+	}
+	defer zero(secret)
+	return s.SubAccountKey(u, secret, account)
+}
+
+// SubAccountKey 由钱包密钥材料（WalletSecret）派生账户选择器对应的私钥
+func (s *Service) SubAccountKey(u *User, secret []byte, account string) ([]byte, string, error) {
+	mainPriv, mnemonic, err := splitWalletSecret(secret)
+	if err != nil {
+		return nil, "", err
+	}
+	if IsDefaultAccount(account) {
+		return append([]byte(nil), mainPriv...), u.Address, nil
+	}
+	sub, err := s.GetSubAccount(u.ID, account)
+	if err != nil {
+		return nil, "", err
+	}
+	if mnemonic == "" {
+		return nil, "", errors.New("用户尚未生成助记词")
+	}
+	privBytes, err := deriveSubAccountKey(mnemonic, sub.Index)
+	if err != nil {
+		return nil, "", err
+	}
+	// 托管恢复时助记词丢失后重新生成的助记词派生不出原有子账户
+	if key, err := crypto.ToECDSA(privBytes); err != nil || crypto.PubkeyToAddress(key.PublicKey).Hex() != sub.Address {
+		zero(privBytes)
+		return nil, "", errors.New("子账户的助记词已丢失，无法签名")
+	}
+	return privBytes, sub.Address, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// OwnsAddress 判断地址是否为用户的主账户或子账户
+func (s *Service) OwnsAddress(u *User, address string) (bool, error) {
+	if strings.EqualFold(u.Address, address) {
+		return true, nil
+	}
+	var count int64
+	err := s.db.Model(&SubAccountModel{}).
+		Where("user_id = ? AND address = ?", u.ID, common.HexToAddress(address).Hex()).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RFC 6238 参数（与 Google Authenticator 等常见应用兼容）
+const (
+	totpIssuer  = "QXB"
+	totpPeriod  = 30
+	totpDigits  = 6
+	totpSkew    = 1 // 允许前后各一个时间步的时钟误差
+	backupCodes = 10
+)
+
+var (
+	// ErrTOTPNotEnabled 用户未开启两步验证
+	ErrTOTPNotEnabled = errors.New("未开启两步验证")
+	// ErrTOTPAlreadyEnabled 用户已开启两步验证
+	ErrTOTPAlreadyEnabled = errors.New("已开启两步验证")
+	// ErrInvalidTOTP 验证码错误、已使用或已过期
+	ErrInvalidTOTP = errors.New("两步验证码错误")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPStatus 两步验证状态与策略
+type TOTPStatus struct {
+	Enabled              bool
+	RequireForLogin      bool
+	TransferThreshold    string
+	BackupCodesRemaining int64
+}
+
+// totpCode 计算指定时间步的验证码（HOTP，HMAC-SHA1，RFC 4226）
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000)
+}
+
+// matchTOTP 在允许的时钟误差内查找匹配的时间步，返回 0 表示不匹配
+func matchTOTP(secretB32, code string, now time.Time) int64 {
+	secret, err := totpEncoding.DecodeString(secretB32)
+	if err != nil || len(code) != totpDigits {
+		return 0
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return 0
+}
+
+// totpURI 生成 otpauth:// 配置 URI，客户端可将其渲染为二维码供验证器应用扫描
+func totpURI(email, secretB32 string) string {
+	v := url.Values{}
+	v.Set("secret", secretB32)
+	v.Set("issuer", totpIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(totpIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// normalizeBackupCode 备用码忽略大小写、空格和连字符
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashBackupCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeBackupCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newBackupCodes 生成一组 xxxx-xxxx 格式的备用码
+func newBackupCodes() ([]string, error) {
+	codes := make([]string, backupCodes)
+	for i := range codes {
+		b, err := randomBytes(5)
+		if err != nil {
+			return nil, err
+		}
+		s := fmt.Sprintf("%08s", strings.ToLower(new(big.Int).SetBytes(b).Text(36)))
+		codes[i] = s[len(s)-8:len(s)-4] + "-" + s[len(s)-4:]
+	}
+	return codes, nil
+}
+
+// SetupTOTP 生成新的共享密钥（尚未启用，需调用 ConfirmTOTP 确认），返回密钥与配置 URI
+func (s *Service) SetupTOTP(u *User) (secret, uri string, err error) {
+	var existing TOTPModel
+	err = s.db.First(&existing, "user_id = ?", u.ID).Error
+	if err == nil && existing.Enabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", err
+	}
+
+	raw, err := randomBytes(20)
+	if err != nil {
+		return "", "", err
+	}
+	secret = totpEncoding.EncodeToString(raw)
+	model := TOTPModel{UserID: u.ID, Secret: secret, RequireForLogin: true, CreatedAt: time.Now()}
+	if err := s.db.Save(&model).Error; err != nil {
+		return "", "", err
+	}
+	return secret, totpURI(u.Email, secret), nil
+}
+
+// ConfirmTOTP 使用验证器应用生成的验证码确认并启用两步验证，返回一次性备用码
+func (s *Service) ConfirmTOTP(userID int64, code string) ([]string, error) {
+	var model TOTPModel
+	if err := s.db.First(&model, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTOTPNotEnabled
+		}
+		return nil, err
+	}
+	if model.Enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	step := matchTOTP(model.Secret, strings.TrimSpace(code), time.Now())
+	if step == 0 {
+		return nil, ErrInvalidTOTP
+	}
+
+	codes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model).Updates(map[string]interface{}{
+			"enabled":        true,
+			"enabled_at":     now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		return replaceBackupCodes(tx, userID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func replaceBackupCodes(tx *gorm.DB, userID int64, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&BackupCodeModel{}).Error; err != nil {
+		return err
+	}
+	models := make([]BackupCodeModel, len(codes))
+	for i, c := range codes {
+		models[i] = BackupCodeModel{UserID: userID, CodeHash: hashBackupCode(c)}
+	}
+	return tx.Create(&models).Error
+}
+
+// TOTPEnabled 判断用户是否已开启两步验证
+func (s *Service) TOTPEnabled(userID int64) (bool, error) {
+	status, err := s.GetTOTPStatus(userID)
+	if err != nil {
+		return false, err
+	}
+	return status.Enabled, nil
+}
+
+// GetTOTPStatus 查询两步验证状态与策略
+func (s *Service) GetTOTPStatus(userID int64) (*TOTPStatus, error) {
+	var model TOTPModel
+	err := s.db.First(&model, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !model.Enabled) {
+		return &TOTPStatus{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var remaining int64
+	if err := s.db.Model(&BackupCodeModel{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining).Error; err != nil {
+		return nil, err
+	}
+	return &TOTPStatus{
+		Enabled:              true,
+		RequireForLogin:      model.RequireForLogin,
+		TransferThreshold:    model.TransferThreshold,
+		BackupCodesRemaining: remaining,
+	}, nil
+}
+
+// VerifyTOTP 校验验证码或备用码；验证码的时间步只能使用一次，备用码使用后作废
+func (s *Service) VerifyTOTP(userID int64, code string) error {
+	var model TOTPModel
+	if err := s.db.First(&model, "user_id = ? AND enabled = ?", userID, true).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTOTPNotEnabled
+		}
+		return err
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrInvalidTOTP
+	}
+
+	if step := matchTOTP(model.Secret, code, time.Now()); step != 0 {
+		// 条件更新：同一时间步（或更早的）验证码不能再次使用
+		result := s.db.Model(&TOTPModel{}).
+			Where("user_id = ? AND last_used_step < ?", userID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTOTP
+		}
+		return nil
+	}
+
+	result := s.db.Model(&BackupCodeModel{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashBackupCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTP
+	}
+	return nil
+}
+
+// RegenerateBackupCodes 重新生成备用码（旧备用码全部作废）
+func (s *Service) RegenerateBackupCodes(userID int64) ([]string, error) {
+	codes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return replaceBackupCodes(tx, userID, codes)
+	}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SetTOTPPolicy 更新登录是否需要验证码以及转账需要验证码的金额阈值
+func (s *Service) SetTOTPPolicy(userID int64, requireForLogin bool, transferThreshold string) error {
+	if transferThreshold != "" {
+		if v, ok := new(big.Int).SetString(transferThreshold, 10); !ok || v.Sign() < 0 {
+			return errors.New("无效的转账阈值")
+		}
+	}
+	result := s.db.Model(&TOTPModel{}).
+		Where("user_id = ? AND enabled = ?", userID, true).
+		Updates(map[string]interface{}{
+			"require_for_login":  requireForLogin,
+			"transfer_threshold": transferThreshold,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTOTPNotEnabled
+	}
+	return nil
+}
+
+// DisableTOTP 关闭两步验证并删除密钥与备用码
+func (s *Service) DisableTOTP(userID int64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&BackupCodeModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&TOTPModel{}).Error
+	})
+}
+
+// TransferNeedsTOTP 根据用户策略判断该金额的转账是否需要验证码
+func (s *Service) TransferNeedsTOTP(userID int64, amount *big.Int) (bool, error) {
+	status, err := s.GetTOTPStatus(userID)
+	if err != nil || !status.Enabled {
+		return false, err
+	}
+	if status.TransferThreshold == "" {
+		return true, nil
+	}
+	threshold, ok := new(big.Int).SetString(status.TransferThreshold, 10)
+	return !ok || amount.Cmp(threshold) > 0, nil
+}
+
+// ChangePassword 校验旧密码后使用新密码重新加密私钥与助记词
+func (s *Service) ChangePassword(u *User, oldPassword, newPassword string) error {
+	if !verifyPassword(oldPassword, u.PasswordHash, u.PassSaltB64) {
+		return errors.New("原密码错误")
+	}
+	secret, err := s.WalletSecret(u, oldPassword)
+	if err != nil {
+		return fmt.Errorf("解密私钥失败: %w", err)
+	}
+	defer zero(secret)
+	return s.ResetPassword(u.ID, newPassword, secret)
+}
@@ -0,0 +1,385 @@
+package autoclaim
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+const (
+	daySeconds   = 86400
+	retryBackoff = 5 * time.Minute // 提交失败后的首次重试间隔，之后翻倍
+	maxBackoff   = time.Hour
+	batchSize    = 50
+)
+
+var (
+	// ErrDisabled 服务器未配置委托密钥
+	ErrDisabled = errors.New("服务器未开启自动领取")
+	// ErrNotFound 委托不存在或已撤销
+	ErrNotFound = errors.New("未开启自动领取")
+	// ErrKeyChanged 委托密钥已更换，旧委托无法解密，需要用户重新开启
+	ErrKeyChanged = errors.New("委托密钥已更换，请重新开启自动领取")
+	// ErrNotClaim 委托私钥只能签名发往奖励合约的 claimDailyReward 调用
+	ErrNotClaim = errors.New("委托私钥只能用于 claimDailyReward")
+)
+
+// claimSelector claimDailyReward() 的函数选择器，也是委托私钥唯一允许签名的调用数据
+var claimSelector = crypto.Keccak256([]byte("claimDailyReward()"))[:4]
+
+// ClaimFunc 为委托账户提交当天的领取，返回的记录只需填写 Status、TxHash、JobID、OperationID、Detail；
+// 返回错误表示提交失败，稍后重试
+type ClaimFunc func(ctx context.Context, d *DelegationModel, day int64) (*RunModel, error)
+
+// Service 管理自动领取委托，并在每个 UTC 日开始后（加随机延迟）为委托账户领取奖励
+type Service struct {
+	db       *gorm.DB
+	key      []byte // AES-256 委托密钥，未配置时为 nil
+	keyID    string
+	contract common.Address
+	jitter   time.Duration // 每天开始后随机延迟的上限，避免所有账户同时领取
+}
+
+// NewService 创建服务并初始化表结构；secret 为空时服务可查询但不能开启新的委托，也不会执行领取
+func NewService(db *gorm.DB, secret string, contract common.Address, jitter time.Duration) (*Service, error) {
+	if err := db.AutoMigrate(&DelegationModel{}, &RunModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	s := &Service{db: db, contract: contract, jitter: jitter}
+	if secret != "" {
+		// 与其他用途的密钥做域分离
+		sum := sha256.Sum256([]byte("qxb-autoclaim-delegation-v1\x00" + secret))
+		s.key = sum[:]
+		id := sha256.Sum256(s.key)
+		s.keyID = hex.EncodeToString(id[:4])
+	}
+	return s, nil
+}
+
+// Enabled 是否已配置委托密钥
+func (s *Service) Enabled() bool {
+	return s.key != nil
+}
+
+// Today 当前 UTC 日，与合约的日期计算一致
+func Today() int64 {
+	return time.Now().UTC().Unix() / daySeconds
+}
+
+// nextSlot 下一个 UTC 日开始后的随机时刻
+func (s *Service) nextSlot(now time.Time) time.Time {
+	next := time.Unix((now.UTC().Unix()/daySeconds+1)*daySeconds, 0)
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(mrand.Int63n(int64(s.jitter))))
+	}
+	return next
+}
+
+// Grant 用户授权自动领取：privKey 以委托密钥重新加密保存，立即安排一次领取（当天已领取时会跳过）
+func (s *Service) Grant(userID int64, account, address string, privKey []byte) (*DelegationModel, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	key, err := crypto.ToECDSA(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	address = common.HexToAddress(address).Hex()
+	if crypto.PubkeyToAddress(key.PublicKey).Hex() != address {
+		return nil, errors.New("私钥与地址不匹配")
+	}
+	wrapped, err := s.wrap(userID, account, address, privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	d := &DelegationModel{
+		UserID:    userID,
+		Account:   account,
+		CreatedAt: now,
+	}
+	err = s.db.Where("user_id = ? AND account = ?", userID, account).
+		Assign(map[string]interface{}{
+			"address":     address,
+			"wrapped_key": wrapped,
+			"key_id":      s.keyID,
+			"status":      StatusActive,
+			"next_run_at": now,
+			"failures":    0,
+			"updated_at":  now,
+			"revoked_at":  nil,
+		}).
+		FirstOrCreate(d).Error
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// List 列出用户的委托（包括已暂停和已撤销的）
+func (s *Service) List(userID int64) ([]DelegationModel, error) {
+	var list []DelegationModel
+	err := s.db.Where("user_id = ?", userID).Order("id").Find(&list).Error
+	return list, err
+}
+
+// get 查询未撤销的委托
+func (s *Service) get(userID int64, account string) (*DelegationModel, error) {
+	var d DelegationModel
+	err := s.db.Where("user_id = ? AND account = ? AND status <> ?", userID, account, StatusRevoked).Limit(1).Find(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	if d.ID == 0 {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+// SetPaused 暂停或恢复自动领取；恢复后立即检查当天是否需要领取
+func (s *Service) SetPaused(userID int64, account string, paused bool) (*DelegationModel, error) {
+	d, err := s.get(userID, account)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	fields := map[string]interface{}{"status": StatusPaused, "updated_at": now}
+	if !paused {
+		fields = map[string]interface{}{"status": StatusActive, "next_run_at": now, "failures": 0, "updated_at": now}
+	}
+	if err := s.db.Model(d).Updates(fields).Error; err != nil {
+		return nil, err
+	}
+	return s.get(userID, account)
+}
+
+// Revoke 撤销委托并删除委托私钥，历史记录保留
+func (s *Service) Revoke(userID int64, account string) (*DelegationModel, error) {
+	d, err := s.get(userID, account)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.db.Model(d).Updates(map[string]interface{}{
+		"status":      StatusRevoked,
+		"wrapped_key": "",
+		"updated_at":  now,
+		"revoked_at":  now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// History 按时间倒序列出用户的自动领取记录
+func (s *Service) History(userID int64, limit int) ([]RunModel, error) {
+	var runs []RunModel
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// Signer 返回委托账户的签名函数：只签名发往奖励合约、不转账、调用数据恰好为 claimDailyReward() 的交易，
+// 私钥在每次签名时解密并在签名后清零，不会离开本包
+func (s *Service) Signer(d *DelegationModel) func(tx *types.Transaction, signer types.Signer) (*types.Transaction, error) {
+	return func(tx *types.Transaction, signer types.Signer) (*types.Transaction, error) {
+		if tx.To() == nil || *tx.To() != s.contract || tx.Value().Sign() != 0 || !bytes.Equal(tx.Data(), claimSelector) {
+			return nil, ErrNotClaim
+		}
+		privBytes, err := s.unwrap(d)
+		if err != nil {
+			return nil, err
+		}
+		defer zero(privBytes)
+		key, err := crypto.ToECDSA(privBytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析委托私钥失败: %w", err)
+		}
+		return types.SignTx(tx, signer, key)
+	}
+}
+
+// aad 将密文绑定到委托的用户、账户、地址与用途，防止密文被挪用到其他记录
+func aad(userID int64, account, address string) []byte {
+	return []byte(fmt.Sprintf("claimDailyReward\x00%d\x00%s\x00%s", userID, account, strings.ToLower(address)))
+}
+
+func (s *Service) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Service) wrap(userID int64, account, address string, privKey []byte) (string, error) {
+	gcm, err := s.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, privKey, aad(userID, account, address))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Service) unwrap(d *DelegationModel) ([]byte, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	if d.Status == StatusRevoked || d.WrappedKey == "" {
+		return nil, ErrNotFound
+	}
+	if d.KeyID != s.keyID {
+		return nil, ErrKeyChanged
+	}
+	sealed, err := base64.StdEncoding.DecodeString(d.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("委托私钥密文无效")
+	}
+	nonce, body := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, body, aad(d.UserID, d.Account, d.Address))
+	if err != nil {
+		return nil, fmt.Errorf("解密委托私钥失败: %w", err)
+	}
+	return plain, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Run 每隔 interval 为到期的委托提交领取，直到 ctx 结束；未配置委托密钥时直接返回
+func (s *Service) Run(ctx context.Context, interval time.Duration, claim ClaimFunc) {
+	if !s.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDue(ctx, claim)
+		}
+	}
+}
+
+func (s *Service) runDue(ctx context.Context, claim ClaimFunc) {
+	var due []DelegationModel
+	now := time.Now()
+	err := s.db.Where("status = ? AND next_run_at <= ?", StatusActive, now).
+		Order("next_run_at").Limit(batchSize).Find(&due).Error
+	if err != nil {
+		log.Printf("查询到期的自动领取失败: %v", err)
+		return
+	}
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runOne(ctx, &due[i], claim)
+	}
+}
+
+func (s *Service) runOne(ctx context.Context, d *DelegationModel, claim ClaimFunc) {
+	now := time.Now()
+	day := Today()
+
+	// 以条件更新占用本次执行，多个实例共享数据库时只有一个会执行
+	lease := s.db.Model(&DelegationModel{}).
+		Where("id = ? AND status = ? AND next_run_at <= ?", d.ID, StatusActive, now).
+		Update("next_run_at", now.Add(maxBackoff))
+	if lease.Error != nil || lease.RowsAffected == 0 {
+		return
+	}
+
+	if d.LastClaimDay >= day {
+		s.reschedule(d.ID, d.LastClaimDay, 0, s.nextSlot(now))
+		return
+	}
+
+	var run *RunModel
+	var err error
+	if d.KeyID != s.keyID {
+		err = ErrKeyChanged
+	} else {
+		run, err = claim(ctx, d, day)
+	}
+	if err != nil {
+		run = &RunModel{Status: RunFailed, Detail: err.Error()}
+	}
+	run.DelegationID = d.ID
+	run.UserID = d.UserID
+	run.Account = d.Account
+	run.Address = d.Address
+	run.ClaimDay = day
+	run.CreatedAt = now
+	if createErr := s.db.Create(run).Error; createErr != nil {
+		log.Printf("保存自动领取记录失败: %v", createErr)
+	}
+
+	if err == nil {
+		s.reschedule(d.ID, day, 0, s.nextSlot(now))
+		return
+	}
+
+	log.Printf("用户 %d 自动领取失败（账户 %q）: %v", d.UserID, d.Account, err)
+	if errors.Is(err, ErrKeyChanged) {
+		// 委托无法再使用：暂停，等待用户重新开启
+		s.db.Model(&DelegationModel{}).Where("id = ?", d.ID).
+			Updates(map[string]interface{}{"status": StatusPaused, "updated_at": now})
+		return
+	}
+	// 当天内按退避重试，最晚在下一个领取时刻重新开始
+	backoff := retryBackoff << d.Failures
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	next, failures := now.Add(backoff), d.Failures+1
+	if slot := s.nextSlot(now); slot.Before(next) {
+		next, failures = slot, 0
+	}
+	s.reschedule(d.ID, d.LastClaimDay, failures, next)
+}
+
+func (s *Service) reschedule(id, lastClaimDay int64, failures int, next time.Time) {
+	err := s.db.Model(&DelegationModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_claim_day": lastClaimDay,
+		"failures":       failures,
+		"next_run_at":    next,
+		"updated_at":     time.Now(),
+	}).Error
+	if err != nil {
+		log.Printf("更新自动领取计划失败: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package autoclaim
+
+import (
+	"time"
+)
+
+// 委托状态
+const (
+	StatusActive  = "active"  // 按计划自动领取
+	StatusPaused  = "paused"  // 用户暂停，委托私钥保留
+	StatusRevoked = "revoked" // 用户撤销，委托私钥已删除
+)
+
+// 自动领取记录结果
+const (
+	RunSubmitted = "submitted" // 领取交易已放入发送队列
+	RunSkipped   = "skipped"   // 当天已领取或已提交
+	RunFailed    = "failed"    // 提交失败，稍后重试
+)
+
+// DelegationModel GORM 自动领取委托模型：用户授权后，私钥以服务器委托密钥重新加密保存，只能用于签名 claimDailyReward
+type DelegationModel struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement"`
+	UserID       int64      `gorm:"uniqueIndex:idx_autoclaim_user_account;not null;column:user_id"`
+	Account      string     `gorm:"uniqueIndex:idx_autoclaim_user_account;not null;column:account"` // 子账户标签，空字符串表示主账户
+	Address      string     `gorm:"not null;column:address"`
+	WrappedKey   string     `gorm:"column:wrapped_key"` // AES-GCM 密文（base64），撤销后清空
+	KeyID        string     `gorm:"column:key_id"`      // 委托密钥指纹，用于发现密钥更换
+	Status       string     `gorm:"index:idx_autoclaim_due;not null;column:status"`
+	NextRunAt    time.Time  `gorm:"index:idx_autoclaim_due;not null;column:next_run_at"`
+	LastClaimDay int64      `gorm:"column:last_claim_day"`              // 最近一次处理完成的 UTC 日
+	Failures     int        `gorm:"not null;default:0;column:failures"` // 当天连续失败次数
+	CreatedAt    time.Time  `gorm:"not null;column:created_at"`
+	UpdatedAt    time.Time  `gorm:"not null;column:updated_at"`
+	RevokedAt    *time.Time `gorm:"column:revoked_at"`
+}
+
+// TableName 指定表名
+func (DelegationModel) TableName() string {
+	return "autoclaim_delegations"
+}
+
+// RunModel GORM 自动领取记录模型
+type RunModel struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement"`
+	DelegationID int64     `gorm:"index;not null;column:delegation_id"`
+	UserID       int64     `gorm:"index:idx_autoclaim_runs_user;not null;column:user_id"`
+	Account      string    `gorm:"not null;column:account"`
+	Address      string    `gorm:"not null;column:address"`
+	ClaimDay     int64     `gorm:"not null;column:claim_day"`
+	Status       string    `gorm:"not null;column:status"`
+	TxHash       string    `gorm:"column:tx_hash"`
+	JobID        int64     `gorm:"column:job_id"`
+	OperationID  string    `gorm:"column:operation_id"` // 需要先补贴 Gas 时的异步操作
+	Detail       string    `gorm:"column:detail"`       // 跳过或失败的原因
+	CreatedAt    time.Time `gorm:"index:idx_autoclaim_runs_user;not null;column:created_at"`
+}
+
+// TableName 指定表名
+func (RunModel) TableName() string {
+	return "autoclaim_runs"
+}
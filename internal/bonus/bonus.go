@@ -0,0 +1,256 @@
+package bonus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"lbtc/internal/claims"
+)
+
+// cursorName 在 index_cursors 中记录已处理到的领取事件 ID
+const cursorName = "streak_bonus"
+
+// Milestone 连续领取里程碑
+type Milestone struct {
+	Days   int
+	Amount *big.Int // QXB 最小单位
+}
+
+// ParseMilestones 解析 "天数:金额" 列表，如 "7:1000000000000000000,30:5000000000000000000"，金额为 QXB 最小单位
+func ParseMilestones(spec string) ([]Milestone, error) {
+	var milestones []Milestone
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("无效的里程碑 %q，格式为 天数:金额", item)
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || days < 2 {
+			return nil, fmt.Errorf("无效的里程碑天数 %q", parts[0])
+		}
+		amount, ok := new(big.Int).SetString(strings.TrimSpace(parts[1]), 10)
+		if !ok || amount.Sign() <= 0 {
+			return nil, fmt.Errorf("无效的里程碑金额 %q", parts[1])
+		}
+		if seen[days] {
+			return nil, fmt.Errorf("里程碑 %d 天重复", days)
+		}
+		seen[days] = true
+		milestones = append(milestones, Milestone{Days: days, Amount: amount})
+	}
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i].Days < milestones[j].Days })
+	return milestones, nil
+}
+
+var (
+	// ErrNotFound 奖金记录不存在
+	ErrNotFound = errors.New("奖金记录不存在")
+	// ErrNotRetryable 只有发送失败的奖金可以重试
+	ErrNotRetryable = errors.New("只有发送失败的奖金可以重试")
+)
+
+// PayFunc 发送一笔奖金（通常是放入发送队列），返回发送任务 ID
+type PayFunc func(payout *PayoutModel) (int64, error)
+
+// Service 根据链上领取事件计算连续领取天数，在达到里程碑时记录并发放奖金
+type Service struct {
+	db         *gorm.DB
+	claims     *claims.Service
+	milestones []Milestone
+}
+
+// NewService 创建服务并初始化表结构；milestones 为空表示不发放奖金
+func NewService(db *gorm.DB, claimsService *claims.Service, milestones []Milestone) (*Service, error) {
+	if err := db.AutoMigrate(&PayoutModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, claims: claimsService, milestones: milestones}, nil
+}
+
+// Milestones 配置的里程碑（按天数升序）
+func (s *Service) Milestones() []Milestone {
+	return s.milestones
+}
+
+// ListByAddress 列出地址的奖金记录（新的在前）
+func (s *Service) ListByAddress(address string, limit int) ([]PayoutModel, error) {
+	var payouts []PayoutModel
+	err := s.db.Where("address = ?", address).Order("id DESC").Limit(limit).Find(&payouts).Error
+	return payouts, err
+}
+
+// List 按状态列出奖金记录（status 为空表示全部，新的在前）
+func (s *Service) List(status string, limit int) ([]PayoutModel, error) {
+	var payouts []PayoutModel
+	query := s.db.Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&payouts).Error
+	return payouts, err
+}
+
+// MarkSent 记录奖金转账交易已广播
+func (s *Service) MarkSent(id int64, txHash string) error {
+	return s.db.Model(&PayoutModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     StatusSent,
+		"tx_hash":    txHash,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// MarkFailed 记录奖金发送失败
+func (s *Service) MarkFailed(id int64, cause error) error {
+	return s.db.Model(&PayoutModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     StatusFailed,
+		"error":      cause.Error(),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// Retry 将发送失败的奖金重新置为待发送并再次调用 pay；唯一约束使 process 不会重新生成同一笔奖金，
+// 失败的奖金只能通过这里补发
+func (s *Service) Retry(id int64, pay PayFunc) (*PayoutModel, error) {
+	result := s.db.Model(&PayoutModel{}).Where("id = ? AND status = ?", id, StatusFailed).Updates(map[string]interface{}{
+		"status":     StatusPending,
+		"error":      "",
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	var payout PayoutModel
+	if err := s.db.First(&payout, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotRetryable
+	}
+	if err := s.send(&payout, pay); err != nil {
+		return nil, err
+	}
+	if err := s.db.First(&payout, id).Error; err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// send 调用 pay 发送奖金并记录任务 ID；发送失败时标记为失败
+func (s *Service) send(payout *PayoutModel, pay PayFunc) error {
+	jobID, err := pay(payout)
+	if err != nil {
+		log.Printf("发送连续领取奖金 %d 失败: %v", payout.ID, err)
+		if markErr := s.MarkFailed(payout.ID, err); markErr != nil {
+			log.Printf("记录奖金 %d 失败状态出错: %v", payout.ID, markErr)
+		}
+		return err
+	}
+	if err := s.db.Model(payout).Updates(map[string]interface{}{"job_id": jobID, "updated_at": time.Now()}).Error; err != nil {
+		log.Printf("记录奖金 %d 的任务失败: %v", payout.ID, err)
+	}
+	return nil
+}
+
+// Run 每隔 interval 处理新索引的领取事件并发放达到的里程碑奖金，直到 ctx 结束；没有配置里程碑时直接返回
+// 首次运行从当前最新事件之后开始，不为开启奖金前的历史连续领取补发
+func (s *Service) Run(ctx context.Context, interval time.Duration, pay PayFunc) {
+	if len(s.milestones) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.process(pay); err != nil {
+				log.Printf("处理连续领取奖金失败: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) process(pay PayFunc) error {
+	position, ok, err := s.claims.Cursor(cursorName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		last, err := s.claims.LastEventID()
+		if err != nil {
+			return err
+		}
+		return s.claims.SaveCursor(cursorName, uint64(last))
+	}
+
+	for {
+		events, err := s.claims.EventsAfter(int64(position), 200)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		for i := range events {
+			if err := s.award(&events[i], pay); err != nil {
+				return err
+			}
+			position = uint64(events[i].ID)
+			if err := s.claims.SaveCursor(cursorName, position); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// award 计算领取当天的连续天数，为已达到的里程碑记录奖金（同一段连续领取中每个里程碑只发放一次）
+func (s *Service) award(event *claims.EventModel, pay PayFunc) error {
+	streak, err := s.claims.StreakAt(event.Address, event.Day)
+	if err != nil {
+		return err
+	}
+	for _, m := range s.milestones {
+		if streak.Current < m.Days {
+			break
+		}
+		now := time.Now()
+		payout := &PayoutModel{
+			Address:   event.Address,
+			Milestone: m.Days,
+			StartDay:  streak.StartDay,
+			ClaimDay:  event.Day,
+			Amount:    m.Amount.String(),
+			Status:    StatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(payout)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // 已发放过
+		}
+		if err := s.send(payout, pay); err != nil {
+			continue // 已标记为失败，可由管理员重试
+		}
+		log.Printf("🎉 %s 连续领取 %d 天，发放奖金 %s", event.Address, m.Days, payout.Amount)
+	}
+	return nil
+}
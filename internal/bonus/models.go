@@ -0,0 +1,33 @@
+package bonus
+
+import (
+	"time"
+)
+
+// 奖金发放状态
+const (
+	StatusPending = "pending" // 已记录，等待发送
+	StatusSent    = "sent"    // 转账交易已广播
+	StatusFailed  = "failed"  // 发送失败（任务进入死信或被取消）
+)
+
+// PayoutModel GORM 连续领取奖金发放记录：每段连续领取中每个里程碑最多发放一次
+type PayoutModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Address   string    `gorm:"uniqueIndex:idx_streak_bonus_once;index;not null;column:address"`
+	Milestone int       `gorm:"uniqueIndex:idx_streak_bonus_once;not null;column:milestone"` // 连续领取天数
+	StartDay  int64     `gorm:"uniqueIndex:idx_streak_bonus_once;not null;column:start_day"` // 这段连续领取开始的 UTC 日
+	ClaimDay  int64     `gorm:"not null;column:claim_day"`                                   // 达成里程碑的领取日期
+	Amount    string    `gorm:"not null;column:amount"`                                      // QXB 最小单位
+	Status    string    `gorm:"index;not null;column:status"`
+	JobID     int64     `gorm:"column:job_id"`
+	TxHash    string    `gorm:"column:tx_hash"`
+	Error     string    `gorm:"column:error"`
+	CreatedAt time.Time `gorm:"not null;column:created_at"`
+	UpdatedAt time.Time `gorm:"not null;column:updated_at"`
+}
+
+// TableName 指定表名
+func (PayoutModel) TableName() string {
+	return "streak_bonus_payouts"
+}
@@ -0,0 +1,413 @@
+package claims
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/jobqueue"
+)
+
+const (
+	cursorName         = "daily_reward_claimed"
+	transferCursorName = "qxb_transfer"
+	confirmations      = 6                // 只索引足够确认的区块，避免重组
+	batchBlocks        = 2000             // 每次 eth_getLogs 的区块范围
+	defaultLookback    = 14400            // 未配置起始区块时从约 2 天前开始索引（12 秒出块）
+	dropAfter          = 30 * time.Minute // 交易长时间既无收据也不在交易池中时视为丢弃
+	daySeconds         = 86400
+)
+
+var (
+	// claimedTopic DailyRewardClaimed(address indexed user, uint256 amount, uint256 timestamp)
+	claimedTopic = crypto.Keccak256Hash([]byte("DailyRewardClaimed(address,uint256,uint256)"))
+	// transferTopic Transfer(address indexed from, address indexed to, uint256 value)
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// Service 索引链上 DailyRewardClaimed 与 Transfer 事件，并据此与交易收据对账领取锁：
+// 交易失败或被丢弃时释放锁，链上已领取但数据库没有锁时补齐
+type Service struct {
+	db         *gorm.DB
+	client     *ethclient.Client
+	contract   common.Address
+	auth       *auth.Service
+	jobs       *jobqueue.Queue
+	startBlock uint64 // 首次索引的起始区块，0 表示从最近约 2 天开始
+	cache      statsCache
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, client *ethclient.Client, contract common.Address, authService *auth.Service, jobs *jobqueue.Queue, startBlock uint64) (*Service, error) {
+	if err := db.AutoMigrate(&EventModel{}, &TransferModel{}, &CursorModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, client: client, contract: contract, auth: authService, jobs: jobs, startBlock: startBlock}, nil
+}
+
+// Today 当前 UTC 日，与合约的日期计算一致
+func Today() int64 {
+	return time.Now().UTC().Unix() / daySeconds
+}
+
+// Claimed 地址在指定日期是否已有链上领取事件
+func (s *Service) Claimed(address string, day int64) (*EventModel, error) {
+	var event EventModel
+	err := s.db.Where("address = ? AND day = ?", common.HexToAddress(address).Hex(), day).Limit(1).Find(&event).Error
+	if err != nil || event.ID == 0 {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Run 每隔 interval 索引新事件并对账领取锁，直到 ctx 结束
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			safe, err := s.safeHead(ctx)
+			if err != nil {
+				log.Printf("索引领取事件失败: %v", err)
+				continue
+			}
+			// 索引失败时跳过对账，避免因事件缺失误释放已在链上领取的锁
+			if err := s.scan(ctx, cursorName, claimedTopic, safe, s.storeClaims); err != nil {
+				log.Printf("索引领取事件失败: %v", err)
+				continue
+			}
+			s.reconcile(ctx)
+			if err := s.scan(ctx, transferCursorName, transferTopic, safe, s.storeTransfers); err != nil {
+				log.Printf("索引转账事件失败: %v", err)
+			}
+		}
+	}
+}
+
+// safeHead 返回已有足够确认数的最新区块
+func (s *Service) safeHead(ctx context.Context) (uint64, error) {
+	head, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取最新区块失败: %v", err)
+	}
+	if head < confirmations {
+		return 0, nil
+	}
+	return head - confirmations, nil
+}
+
+// scan 从 name 对应的进度开始分批拉取合约的 topic 事件直到 safe，由 store 在同一事务中保存；
+// store 返回的函数在事务提交后执行
+func (s *Service) scan(ctx context.Context, name string, topic common.Hash, safe uint64, store func(tx *gorm.DB, logs []types.Log) (func(), error)) error {
+	var cursor CursorModel
+	if err := s.db.Where("name = ?", name).Limit(1).Find(&cursor).Error; err != nil {
+		return err
+	}
+	from := cursor.Block + 1
+	if cursor.Name == "" {
+		from = s.startBlock
+		if from == 0 && safe > defaultLookback {
+			from = safe - defaultLookback
+		}
+	}
+
+	for from <= safe && ctx.Err() == nil {
+		to := from + batchBlocks - 1
+		if to > safe {
+			to = safe
+		}
+		logs, err := s.client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{s.contract},
+			Topics:    [][]common.Hash{{topic}},
+		})
+		if err != nil {
+			return fmt.Errorf("查询区块 %d-%d 的事件失败: %v", from, to, err)
+		}
+
+		var after func()
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if after, err = store(tx, logs); err != nil {
+				return err
+			}
+			return tx.Save(&CursorModel{Name: name, Block: to, UpdatedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("保存事件失败: %v", err)
+		}
+		if after != nil {
+			after()
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// storeClaims 保存 DailyRewardClaimed 事件，提交后补齐对应的领取锁
+func (s *Service) storeClaims(tx *gorm.DB, logs []types.Log) (func(), error) {
+	events := make([]EventModel, 0, len(logs))
+	for _, l := range logs {
+		if event, ok := parseEvent(l); ok {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&events).Error; err != nil {
+		return nil, err
+	}
+	return func() {
+		for i := range events {
+			s.backfill(&events[i])
+		}
+	}, nil
+}
+
+// storeTransfers 保存 QXB 的 Transfer 事件
+func (s *Service) storeTransfers(tx *gorm.DB, logs []types.Log) (func(), error) {
+	transfers := make([]TransferModel, 0, len(logs))
+	for _, l := range logs {
+		if len(l.Topics) != 3 || len(l.Data) != 32 || l.Removed {
+			continue
+		}
+		transfers = append(transfers, TransferModel{
+			TxHash:      l.TxHash.Hex(),
+			LogIndex:    l.Index,
+			BlockNumber: l.BlockNumber,
+			From:        common.BytesToAddress(l.Topics[1].Bytes()).Hex(),
+			To:          common.BytesToAddress(l.Topics[2].Bytes()).Hex(),
+			Value:       new(big.Int).SetBytes(l.Data).String(),
+		})
+	}
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+	return nil, tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&transfers).Error
+}
+
+func parseEvent(l types.Log) (EventModel, bool) {
+	if len(l.Topics) != 2 || len(l.Data) != 64 || l.Removed {
+		return EventModel{}, false
+	}
+	timestamp := new(big.Int).SetBytes(l.Data[32:64]).Int64()
+	return EventModel{
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+		BlockNumber: l.BlockNumber,
+		Address:     common.BytesToAddress(l.Topics[1].Bytes()).Hex(),
+		Day:         timestamp / daySeconds,
+		Amount:      new(big.Int).SetBytes(l.Data[:32]).String(),
+		ClaimedAt:   time.Unix(timestamp, 0).UTC(),
+	}, true
+}
+
+// backfill 为链上领取事件的地址补齐已确认的领取锁（包括通过外部钱包或其他途径领取的情况）
+func (s *Service) backfill(event *EventModel) {
+	refs, err := s.auth.AccountsByAddress(event.Address)
+	if err != nil {
+		log.Printf("查询地址 %s 的账户失败: %v", event.Address, err)
+		return
+	}
+	for _, ref := range refs {
+		if err := s.auth.EnsureClaimLock(ref.UserID, event.Day, ref.Account, event.Address, event.TxHash); err != nil {
+			log.Printf("补齐用户 %d 的领取锁失败: %v", ref.UserID, err)
+		}
+	}
+}
+
+// reconcile 检查今天和昨天仍在等待的领取锁
+func (s *Service) reconcile(ctx context.Context) {
+	locks, err := s.auth.PendingClaimLocks(Today()-1, 200)
+	if err != nil {
+		log.Printf("查询待确认的领取锁失败: %v", err)
+		return
+	}
+	for i := range locks {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.reconcileLock(ctx, &locks[i]); err != nil {
+			log.Printf("对账用户 %d 第 %d 天的领取锁失败: %v", locks[i].UserID, locks[i].ClaimDay, err)
+		}
+	}
+}
+
+func (s *Service) reconcileLock(ctx context.Context, lock *auth.ClaimLockModel) error {
+	address := lock.Address
+	if address == "" {
+		// 旧记录或签名前中断的请求：按账户查出地址
+		user, err := s.auth.GetByID(lock.UserID)
+		if err != nil {
+			return err
+		}
+		if address, err = s.auth.AccountAddress(user, lock.Account); err != nil {
+			return err
+		}
+	}
+
+	// 链上已有当天的领取事件
+	event, err := s.Claimed(address, lock.ClaimDay)
+	if err != nil {
+		return err
+	}
+	if event != nil {
+		return s.auth.ConfirmClaimLock(lock.UserID, lock.ClaimDay, lock.Account, event.TxHash)
+	}
+
+	if lock.TxHash == "" {
+		// 没有关联交易：请求在签名前失败且未回滚
+		if time.Since(lock.CreatedAt) > dropAfter {
+			return s.release(lock, "未关联领取交易")
+		}
+		return nil
+	}
+
+	// 交易仍在发送队列中时等待，发送失败则释放
+	if lock.JobID != 0 {
+		job, err := s.jobs.Get(lock.JobID)
+		if err != nil && !errors.Is(err, jobqueue.ErrNotFound) {
+			return err
+		}
+		if job != nil {
+			switch job.Status {
+			case jobqueue.StatusDead, jobqueue.StatusCanceled:
+				return s.release(lock, fmt.Sprintf("领取交易未能发送: %s", job.LastError))
+			case jobqueue.StatusQueued, jobqueue.StatusRunning:
+				return nil
+			}
+		}
+	}
+
+	hash := common.HexToHash(lock.TxHash)
+	receipt, err := s.client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			// 事件尚未达到索引所需的确认数，先按收据确认
+			return s.auth.ConfirmClaimLock(lock.UserID, lock.ClaimDay, lock.Account, "")
+		}
+		return s.release(lock, fmt.Sprintf("领取交易执行失败: %s", lock.TxHash))
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	if _, _, err := s.client.TransactionByHash(ctx, hash); err == nil {
+		return nil
+	} else if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	linkedAt := lock.CreatedAt
+	if lock.LinkedAt != nil {
+		linkedAt = *lock.LinkedAt
+	}
+	if time.Since(linkedAt) > dropAfter {
+		return s.release(lock, fmt.Sprintf("领取交易已被丢弃: %s", lock.TxHash))
+	}
+	return nil
+}
+
+func (s *Service) release(lock *auth.ClaimLockModel, reason string) error {
+	log.Printf("释放用户 %d 第 %d 天的领取锁（账户 %q）: %s", lock.UserID, lock.ClaimDay, lock.Account, reason)
+	return s.auth.RemoveClaimLock(lock.UserID, lock.ClaimDay, lock.Account)
+}
+
+// Streak 地址的连续领取统计（按链上领取事件的 UTC 日计算）
+type Streak struct {
+	Current  int   // 当前连续领取天数：最后一次领取是今天或昨天时有效，否则为 0
+	Longest  int   // 历史最长连续领取天数
+	StartDay int64 // 当前连续领取开始的日期，Current 为 0 时无意义
+	LastDay  int64 // 最后一次领取的日期，从未领取为 0
+}
+
+// Streak 计算地址截至今天的连续领取天数；今天尚未领取时，昨天领取过仍算连续
+func (s *Service) Streak(address string) (*Streak, error) {
+	today := Today()
+	streak, err := s.streak(address, today)
+	if err != nil {
+		return nil, err
+	}
+	if streak.LastDay < today-1 {
+		streak.Current = 0
+	}
+	return streak, nil
+}
+
+// StreakAt 计算地址截至 day（含）的连续领取天数；day 当天没有领取时 Current 为 0
+func (s *Service) StreakAt(address string, day int64) (*Streak, error) {
+	streak, err := s.streak(address, day)
+	if err != nil {
+		return nil, err
+	}
+	if streak.LastDay != day {
+		streak.Current = 0
+	}
+	return streak, nil
+}
+
+func (s *Service) streak(address string, upTo int64) (*Streak, error) {
+	var days []int64
+	err := s.db.Model(&EventModel{}).
+		Where("address = ? AND day <= ?", common.HexToAddress(address).Hex(), upTo).
+		Distinct("day").Order("day").Pluck("day", &days).Error
+	if err != nil {
+		return nil, err
+	}
+	streak := &Streak{}
+	for i, day := range days {
+		if i == 0 || day != days[i-1]+1 {
+			streak.Current = 0
+			streak.StartDay = day
+		}
+		streak.Current++
+		if streak.Current > streak.Longest {
+			streak.Longest = streak.Current
+		}
+		streak.LastDay = day
+	}
+	return streak, nil
+}
+
+// EventsAfter 按 ID 顺序列出 afterID 之后索引的领取事件，供其他模块增量处理
+func (s *Service) EventsAfter(afterID int64, limit int) ([]EventModel, error) {
+	var events []EventModel
+	err := s.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// Cursor 读取增量处理进度，ok 为 false 表示尚未开始
+func (s *Service) Cursor(name string) (position uint64, ok bool, err error) {
+	var cursor CursorModel
+	if err := s.db.Where("name = ?", name).Limit(1).Find(&cursor).Error; err != nil {
+		return 0, false, err
+	}
+	return cursor.Block, cursor.Name != "", nil
+}
+
+// SaveCursor 保存增量处理进度
+func (s *Service) SaveCursor(name string, position uint64) error {
+	return s.db.Save(&CursorModel{Name: name, Block: position, UpdatedAt: time.Now()}).Error
+}
+
+// LastEventID 最新索引的领取事件 ID，没有事件时为 0
+func (s *Service) LastEventID() (int64, error) {
+	var event EventModel
+	err := s.db.Order("id DESC").Limit(1).Find(&event).Error
+	return event.ID, err
+}
@@ -0,0 +1,50 @@
+package claims
+
+import (
+	"time"
+)
+
+// EventModel GORM 链上 DailyRewardClaimed 事件模型
+type EventModel struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	TxHash      string    `gorm:"uniqueIndex:idx_reward_claim_log;not null;column:tx_hash"`
+	LogIndex    uint      `gorm:"uniqueIndex:idx_reward_claim_log;not null;column:log_index"`
+	BlockNumber uint64    `gorm:"index;not null;column:block_number"`
+	Address     string    `gorm:"index:idx_reward_claim_address_day;not null;column:address"`
+	Day         int64     `gorm:"index:idx_reward_claim_address_day;index;not null;column:day"` // UTC 日（区块时间 / 86400），与合约的 lastClaimDay 一致
+	Amount      string    `gorm:"not null;column:amount"`                                       // wei
+	ClaimedAt   time.Time `gorm:"not null;column:claimed_at"`                                   // 区块时间
+}
+
+// TableName 指定表名
+func (EventModel) TableName() string {
+	return "reward_claim_events"
+}
+
+// TransferModel GORM 链上 QXB Transfer 事件模型（铸造时 From 为零地址）
+type TransferModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement"`
+	TxHash      string `gorm:"uniqueIndex:idx_token_transfer_log;not null;column:tx_hash"`
+	LogIndex    uint   `gorm:"uniqueIndex:idx_token_transfer_log;not null;column:log_index"`
+	BlockNumber uint64 `gorm:"index;not null;column:block_number"`
+	From        string `gorm:"index;not null;column:from_address"`
+	To          string `gorm:"index;not null;column:to_address"`
+	Value       string `gorm:"not null;column:value"` // QXB 最小单位
+}
+
+// TableName 指定表名
+func (TransferModel) TableName() string {
+	return "token_transfers"
+}
+
+// CursorModel GORM 事件索引进度模型
+type CursorModel struct {
+	Name      string    `gorm:"primaryKey;column:name"`
+	Block     uint64    `gorm:"not null;column:block"` // 已处理到的位置（含）：事件索引为区块号，增量处理事件的模块为事件 ID
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName 指定表名
+func (CursorModel) TableName() string {
+	return "index_cursors"
+}
@@ -0,0 +1,327 @@
+package claims
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// statsCacheTTL 排行榜与统计的缓存时间；事件每 30 秒索引一次，缓存略长于索引间隔即可
+const statsCacheTTL = time.Minute
+
+// 排行榜排序方式
+const (
+	RankByClaims  = "claims"  // 窗口内领取次数
+	RankByStreak  = "streak"  // 当前连续领取天数
+	RankByBalance = "balance" // 由 Transfer 事件计算的 QXB 余额
+)
+
+// LeaderboardEntry 排行榜条目
+type LeaderboardEntry struct {
+	Rank    int      // 并列时名次相同（1, 2, 2, 4）
+	Address string   // 校验和地址
+	Claims  int      // 窗口内领取次数
+	Streak  int      // 当前连续领取天数
+	Balance *big.Int // QXB 余额（最小单位）
+}
+
+// DailyStats 某一天的领取统计
+type DailyStats struct {
+	Day               int64    // UTC 日
+	Claimers          int      // 领取的地址数
+	NewClaimers       int      // 当天首次领取的地址数
+	ReturningClaimers int      // 之前领取过的地址数
+	Minted            *big.Int // 当天通过领取铸造的 QXB
+	TotalMinted       *big.Int // 截至当天累计铸造的 QXB
+}
+
+// Stats 领取统计
+type Stats struct {
+	TotalClaims   int
+	TotalClaimers int
+	TotalMinted   *big.Int // 通过每日奖励累计铸造的 QXB
+	Days          []DailyStats
+}
+
+// statsCache 缓存计算结果；所有条目在 statsCacheTTL 后过期
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+func (c *statsCache) get(key string, compute func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expiresAt) {
+		return e.value, nil
+	}
+	value, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{value: value, expiresAt: time.Now().Add(statsCacheTTL)}
+	return value, nil
+}
+
+// Leaderboard 按 by 排序的完整排行榜（只包含该指标大于 0 的地址），结果缓存 statsCacheTTL
+// windowDays 为统计领取次数的天数（含今天），0 表示全部历史；连续天数与余额不受窗口影响
+func (s *Service) Leaderboard(by string, windowDays int) ([]LeaderboardEntry, error) {
+	switch by {
+	case RankByClaims, RankByStreak, RankByBalance:
+	default:
+		return nil, fmt.Errorf("无效的排序方式 %q", by)
+	}
+	key := fmt.Sprintf("leaderboard:%s:%d", by, windowDays)
+	value, err := s.cache.get(key, func() (interface{}, error) {
+		return s.computeLeaderboard(by, windowDays)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]LeaderboardEntry), nil
+}
+
+func (s *Service) computeLeaderboard(by string, windowDays int) ([]LeaderboardEntry, error) {
+	entries := make(map[string]*LeaderboardEntry)
+	entry := func(address string) *LeaderboardEntry {
+		e, ok := entries[address]
+		if !ok {
+			e = &LeaderboardEntry{Address: address, Balance: new(big.Int)}
+			entries[address] = e
+		}
+		return e
+	}
+
+	// 领取次数与当前连续天数
+	today := Today()
+	var rows []struct {
+		Address string
+		Day     int64
+	}
+	if err := s.db.Model(&EventModel{}).Distinct("address", "day").Order("address, day").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		e := entry(row.Address)
+		if windowDays == 0 || row.Day > today-int64(windowDays) {
+			e.Claims++
+		}
+		if i > 0 && rows[i-1].Address == row.Address && rows[i-1].Day == row.Day-1 {
+			e.Streak++
+		} else {
+			e.Streak = 1
+		}
+	}
+	for i, row := range rows {
+		// 每个地址的最后一天决定连续是否仍然有效
+		if i+1 < len(rows) && rows[i+1].Address == row.Address {
+			continue
+		}
+		if row.Day < today-1 {
+			entries[row.Address].Streak = 0
+		}
+	}
+
+	// 余额
+	if by == RankByBalance {
+		balances, err := s.balances()
+		if err != nil {
+			return nil, err
+		}
+		for address, balance := range balances {
+			entry(address).Balance = balance
+		}
+	}
+
+	metric := func(e *LeaderboardEntry) *big.Int {
+		switch by {
+		case RankByClaims:
+			return big.NewInt(int64(e.Claims))
+		case RankByStreak:
+			return big.NewInt(int64(e.Streak))
+		default:
+			return e.Balance
+		}
+	}
+	list := make([]LeaderboardEntry, 0, len(entries))
+	for _, e := range entries {
+		if metric(e).Sign() > 0 {
+			list = append(list, *e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if c := metric(&list[i]).Cmp(metric(&list[j])); c != 0 {
+			return c > 0
+		}
+		return list[i].Address < list[j].Address
+	})
+	for i := range list {
+		list[i].Rank = i + 1
+		if i > 0 && metric(&list[i]).Cmp(metric(&list[i-1])) == 0 {
+			list[i].Rank = list[i-1].Rank
+		}
+	}
+	return list, nil
+}
+
+// balances 根据 Transfer 事件计算各地址的余额（零地址除外）；需要从合约部署区块开始索引才准确
+func (s *Service) balances() (map[string]*big.Int, error) {
+	balances := make(map[string]*big.Int)
+	add := func(address string, delta *big.Int) {
+		if address == zeroAddress {
+			return
+		}
+		b, ok := balances[address]
+		if !ok {
+			b = new(big.Int)
+			balances[address] = b
+		}
+		b.Add(b, delta)
+	}
+
+	rows, err := s.db.Model(&TransferModel{}).Select("from_address, to_address, value").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from, to, value string
+		if err := rows.Scan(&from, &to, &value); err != nil {
+			return nil, err
+		}
+		amount, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			continue
+		}
+		add(to, amount)
+		add(from, new(big.Int).Neg(amount))
+	}
+	return balances, rows.Err()
+}
+
+var zeroAddress = common.Address{}.Hex()
+
+// Stats 最近 days 天（含今天）的每日领取统计与累计数据，结果缓存 statsCacheTTL
+func (s *Service) Stats(days int) (*Stats, error) {
+	value, err := s.cache.get(fmt.Sprintf("stats:%d", days), func() (interface{}, error) {
+		return s.computeStats(days)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Stats), nil
+}
+
+func (s *Service) computeStats(days int) (*Stats, error) {
+	today := Today()
+	first := today - int64(days) + 1
+	stats := &Stats{TotalMinted: new(big.Int)}
+	series := make([]DailyStats, days)
+	for i := range series {
+		series[i] = DailyStats{Day: first + int64(i), Minted: new(big.Int), TotalMinted: new(big.Int)}
+	}
+
+	// 按日期顺序遍历所有领取事件：首次出现的地址为新领取者
+	seen := make(map[string]bool)
+	claimedOn := make(map[string]int64) // 地址最近一次计入的日期，避免同一天重复计数
+	rows, err := s.db.Model(&EventModel{}).Select("address, day, amount").Order("day, id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var address, amount string
+		var day int64
+		if err := rows.Scan(&address, &day, &amount); err != nil {
+			return nil, err
+		}
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			value = new(big.Int)
+		}
+		stats.TotalClaims++
+		stats.TotalMinted.Add(stats.TotalMinted, value)
+
+		returning := seen[address]
+		seen[address] = true
+		if day < first || day > today {
+			continue
+		}
+		d := &series[day-first]
+		d.Minted.Add(d.Minted, value)
+		if last, ok := claimedOn[address]; ok && last == day {
+			continue
+		}
+		claimedOn[address] = day
+		d.Claimers++
+		if returning {
+			d.ReturningClaimers++
+		} else {
+			d.NewClaimers++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats.TotalClaimers = len(seen)
+
+	// 累计铸造量：窗口之前的总量加上窗口内逐日累加
+	var before = new(big.Int).Set(stats.TotalMinted)
+	for i := range series {
+		before.Sub(before, series[i].Minted)
+	}
+	for i := range series {
+		before.Add(before, series[i].Minted)
+		series[i].TotalMinted.Set(before)
+	}
+	stats.Days = series
+	return stats, nil
+}
+
+// ClaimsSince 列出 day（含）之后的领取事件，按区块排序
+func (s *Service) ClaimsSince(day int64) ([]EventModel, error) {
+	var events []EventModel
+	err := s.db.Where("day >= ?", day).Order("block_number, log_index").Find(&events).Error
+	return events, err
+}
+
+// SinkSources 找出接收过至少 minSources 个不同领取地址转出 QXB 的地址（归集地址），返回归集地址到转出地址的映射
+// 零地址（销毁）与合约地址不计入
+func (s *Service) SinkSources(minSources int) (map[string][]string, error) {
+	var rows []struct {
+		FromAddress string
+		ToAddress   string
+	}
+	claimers := s.db.Model(&EventModel{}).Distinct("address")
+	err := s.db.Model(&TransferModel{}).Distinct("from_address", "to_address").
+		Where("from_address IN (?)", claimers).
+		Where("to_address NOT IN ?", []string{zeroAddress, s.contract.Hex()}).
+		Order("to_address, from_address").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string][]string)
+	for _, row := range rows {
+		if row.FromAddress != row.ToAddress {
+			sources[row.ToAddress] = append(sources[row.ToAddress], row.FromAddress)
+		}
+	}
+	for sink, from := range sources {
+		if len(from) < minSources {
+			delete(sources, sink)
+		}
+	}
+	return sources, nil
+}
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"math/big"
+	"net/url"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +28,9 @@ const (
 
 	// SQLite 数据库路径
 	DefaultDBPath = "data/app.db"
+
+	// 默认链 ID（Sepolia）
+	DefaultChainID = 11155111
 )
 
 var (
@@ -54,12 +63,429 @@ func GetDBPath() string {
 	return DefaultDBPath
 }
 
-// GetJWTSecret 获取 JWT 密钥
+// GetJWTSecret 获取 JWT 密钥（HS256，未配置 JWT_KEYS_FILE 时使用）
 func GetJWTSecret() string {
 	LoadEnv()
 	return os.Getenv("JWT_SECRET")
 }
 
+// GetJWTKeysFile 获取 JWT 密钥集配置文件路径（支持 HS256 / EdDSA / ES256 与 kid 轮换）
+func GetJWTKeysFile() string {
+	LoadEnv()
+	return os.Getenv("JWT_KEYS_FILE")
+}
+
+// GetWalletUnlockTTL 获取钱包解锁会话的默认有效期（WALLET_UNLOCK_TTL，如 "5m"），默认 5 分钟
+func GetWalletUnlockTTL() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("WALLET_UNLOCK_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 5 * time.Minute
+}
+
+// GetAccessTokenTTL 获取 access token 有效期（ACCESS_TOKEN_TTL），默认 15 分钟
+func GetAccessTokenTTL() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("ACCESS_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 15 * time.Minute
+}
+
+// GetIdempotencyKeyTTL 获取 Idempotency-Key 的有效期（IDEMPOTENCY_KEY_TTL），默认 24 小时，期间重复请求重放首次响应
+func GetIdempotencyKeyTTL() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 24 * time.Hour
+}
+
+// GetRefreshTokenTTL 获取登录会话（刷新令牌）有效期（REFRESH_TOKEN_TTL，如 "720h"），默认 30 天
+func GetRefreshTokenTTL() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("REFRESH_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 30 * 24 * time.Hour
+}
+
+// GetWithdrawCoolingOff 获取绑定外部钱包后的默认提现冷静期（WITHDRAW_COOLING_OFF），默认 24 小时
+func GetWithdrawCoolingOff() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("WITHDRAW_COOLING_OFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 24 * time.Hour
+}
+
+// GetWithdrawMinCoolingOff 获取用户可设置的最短提现冷静期（WITHDRAW_MIN_COOLING_OFF），默认 1 小时
+func GetWithdrawMinCoolingOff() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("WITHDRAW_MIN_COOLING_OFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return time.Hour
+}
+
+// GetArgonMaxConcurrency 获取同时运行的 Argon2 计算上限（ARGON2_MAX_CONCURRENCY），默认 CPU 核数
+// 每次计算占用 64 MiB 内存，上限决定了登录/注册突发请求的峰值内存
+func GetArgonMaxConcurrency() int {
+	LoadEnv()
+	if v := os.Getenv("ARGON2_MAX_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// getWei 读取以 wei 为单位的金额配置，无效或未设置时使用默认值
+func getWei(name, def string) *big.Int {
+	LoadEnv()
+	if v := os.Getenv(name); v != "" {
+		if n, ok := new(big.Int).SetString(v, 10); ok && n.Sign() >= 0 {
+			return n
+		}
+	}
+	n, _ := new(big.Int).SetString(def, 10)
+	return n
+}
+
+// GetFaucetThreshold 获取自动补贴 Gas 的余额阈值（FAUCET_THRESHOLD，wei），默认 0.001 ETH
+func GetFaucetThreshold() *big.Int {
+	return getWei("FAUCET_THRESHOLD", "1000000000000000")
+}
+
+// GetFaucetTopUp 获取每次补贴的 ETH 金额（FAUCET_TOPUP，wei），默认 0.002 ETH
+func GetFaucetTopUp() *big.Int {
+	return getWei("FAUCET_TOPUP", "2000000000000000")
+}
+
+// GetFaucetUserDailyBudget 获取每个用户每天的补贴上限（FAUCET_USER_DAILY_BUDGET，wei，0 不限），默认 0.006 ETH
+func GetFaucetUserDailyBudget() *big.Int {
+	return getWei("FAUCET_USER_DAILY_BUDGET", "6000000000000000")
+}
+
+// GetFaucetDailyBudget 获取全站每天的补贴上限（FAUCET_DAILY_BUDGET，wei，0 不限），默认 0.2 ETH
+func GetFaucetDailyBudget() *big.Int {
+	return getWei("FAUCET_DAILY_BUDGET", "200000000000000000")
+}
+
+// GetFaucetTotalBudget 获取全站累计补贴上限（FAUCET_TOTAL_BUDGET，wei，0 不限），默认不限
+func GetFaucetTotalBudget() *big.Int {
+	return getWei("FAUCET_TOTAL_BUDGET", "0")
+}
+
+// GetFaucetCooldown 获取同一用户两次补贴的最短间隔（FAUCET_COOLDOWN），默认 10 分钟
+func GetFaucetCooldown() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("FAUCET_COOLDOWN"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 10 * time.Minute
+}
+
+// GetJobWorkers 获取链上交易任务队列的 worker 数量（JOB_WORKERS），默认 4
+// 同一发送地址的任务始终串行执行，worker 数只影响不同地址之间的并发
+func GetJobWorkers() int {
+	LoadEnv()
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 4
+}
+
+// GetJobMaxAttempts 获取任务最多执行次数（JOB_MAX_ATTEMPTS），默认 5，用尽后进入死信
+func GetJobMaxAttempts() int {
+	LoadEnv()
+	if v := os.Getenv("JOB_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 5
+}
+
+// GetClaimIndexStartBlock 获取领取事件索引的起始区块（CLAIM_INDEX_START_BLOCK），通常为合约部署区块
+// 仅在首次索引时使用；未设置时从最近约 2 天的区块开始
+func GetClaimIndexStartBlock() uint64 {
+	LoadEnv()
+	if v := os.Getenv("CLAIM_INDEX_START_BLOCK"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// GetAutoClaimSecret 获取自动领取委托密钥（AUTOCLAIM_SECRET），用于加密用户授权的领取私钥
+// 未设置时不能开启自动领取；更换后已有的委托无法解密，需要用户重新开启
+func GetAutoClaimSecret() string {
+	LoadEnv()
+	return os.Getenv("AUTOCLAIM_SECRET")
+}
+
+// GetAutoClaimJitter 获取自动领取在每个 UTC 日开始后随机延迟的上限（AUTOCLAIM_JITTER），默认 30 分钟
+func GetAutoClaimJitter() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("AUTOCLAIM_JITTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 30 * time.Minute
+}
+
+// GetStreakBonuses 获取连续领取奖金里程碑（STREAK_BONUSES），格式为 "天数:金额,..."，金额为 QXB 最小单位
+// 如 "7:1000000000000000000,30:5000000000000000000,100:20000000000000000000"；未设置时不发放奖金
+func GetStreakBonuses() string {
+	LoadEnv()
+	return os.Getenv("STREAK_BONUSES")
+}
+
+// GetRiskFlagScore 获取风控标记为可疑的分数（RISK_FLAG_SCORE），默认 40；设为 0 不标记
+func GetRiskFlagScore() int {
+	return riskScore("RISK_FLAG_SCORE", 40)
+}
+
+// GetRiskBlockScore 获取风控限制领取的分数（RISK_BLOCK_SCORE），默认 100；设为 0 不自动限制
+func GetRiskBlockScore() int {
+	return riskScore("RISK_BLOCK_SCORE", 100)
+}
+
+func riskScore(name string, fallback int) int {
+	LoadEnv()
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// GetRiskDisposableDomains 获取额外的一次性邮箱域名（RISK_DISPOSABLE_DOMAINS，逗号分隔），追加到内置列表
+func GetRiskDisposableDomains() []string {
+	LoadEnv()
+	var domains []string
+	for _, d := range strings.Split(os.Getenv("RISK_DISPOSABLE_DOMAINS"), ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, strings.ToLower(d))
+		}
+	}
+	return domains
+}
+
+// GetPoWSecret 获取工作量证明挑战的 HMAC 密钥（POW_SECRET）；未设置时每次启动随机生成，重启后未完成的挑战失效
+func GetPoWSecret() string {
+	LoadEnv()
+	return os.Getenv("POW_SECRET")
+}
+
+// GetPoWDifficulty 获取工作量证明的基础难度（POW_DIFFICULTY，SHA-256 前导零位数），默认 0 表示不启用
+func GetPoWDifficulty() int {
+	LoadEnv()
+	if v := os.Getenv("POW_DIFFICULTY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 32 {
+			return n
+		}
+	}
+	return 0
+}
+
+// GetPoWMaxDifficulty 获取请求量升高时的难度上限（POW_MAX_DIFFICULTY），默认基础难度加 6
+func GetPoWMaxDifficulty() int {
+	LoadEnv()
+	if v := os.Getenv("POW_MAX_DIFFICULTY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 32 {
+			return n
+		}
+	}
+	return GetPoWDifficulty() + 6
+}
+
+// GetPoWTTL 获取工作量证明挑战的有效期（POW_TTL），默认 2 分钟
+func GetPoWTTL() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("POW_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 2 * time.Minute
+}
+
+// GetPoWTargetRate 获取每个操作 10 分钟内不提高难度的请求量（POW_TARGET_RATE），默认 60；超过后每翻一倍难度加 1，0 表示固定难度
+func GetPoWTargetRate() int {
+	LoadEnv()
+	if v := os.Getenv("POW_TARGET_RATE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return 60
+}
+
+// GetChainID 获取部署所在链的 ID（CHAIN_ID），默认 Sepolia
+func GetChainID() uint64 {
+	LoadEnv()
+	if v := os.Getenv("CHAIN_ID"); v != "" {
+		if id, err := strconv.ParseUint(v, 10, 64); err == nil && id > 0 {
+			return id
+		}
+	}
+	return DefaultChainID
+}
+
+// GetSIWEDomain 获取 SIWE 登录允许的 domain（SIWE_DOMAIN，如 "app.example.com"），默认取 APP_BASE_URL 的主机（含端口）
+// 无法确定时返回空字符串，服务启动失败
+func GetSIWEDomain() string {
+	LoadEnv()
+	if v := strings.TrimSpace(os.Getenv("SIWE_DOMAIN")); v != "" {
+		return v
+	}
+	if u, err := url.Parse(GetAppBaseURL()); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// GetAppBaseURL 获取服务对外访问地址（APP_BASE_URL），用于生成邮件中的链接，默认 http://localhost:8080
+func GetAppBaseURL() string {
+	LoadEnv()
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// GetMagicLinkURL 获取免密登录链接指向的前端页面（MAGIC_LINK_URL），令牌以 ?token= 附加
+// 默认 APP_BASE_URL + /login/magic
+func GetMagicLinkURL() string {
+	LoadEnv()
+	if v := os.Getenv("MAGIC_LINK_URL"); v != "" {
+		return v
+	}
+	return GetAppBaseURL() + "/login/magic"
+}
+
+// SMTPConfig SMTP 发信配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// GetSMTPConfig 获取 SMTP 配置（SMTP_HOST、SMTP_PORT、SMTP_USERNAME、SMTP_PASSWORD），
+// Host 为空表示未配置，端口默认 587
+func GetSMTPConfig() SMTPConfig {
+	LoadEnv()
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     587,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if port, err := strconv.Atoi(v); err == nil && port > 0 {
+			cfg.Port = port
+		}
+	}
+	return cfg
+}
+
+// GetMailFrom 获取发件人地址（MAIL_FROM）
+func GetMailFrom() string {
+	LoadEnv()
+	if v := os.Getenv("MAIL_FROM"); v != "" {
+		return v
+	}
+	return "no-reply@localhost"
+}
+
+// GetMailFile 获取邮件输出文件（MAIL_FILE），未配置 SMTP 时把邮件写入该文件，便于开发测试
+func GetMailFile() string {
+	LoadEnv()
+	return os.Getenv("MAIL_FILE")
+}
+
+// GetWebAuthnRPID 获取通行密钥的 RP ID（WEBAUTHN_RP_ID，如 "app.example.com"），默认取 APP_BASE_URL 的主机名
+func GetWebAuthnRPID() string {
+	LoadEnv()
+	if v := os.Getenv("WEBAUTHN_RP_ID"); v != "" {
+		return v
+	}
+	if u, err := url.Parse(GetAppBaseURL()); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "localhost"
+}
+
+// GetWebAuthnRPName 获取认证器中显示的服务名称（WEBAUTHN_RP_NAME），默认 QXB
+func GetWebAuthnRPName() string {
+	LoadEnv()
+	if v := os.Getenv("WEBAUTHN_RP_NAME"); v != "" {
+		return v
+	}
+	return "QXB"
+}
+
+// GetWebAuthnOrigins 获取允许发起通行密钥流程的前端来源（WEBAUTHN_RP_ORIGINS，逗号分隔），默认 APP_BASE_URL
+func GetWebAuthnOrigins() []string {
+	LoadEnv()
+	var origins []string
+	for _, o := range strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{GetAppBaseURL()}
+	}
+	return origins
+}
+
+// GetAdminEmails 获取管理员邮箱列表（ADMIN_EMAILS，逗号分隔）
+func GetAdminEmails() []string {
+	LoadEnv()
+	var emails []string
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emails = append(emails, strings.ToLower(e))
+		}
+	}
+	return emails
+}
+
+// GetEscrowRecoveryDelay 获取私钥恢复的冷静期（ESCROW_RECOVERY_DELAY），期间不能完成恢复，账户所有者可以取消，默认 48 小时
+func GetEscrowRecoveryDelay() time.Duration {
+	LoadEnv()
+	if v := os.Getenv("ESCROW_RECOVERY_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return 48 * time.Hour
+}
+
 // GetPrivateKey 从环境变量获取私钥
 // ⚠️⚠️⚠️ 安全警告 ⚠️⚠️⚠️
 // 私钥是非常敏感的信息，必须通过环境变量设置！
@@ -0,0 +1,419 @@
+package escrow
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"gorm.io/gorm"
+)
+
+// privateKeyLen 托管密钥材料中私钥的长度
+const privateKeyLen = 32
+
+var (
+	// ErrNotEnrolled 用户未开启私钥托管
+	ErrNotEnrolled = errors.New("用户未开启私钥托管")
+	// ErrRecoveryClosed 恢复流程不存在或已结束
+	ErrRecoveryClosed = errors.New("恢复流程不存在或已结束")
+	// ErrRecoveryActive 用户已有进行中的恢复流程
+	ErrRecoveryActive = errors.New("已有进行中的恢复流程")
+	// ErrInvalidRecoveryToken 恢复令牌无效
+	ErrInvalidRecoveryToken = errors.New("恢复令牌无效")
+)
+
+// Service 负责私钥的 Shamir 拆分托管与恢复
+type Service struct {
+	db            *gorm.DB
+	recoveryDelay time.Duration // 发起恢复后的冷静期，期间不能完成恢复，账户所有者可以取消
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, recoveryDelay time.Duration) (*Service, error) {
+	s := &Service{db: db, recoveryDelay: recoveryDelay}
+	if err := s.db.AutoMigrate(&TrusteeModel{}, &EscrowModel{}, &ShareModel{}, &RecoveryModel{}, &SubmissionModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移托管表结构失败: %w", err)
+	}
+	return s, nil
+}
+
+// AddTrustee 添加受托人，publicKeyHex 支持压缩或未压缩的 secp256k1 公钥
+func (s *Service) AddTrustee(name, publicKeyHex string) (*TrusteeModel, error) {
+	pub, err := parsePublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	trustee := &TrusteeModel{
+		Name:      name,
+		PublicKey: hex.EncodeToString(crypto.FromECDSAPub(pub)),
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(trustee).Error; err != nil {
+		return nil, err
+	}
+	return trustee, nil
+}
+
+// ListTrustees 列出所有受托人
+func (s *Service) ListTrustees() ([]TrusteeModel, error) {
+	var trustees []TrusteeModel
+	err := s.db.Order("id").Find(&trustees).Error
+	return trustees, err
+}
+
+// GetEscrow 获取用户的托管配置
+func (s *Service) GetEscrow(userID int64) (*EscrowModel, error) {
+	var e EscrowModel
+	if err := s.db.First(&e, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Enroll 将钱包密钥材料（前 32 字节为私钥，其后可附带助记词熵）拆分为 len(trusteeIDs) 份，分别加密给受托人；
+// 重复调用会替换旧的份额
+func (s *Service) Enroll(userID int64, address string, secret []byte, trusteeIDs []int64, threshold int) error {
+	if len(secret) < privateKeyLen {
+		return errors.New("私钥长度无效")
+	}
+	var trustees []TrusteeModel
+	if err := s.db.Where("id IN ?", trusteeIDs).Find(&trustees).Error; err != nil {
+		return err
+	}
+	if len(trustees) != len(trusteeIDs) {
+		return errors.New("受托人不存在或重复")
+	}
+
+	shares, err := Split(secret, len(trustees), threshold)
+	if err != nil {
+		return err
+	}
+
+	models := make([]ShareModel, len(trustees))
+	for i, t := range trustees {
+		pub, err := parsePublicKey(t.PublicKey)
+		if err != nil {
+			return fmt.Errorf("受托人 %d 公钥无效: %w", t.ID, err)
+		}
+		enc, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), shares[i], nil, nil)
+		if err != nil {
+			return fmt.Errorf("加密份额失败: %w", err)
+		}
+		models[i] = ShareModel{
+			UserID:      userID,
+			TrusteeID:   t.ID,
+			EncShareB64: base64.StdEncoding.EncodeToString(enc),
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&ShareModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&EscrowModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&EscrowModel{
+			UserID:    userID,
+			Address:   address,
+			Threshold: threshold,
+			Total:     len(trustees),
+			CreatedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models).Error
+	})
+}
+
+// StartRecovery 为用户发起恢复流程，同一用户同时只能有一个进行中的流程
+// 返回的 token 只出现这一次，用于在达到门限且冷静期结束后取回私钥，调用方只能把它发送到账户的已验证邮箱
+func (s *Service) StartRecovery(userID int64) (*RecoveryModel, string, error) {
+	if _, err := s.GetEscrow(userID); err != nil {
+		return nil, "", err
+	}
+	active, err := s.ActiveRecovery(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if active != nil {
+		return nil, "", ErrRecoveryActive
+	}
+
+	ephemeral, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	recovery := &RecoveryModel{
+		UserID:     userID,
+		Status:     RecoveryPending,
+		PublicKey:  hex.EncodeToString(crypto.FromECDSAPub(&ephemeral.PublicKey)),
+		CreatedAt:  now,
+		CompleteAt: now.Add(s.recoveryDelay),
+	}
+	if err := s.db.Create(recovery).Error; err != nil {
+		return nil, "", err
+	}
+	return recovery, hex.EncodeToString(crypto.FromECDSA(ephemeral)), nil
+}
+
+// ActiveRecovery 用户进行中（pending 或 ready）的恢复流程，没有时返回 nil
+func (s *Service) ActiveRecovery(userID int64) (*RecoveryModel, error) {
+	var recovery RecoveryModel
+	err := s.db.Where("user_id = ? AND status IN ?", userID, []string{RecoveryPending, RecoveryReady}).
+		Order("id DESC").Limit(1).Find(&recovery).Error
+	if err != nil {
+		return nil, err
+	}
+	if recovery.ID == 0 {
+		return nil, nil
+	}
+	return &recovery, nil
+}
+
+// GetRecovery 查询恢复流程及各受托人的加密份额（份额只有受托人能解密）
+func (s *Service) GetRecovery(recoveryID int64) (*RecoveryModel, []ShareModel, error) {
+	var recovery RecoveryModel
+	if err := s.db.First(&recovery, recoveryID).Error; err != nil {
+		return nil, nil, ErrRecoveryClosed
+	}
+	var shares []ShareModel
+	if err := s.db.Where("user_id = ?", recovery.UserID).Order("trustee_id").Find(&shares).Error; err != nil {
+		return nil, nil, err
+	}
+	return &recovery, shares, nil
+}
+
+// CancelRecovery 取消进行中的恢复流程：userID 为账户所有者（已登录）时无需令牌，否则需要恢复令牌
+func (s *Service) CancelRecovery(recoveryID, userID int64, token string) (*RecoveryModel, error) {
+	var recovery RecoveryModel
+	if err := s.db.First(&recovery, recoveryID).Error; err != nil {
+		return nil, ErrRecoveryClosed
+	}
+	if recovery.Status != RecoveryPending && recovery.Status != RecoveryReady {
+		return nil, ErrRecoveryClosed
+	}
+	if userID != recovery.UserID {
+		if _, err := recoveryKey(&recovery, token); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&recovery).Updates(map[string]interface{}{
+			"status":       RecoveryCancelled,
+			"enc_key":      "",
+			"cancelled_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("recovery_id = ?", recoveryID).Delete(&SubmissionModel{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	recovery.Status = RecoveryCancelled
+	recovery.CancelledAt = &now
+	return &recovery, nil
+}
+
+// recoveryKey 校验恢复令牌（临时私钥）与恢复流程的临时公钥是否匹配
+func recoveryKey(recovery *RecoveryModel, token string) (*ecdsa.PrivateKey, error) {
+	ephemeral, err := crypto.HexToECDSA(strings.TrimPrefix(token, "0x"))
+	if err != nil || !strings.EqualFold(hex.EncodeToString(crypto.FromECDSAPub(&ephemeral.PublicKey)), recovery.PublicKey) {
+		return nil, ErrInvalidRecoveryToken
+	}
+	return ephemeral, nil
+}
+
+// RecoveryMessage 受托人提交份额时需要签名（personal_sign）的消息
+func RecoveryMessage(recoveryID int64, share []byte) string {
+	return fmt.Sprintf("QXB escrow recovery %d: %x", recoveryID, share)
+}
+
+// SubmitShare 受托人提交解密后的份额，signature 为受托人私钥对 RecoveryMessage 的签名
+// 达到门限后恢复私钥、校验地址，并将私钥加密给恢复流程的临时公钥
+func (s *Service) SubmitShare(recoveryID, trusteeID int64, share, signature []byte) (*RecoveryModel, int, error) {
+	var recovery RecoveryModel
+	if err := s.db.First(&recovery, recoveryID).Error; err != nil || recovery.Status != RecoveryPending {
+		return nil, 0, ErrRecoveryClosed
+	}
+
+	var trustee TrusteeModel
+	if err := s.db.First(&trustee, trusteeID).Error; err != nil {
+		return nil, 0, errors.New("受托人不存在")
+	}
+	var count int64
+	if err := s.db.Model(&ShareModel{}).Where("user_id = ? AND trustee_id = ?", recovery.UserID, trusteeID).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, errors.New("该受托人未持有此用户的份额")
+	}
+	if err := verifyTrusteeSignature(trustee.PublicKey, RecoveryMessage(recoveryID, share), signature); err != nil {
+		return nil, 0, err
+	}
+
+	submission := &SubmissionModel{
+		RecoveryID: recoveryID,
+		TrusteeID:  trusteeID,
+		ShareB64:   base64.StdEncoding.EncodeToString(share),
+		CreatedAt:  time.Now(),
+	}
+	if err := s.db.Save(submission).Error; err != nil {
+		return nil, 0, err
+	}
+
+	escrow, err := s.GetEscrow(recovery.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var submissions []SubmissionModel
+	if err := s.db.Where("recovery_id = ?", recoveryID).Find(&submissions).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(submissions) < escrow.Threshold {
+		return &recovery, len(submissions), nil
+	}
+
+	parts := make([][]byte, len(submissions))
+	for i, sub := range submissions {
+		parts[i], err = base64.StdEncoding.DecodeString(sub.ShareB64)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	secret, err := Combine(parts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("合并份额失败: %w", err)
+	}
+	defer zero(secret)
+	if len(secret) < privateKeyLen {
+		return nil, 0, errors.New("恢复出的私钥长度无效，请检查提交的份额")
+	}
+
+	key, err := crypto.ToECDSA(secret[:privateKeyLen])
+	if err != nil || !strings.EqualFold(crypto.PubkeyToAddress(key.PublicKey).Hex(), escrow.Address) {
+		return nil, 0, errors.New("恢复出的私钥与地址不匹配，请检查提交的份额")
+	}
+
+	recoveryPub, err := parsePublicKey(recovery.PublicKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	enc, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(recoveryPub), secret, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&recovery).Updates(map[string]interface{}{
+			"status":  RecoveryReady,
+			"enc_key": base64.StdEncoding.EncodeToString(enc),
+		}).Error; err != nil {
+			return err
+		}
+		// 私钥已加密保存，不再保留明文份额
+		return tx.Where("recovery_id = ?", recoveryID).Delete(&SubmissionModel{}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	recovery.Status = RecoveryReady
+	return &recovery, len(submissions), nil
+}
+
+// CompleteRecovery 使用恢复令牌取回钱包密钥材料（私钥，可能附带助记词熵），并将流程标记为已完成
+func (s *Service) CompleteRecovery(recoveryID int64, token string) (int64, []byte, error) {
+	var recovery RecoveryModel
+	if err := s.db.First(&recovery, recoveryID).Error; err != nil {
+		return 0, nil, ErrRecoveryClosed
+	}
+	if recovery.Status != RecoveryReady {
+		return 0, nil, errors.New("恢复流程尚未达到门限或已完成")
+	}
+
+	ephemeral, err := recoveryKey(&recovery, token)
+	if err != nil {
+		return 0, nil, err
+	}
+	if time.Now().Before(recovery.CompleteAt) {
+		return 0, nil, fmt.Errorf("恢复冷静期尚未结束，请在 %s 之后完成", recovery.CompleteAt.UTC().Format(time.RFC3339))
+	}
+	enc, err := base64.StdEncoding.DecodeString(recovery.EncKeyB64)
+	if err != nil {
+		return 0, nil, err
+	}
+	privBytes, err := ecies.ImportECDSA(ephemeral).Decrypt(enc, nil, nil)
+	if err != nil {
+		return 0, nil, ErrInvalidRecoveryToken
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&recovery).Updates(map[string]interface{}{
+		"status":       RecoveryCompleted,
+		"enc_key":      "",
+		"completed_at": &now,
+	}).Error; err != nil {
+		zero(privBytes)
+		return 0, nil, err
+	}
+	return recovery.UserID, privBytes, nil
+}
+
+func parsePublicKey(publicKeyHex string) (*ecdsa.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(publicKeyHex, "0x"))
+	if err != nil {
+		return nil, errors.New("公钥格式无效")
+	}
+	if len(raw) == 33 {
+		pub, err := crypto.DecompressPubkey(raw)
+		if err != nil {
+			return nil, errors.New("公钥格式无效")
+		}
+		return pub, nil
+	}
+	pub, err := crypto.UnmarshalPubkey(raw)
+	if err != nil {
+		return nil, errors.New("公钥格式无效")
+	}
+	return pub, nil
+}
+
+func verifyTrusteeSignature(publicKeyHex, message string, signature []byte) error {
+	if len(signature) != crypto.SignatureLength {
+		return errors.New("签名长度无效")
+	}
+	sig := common.CopyBytes(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return errors.New("签名无效")
+	}
+	if !strings.EqualFold(hex.EncodeToString(crypto.FromECDSAPub(pub)), publicKeyHex) {
+		return errors.New("签名与受托人公钥不匹配")
+	}
+	return nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
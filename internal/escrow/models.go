@@ -0,0 +1,83 @@
+package escrow
+
+import (
+	"time"
+)
+
+// TrusteeModel 托管受托人（持有 secp256k1 公钥，份额使用 ECIES 加密给该公钥）
+type TrusteeModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Name      string    `gorm:"not null;column:name"`
+	PublicKey string    `gorm:"uniqueIndex;not null;column:public_key"` // 未压缩公钥（hex）
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (TrusteeModel) TableName() string {
+	return "escrow_trustees"
+}
+
+// EscrowModel 用户的私钥托管配置
+type EscrowModel struct {
+	UserID    int64     `gorm:"primaryKey;column:user_id"`
+	Address   string    `gorm:"not null;column:address"`
+	Threshold int       `gorm:"not null;column:threshold"`
+	Total     int       `gorm:"not null;column:total"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (EscrowModel) TableName() string {
+	return "escrows"
+}
+
+// ShareModel 加密给受托人的私钥份额
+type ShareModel struct {
+	UserID      int64  `gorm:"primaryKey;column:user_id"`
+	TrusteeID   int64  `gorm:"primaryKey;column:trustee_id"`
+	EncShareB64 string `gorm:"not null;column:enc_share"`
+}
+
+// TableName 指定表名
+func (ShareModel) TableName() string {
+	return "escrow_shares"
+}
+
+// RecoveryModel 一次私钥恢复流程
+type RecoveryModel struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement"`
+	UserID      int64      `gorm:"index;not null;column:user_id"`
+	Status      string     `gorm:"index;not null;column:status"` // pending / ready / completed / cancelled
+	PublicKey   string     `gorm:"not null;column:public_key"`   // 恢复令牌对应的临时公钥（hex）
+	EncKeyB64   string     `gorm:"column:enc_key"`               // 达到门限后，使用 ECIES 加密给临时公钥的私钥
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	CompleteAt  time.Time  `gorm:"column:complete_at"` // 冷静期结束时间，之前不能完成恢复，账户所有者可以取消
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+	CancelledAt *time.Time `gorm:"column:cancelled_at"`
+}
+
+// TableName 指定表名
+func (RecoveryModel) TableName() string {
+	return "escrow_recoveries"
+}
+
+// SubmissionModel 受托人在恢复流程中提交的份额（流程完成后删除）
+type SubmissionModel struct {
+	RecoveryID int64     `gorm:"primaryKey;column:recovery_id"`
+	TrusteeID  int64     `gorm:"primaryKey;column:trustee_id"`
+	ShareB64   string    `gorm:"not null;column:share"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (SubmissionModel) TableName() string {
+	return "escrow_submissions"
+}
+
+// 恢复流程状态
+const (
+	RecoveryPending   = "pending"
+	RecoveryReady     = "ready"
+	RecoveryCompleted = "completed"
+	RecoveryCancelled = "cancelled"
+)
@@ -0,0 +1,124 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// GF(256) 运算表（AES 多项式 x^8 + x^4 + x^3 + x + 1，生成元 3）
+var (
+	gfExp [256]byte
+	gfLog [256]byte
+)
+
+func init() {
+	var x byte = 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		// x *= 3
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+// Split 将 secret 拆分为 n 份，任意 k 份即可恢复
+// 每份的最后一个字节为 x 坐标（1..n），其余为对应的多项式取值
+func Split(secret []byte, n, k int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret 不能为空")
+	}
+	if k < 2 || n < k || n > 255 {
+		return nil, errors.New("无效的份数或门限（要求 2 <= k <= n <= 255）")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, k)
+	for idx, b := range secret {
+		// 常数项为秘密字节，其余系数随机
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			x := byte(i + 1)
+			// Horner 法求值
+			var y byte
+			for c := k - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coeffs[c]
+			}
+			shares[i][idx] = y
+		}
+	}
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+	return shares, nil
+}
+
+// Combine 使用拉格朗日插值从至少 k 份中恢复 secret
+// 份数不足门限时会得到错误的结果，调用方需要自行校验（例如比对地址）
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("至少需要 2 份")
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("无效的份额")
+	}
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, s := range shares {
+		if len(s) != size {
+			return nil, errors.New("份额长度不一致")
+		}
+		x := s[size-1]
+		if x == 0 || seen[x] {
+			return nil, errors.New("份额 x 坐标无效或重复")
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	for idx := range secret {
+		var acc byte
+		for i, s := range shares {
+			num, den := byte(1), byte(1)
+			for j := range shares {
+				if i == j {
+					continue
+				}
+				num = gfMul(num, xs[j])
+				den = gfMul(den, xs[i]^xs[j])
+			}
+			acc ^= gfMul(s[idx], gfDiv(num, den))
+		}
+		secret[idx] = acc
+	}
+	return secret, nil
+}
@@ -0,0 +1,270 @@
+package faucet
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Policy Gas 补贴策略；额度为 0 表示不限制
+type Policy struct {
+	Threshold       *big.Int      // ETH 余额低于该值时才补贴（wei）
+	TopUp           *big.Int      // 每次补贴金额（wei）
+	UserDailyBudget *big.Int      // 每个用户（未登录时按地址）每天的补贴上限
+	DailyBudget     *big.Int      // 全站每天的补贴上限（按 UTC 日）
+	TotalBudget     *big.Int      // 全站累计补贴上限
+	Cooldown        time.Duration // 同一用户或地址两次补贴的最短间隔
+}
+
+// ErrRefused 额度用尽或仍在冷却中，拒绝补贴
+type ErrRefused struct {
+	Reason     string
+	RetryAfter time.Duration // 0 表示无法预计何时恢复
+}
+
+func (e *ErrRefused) Error() string {
+	return e.Reason + "，请先自行向该地址转入少量 ETH 支付 Gas"
+}
+
+// Service Gas 补贴：按策略检查额度并在 faucet_grants 中记录每一笔补贴
+type Service struct {
+	db     *gorm.DB
+	policy Policy
+	mu     sync.Mutex // 串行化额度检查与预留，避免并发请求同时通过检查
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, policy Policy) (*Service, error) {
+	if policy.Threshold == nil || policy.TopUp == nil || policy.TopUp.Sign() <= 0 {
+		return nil, fmt.Errorf("补贴阈值与补贴金额必须大于 0")
+	}
+	for _, b := range []**big.Int{&policy.UserDailyBudget, &policy.DailyBudget, &policy.TotalBudget} {
+		if *b == nil {
+			*b = new(big.Int)
+		}
+	}
+	if err := db.AutoMigrate(&GrantModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, policy: policy}, nil
+}
+
+// Policy 返回当前策略
+func (s *Service) Policy() Policy {
+	return s.policy
+}
+
+// NeedsTopUp 余额是否低于补贴阈值
+func (s *Service) NeedsTopUp(balance *big.Int) bool {
+	return balance.Cmp(s.policy.Threshold) < 0
+}
+
+// dayStart 返回 UTC 当日零点
+func dayStart(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// counted 只统计未失败的补贴（pending 也计入，服务中途退出时宁可少发）
+func (s *Service) counted() *gorm.DB {
+	return s.db.Model(&GrantModel{}).Where("status <> ?", StatusFailed)
+}
+
+// subject 用户补贴的统计范围：登录用户按用户 ID 或地址，未登录按地址
+func subject(q *gorm.DB, userID int64, address string) *gorm.DB {
+	if userID > 0 {
+		return q.Where("user_id = ? OR address = ?", userID, address)
+	}
+	return q.Where("address = ?", address)
+}
+
+// sum 汇总金额（金额以 wei 字符串保存，在内存中求和）
+func sum(q *gorm.DB) (*big.Int, error) {
+	var amounts []string
+	if err := q.Pluck("amount", &amounts).Error; err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, a := range amounts {
+		if v, ok := new(big.Int).SetString(a, 10); ok {
+			total.Add(total, v)
+		}
+	}
+	return total, nil
+}
+
+// exceeds 加上本次补贴后是否超过额度（额度为 0 不限制）
+func exceeds(spent, amount, budget *big.Int) bool {
+	return budget.Sign() > 0 && new(big.Int).Add(spent, amount).Cmp(budget) > 0
+}
+
+// Reserve 检查冷却与各项额度，通过后预留一笔补贴；发送后调用 MarkSent，失败调用 MarkFailed
+func (s *Service) Reserve(userID int64, address string) (*GrantModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	today := dayStart(now)
+	untilTomorrow := today.Add(24 * time.Hour).Sub(now)
+	amount := s.policy.TopUp
+
+	if s.policy.Cooldown > 0 {
+		var last GrantModel
+		err := subject(s.counted(), userID, address).Order("created_at DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return nil, err
+		}
+		if last.ID != 0 {
+			if wait := last.CreatedAt.Add(s.policy.Cooldown).Sub(now); wait > 0 {
+				return nil, &ErrRefused{Reason: "Gas 补贴过于频繁", RetryAfter: wait}
+			}
+		}
+	}
+
+	if s.policy.UserDailyBudget.Sign() > 0 {
+		spent, err := sum(subject(s.counted(), userID, address).Where("created_at >= ?", today))
+		if err != nil {
+			return nil, err
+		}
+		if exceeds(spent, amount, s.policy.UserDailyBudget) {
+			return nil, &ErrRefused{Reason: "今日 Gas 补贴额度已用完", RetryAfter: untilTomorrow}
+		}
+	}
+
+	if s.policy.DailyBudget.Sign() > 0 {
+		spent, err := sum(s.counted().Where("created_at >= ?", today))
+		if err != nil {
+			return nil, err
+		}
+		if exceeds(spent, amount, s.policy.DailyBudget) {
+			return nil, &ErrRefused{Reason: "今日全站 Gas 补贴额度已用完", RetryAfter: untilTomorrow}
+		}
+	}
+
+	if s.policy.TotalBudget.Sign() > 0 {
+		spent, err := sum(s.counted())
+		if err != nil {
+			return nil, err
+		}
+		if exceeds(spent, amount, s.policy.TotalBudget) {
+			return nil, &ErrRefused{Reason: "Gas 补贴总额度已用完"}
+		}
+	}
+
+	grant := &GrantModel{
+		UserID:    userID,
+		Address:   address,
+		Amount:    amount.String(),
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.Create(grant).Error; err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+func (s *Service) update(id int64, fields map[string]interface{}) error {
+	fields["updated_at"] = time.Now()
+	return s.db.Model(&GrantModel{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// MarkSent 记录补贴交易哈希
+func (s *Service) MarkSent(id int64, txHash string) error {
+	return s.update(id, map[string]interface{}{"status": StatusSent, "tx_hash": txHash})
+}
+
+// MarkConfirmed 补贴交易已确认
+func (s *Service) MarkConfirmed(id int64) error {
+	return s.update(id, map[string]interface{}{"status": StatusConfirmed})
+}
+
+// MarkFailed 补贴发送失败或交易失败，释放预留的额度
+func (s *Service) MarkFailed(id int64, cause error) error {
+	return s.update(id, map[string]interface{}{"status": StatusFailed, "error": cause.Error()})
+}
+
+// Budget 额度使用情况（金额均为 wei）
+type Budget struct {
+	SpentToday     *big.Int
+	RemainingToday *big.Int // 不限制时为 nil
+	GrantsToday    int64
+	SpentTotal     *big.Int
+	RemainingTotal *big.Int // 不限制时为 nil
+}
+
+// UserBudget 单个用户（或地址）的额度使用情况
+type UserBudget struct {
+	SpentToday     *big.Int
+	RemainingToday *big.Int   // 不限制时为 nil
+	NextGrantAt    *time.Time // 冷却结束时间，无冷却时为 nil
+}
+
+func remaining(spent, budget *big.Int) *big.Int {
+	if budget.Sign() <= 0 {
+		return nil
+	}
+	left := new(big.Int).Sub(budget, spent)
+	if left.Sign() < 0 {
+		left.SetInt64(0)
+	}
+	return left
+}
+
+// Summary 返回全站额度使用情况
+func (s *Service) Summary() (*Budget, error) {
+	today := dayStart(time.Now())
+	spentToday, err := sum(s.counted().Where("created_at >= ?", today))
+	if err != nil {
+		return nil, err
+	}
+	var grantsToday int64
+	if err := s.counted().Where("created_at >= ?", today).Count(&grantsToday).Error; err != nil {
+		return nil, err
+	}
+	spentTotal, err := sum(s.counted())
+	if err != nil {
+		return nil, err
+	}
+	return &Budget{
+		SpentToday:     spentToday,
+		RemainingToday: remaining(spentToday, s.policy.DailyBudget),
+		GrantsToday:    grantsToday,
+		SpentTotal:     spentTotal,
+		RemainingTotal: remaining(spentTotal, s.policy.TotalBudget),
+	}, nil
+}
+
+// UserSummary 返回单个用户（或地址）的额度使用情况
+func (s *Service) UserSummary(userID int64, address string) (*UserBudget, error) {
+	now := time.Now()
+	spent, err := sum(subject(s.counted(), userID, address).Where("created_at >= ?", dayStart(now)))
+	if err != nil {
+		return nil, err
+	}
+	budget := &UserBudget{SpentToday: spent, RemainingToday: remaining(spent, s.policy.UserDailyBudget)}
+	if s.policy.Cooldown > 0 {
+		var last GrantModel
+		if err := subject(s.counted(), userID, address).Order("created_at DESC").Limit(1).Find(&last).Error; err != nil {
+			return nil, err
+		}
+		if next := last.CreatedAt.Add(s.policy.Cooldown); last.ID != 0 && next.After(now) {
+			budget.NextGrantAt = &next
+		}
+	}
+	return budget, nil
+}
+
+// List 按时间倒序列出补贴记录，userID/address 为空时不过滤
+func (s *Service) List(userID int64, address string, limit int) ([]GrantModel, error) {
+	q := s.db.Model(&GrantModel{})
+	if userID > 0 || address != "" {
+		q = subject(q, userID, address)
+	}
+	var grants []GrantModel
+	err := q.Order("id DESC").Limit(limit).Find(&grants).Error
+	return grants, err
+}
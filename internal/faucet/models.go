@@ -0,0 +1,31 @@
+package faucet
+
+import (
+	"time"
+)
+
+// 补贴记录状态
+const (
+	StatusPending   = "pending"   // 已预留额度，交易尚未发送
+	StatusSent      = "sent"      // 交易已发送
+	StatusConfirmed = "confirmed" // 交易已确认
+	StatusFailed    = "failed"    // 发送失败或交易失败，不计入额度
+)
+
+// GrantModel GORM Gas 补贴记录模型（每次自动转账 ETH 一条）
+type GrantModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"index;not null;default:0;column:user_id"` // 未登录（直接提供私钥）时为 0
+	Address   string    `gorm:"index;not null;column:address"`
+	Amount    string    `gorm:"not null;column:amount"` // wei
+	TxHash    string    `gorm:"index;column:tx_hash"`
+	Status    string    `gorm:"index;not null;column:status"`
+	Error     string    `gorm:"column:error"`
+	CreatedAt time.Time `gorm:"index;not null;column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName 指定表名
+func (GrantModel) TableName() string {
+	return "faucet_grants"
+}
@@ -0,0 +1,143 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 记录状态
+const (
+	StatusProcessing = "processing" // 首次请求仍在处理
+	StatusCompleted  = "completed"  // 已保存响应，重复请求直接重放
+)
+
+var (
+	// ErrMismatch 同一个 key 已用于内容不同的请求
+	ErrMismatch = errors.New("Idempotency-Key 已用于不同的请求")
+	// ErrInProgress 使用同一个 key 的请求仍在处理中
+	ErrInProgress = errors.New("使用相同 Idempotency-Key 的请求正在处理中，请稍后重试")
+)
+
+// RecordModel GORM 幂等请求记录模型
+type RecordModel struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	UserID      int64     `gorm:"uniqueIndex:idx_idempotency_user_key;not null;default:0;column:user_id"` // 未登录请求为 0
+	Key         string    `gorm:"uniqueIndex:idx_idempotency_user_key;not null;column:key"`
+	Fingerprint string    `gorm:"not null;column:fingerprint"` // 请求方法、路径与请求体（去除密码等字段）的 SHA-256
+	Status      string    `gorm:"not null;column:status"`
+	StatusCode  int       `gorm:"column:status_code"`
+	Response    []byte    `gorm:"column:response"` // 响应体（JSON）
+	CreatedAt   time.Time `gorm:"not null;column:created_at"`
+	ExpiresAt   time.Time `gorm:"index;not null;column:expires_at"`
+}
+
+// TableName 指定表名
+func (RecordModel) TableName() string {
+	return "idempotency_keys"
+}
+
+// Store 基于数据库的幂等 key 存储，多实例共享同一数据库时同样有效
+type Store struct {
+	db  *gorm.DB
+	ttl time.Duration // key 的有效期，过期后可以重新使用
+}
+
+// NewStore 创建存储并初始化表结构
+func NewStore(db *gorm.DB, ttl time.Duration) (*Store, error) {
+	if err := db.AutoMigrate(&RecordModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Store{db: db, ttl: ttl}, nil
+}
+
+// Begin 登记请求。首次请求返回状态为 processing 的新记录，调用方处理完后调用 Complete 或 Release；
+// 相同请求已完成时返回已保存的记录（Status 为 completed）用于重放；
+// 内容不同返回 ErrMismatch，仍在处理返回 ErrInProgress
+func (s *Store) Begin(userID int64, key, fingerprint string) (*RecordModel, error) {
+	existing, err := s.find(userID, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		now := time.Now()
+		record := &RecordModel{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      StatusProcessing,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.ttl),
+		}
+		createErr := s.db.Create(record).Error
+		if createErr == nil {
+			return record, nil
+		}
+		// 并发的相同 key：按已有记录处理
+		if existing, err = s.find(userID, key); err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, createErr
+		}
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, ErrMismatch
+	}
+	if existing.Status != StatusCompleted {
+		return nil, ErrInProgress
+	}
+	return existing, nil
+}
+
+// find 查询未过期的记录，过期记录直接删除
+func (s *Store) find(userID int64, key string) (*RecordModel, error) {
+	var record RecordModel
+	if err := s.db.Where("user_id = ? AND key = ?", userID, key).Limit(1).Find(&record).Error; err != nil {
+		return nil, err
+	}
+	if record.ID == 0 {
+		return nil, nil
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		if err := s.db.Delete(&RecordModel{}, record.ID).Error; err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Complete 保存响应，有效期内的重复请求将重放该响应
+func (s *Store) Complete(id int64, statusCode int, response []byte) error {
+	return s.db.Model(&RecordModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      StatusCompleted,
+		"status_code": statusCode,
+		"response":    response,
+	}).Error
+}
+
+// Release 删除记录（请求未产生副作用，例如参数错误或验证码错误），之后可以用同一个 key 重试
+func (s *Store) Release(id int64) error {
+	return s.db.Delete(&RecordModel{}, id).Error
+}
+
+// Run 每隔 interval 清理过期记录，直到 ctx 结束
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.db.Where("expires_at < ?", time.Now()).Delete(&RecordModel{}).Error; err != nil {
+				log.Printf("清理幂等记录失败: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package jobqueue
+
+import (
+	"time"
+)
+
+// 任务状态
+const (
+	StatusQueued    = "queued"    // 等待执行（包括等待重试）
+	StatusRunning   = "running"   // 已被 worker 租用
+	StatusSucceeded = "succeeded" // 执行成功
+	StatusDead      = "dead"      // 重试次数用尽或不可重试的错误（死信）
+	StatusCanceled  = "canceled"  // 管理员取消
+)
+
+// JobModel GORM 任务模型
+type JobModel struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement"`
+	Kind           string     `gorm:"index;not null;column:kind"`
+	Sender         string     `gorm:"index:idx_jobs_sender_status;column:sender"` // 发送地址，同一地址的任务按 ID 顺序逐个执行；为空不限制顺序
+	Nonce          *uint64    `gorm:"column:nonce"`                               // 预签名交易的 nonce
+	IdempotencyKey *string    `gorm:"uniqueIndex;column:idempotency_key"`         // 相同 key 只会入队一次
+	Payload        string     `gorm:"not null;column:payload"`                    // JSON
+	State          string     `gorm:"column:state"`                               // 执行中保存的检查点（JSON），重试时用于避免重复执行副作用
+	Result         string     `gorm:"column:result"`                              // 成功结果（JSON）
+	Status         string     `gorm:"index:idx_jobs_sender_status;index;not null;column:status"`
+	Attempts       int        `gorm:"not null;default:0;column:attempts"`
+	MaxAttempts    int        `gorm:"not null;column:max_attempts"`
+	RunAt          time.Time  `gorm:"index;not null;column:run_at"` // 最早执行时间（重试退避）
+	LeaseOwner     string     `gorm:"column:lease_owner"`
+	LeaseExpiresAt *time.Time `gorm:"column:lease_expires_at"`
+	LastError      string     `gorm:"column:last_error"`
+	CreatedAt      time.Time  `gorm:"not null;column:created_at"`
+	UpdatedAt      time.Time  `gorm:"not null;column:updated_at"`
+	FinishedAt     *time.Time `gorm:"column:finished_at"`
+}
+
+// TableName 指定表名
+func (JobModel) TableName() string {
+	return "jobs"
+}
+
+// Terminal 是否已结束
+func (m *JobModel) Terminal() bool {
+	return m.Status == StatusSucceeded || m.Status == StatusDead || m.Status == StatusCanceled
+}
+
+// TxResult 发送交易类任务的结果
+type TxResult struct {
+	TxHash string `json:"txHash"`
+}
@@ -0,0 +1,527 @@
+package jobqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	leaseDuration = 2 * time.Minute
+	pollInterval  = time.Second
+	reapInterval  = 30 * time.Second
+	backoffBase   = 5 * time.Second
+	backoffMax    = 10 * time.Minute
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("任务不存在")
+	// ErrNotRetryable 只有死信或已取消的任务可以重试
+	ErrNotRetryable = errors.New("只有失败（死信）或已取消的任务可以重试")
+	// ErrNotCancelable 只有等待中的任务可以取消
+	ErrNotCancelable = errors.New("只有等待执行的任务可以取消")
+)
+
+// Handler 执行一个任务，返回值序列化后保存为结果；返回 Permanent 包装的错误时不再重试
+type Handler func(ctx context.Context, job *JobModel) (interface{}, error)
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记不可重试的错误，任务直接进入死信
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Spec 入队参数
+type Spec struct {
+	Kind           string
+	Sender         string      // 发送地址，同一地址的任务按入队顺序逐个执行
+	Nonce          *uint64     // 预签名交易的 nonce
+	IdempotencyKey string      // 可选，相同 key 只入队一次
+	Payload        interface{} // 序列化为 JSON
+	MaxAttempts    int         // 0 使用默认值
+}
+
+// Queue 持久化任务队列：租约、退避重试、幂等 key、死信，同一发送地址的任务串行执行
+type Queue struct {
+	db          *gorm.DB
+	owner       string // 当前实例的租约标识
+	maxAttempts int
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	onFailed map[string]func(job *JobModel)
+
+	senderMu sync.Mutex
+	senders  map[string]*sync.Mutex
+}
+
+// NewQueue 创建队列并初始化表结构
+func NewQueue(db *gorm.DB, maxAttempts int) (*Queue, error) {
+	if err := db.AutoMigrate(&JobModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return &Queue{
+		db:          db,
+		owner:       fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		maxAttempts: maxAttempts,
+		handlers:    make(map[string]Handler),
+		onFailed:    make(map[string]func(job *JobModel)),
+		senders:     make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Register 注册任务类型的处理函数
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// OnFailed 注册任务进入死信或被取消时的回调（如释放预留的额度）
+func (q *Queue) OnFailed(kind string, fn func(job *JobModel)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onFailed[kind] = fn
+}
+
+func (q *Queue) failed(job *JobModel) {
+	q.mu.RLock()
+	fn := q.onFailed[job.Kind]
+	q.mu.RUnlock()
+	if fn != nil {
+		fn(job)
+	}
+}
+
+// Enqueue 入队；IdempotencyKey 已存在时返回已有任务，created 为 false
+func (q *Queue) Enqueue(spec Spec) (job *JobModel, created bool, err error) {
+	if spec.IdempotencyKey != "" {
+		if existing, err := q.byKey(spec.IdempotencyKey); err != nil || existing != nil {
+			return existing, false, err
+		}
+	}
+
+	payload, err := json.Marshal(spec.Payload)
+	if err != nil {
+		return nil, false, err
+	}
+	maxAttempts := spec.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = q.maxAttempts
+	}
+	now := time.Now()
+	job = &JobModel{
+		Kind:        spec.Kind,
+		Sender:      spec.Sender,
+		Nonce:       spec.Nonce,
+		Payload:     string(payload),
+		Status:      StatusQueued,
+		MaxAttempts: maxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if spec.IdempotencyKey != "" {
+		key := spec.IdempotencyKey
+		job.IdempotencyKey = &key
+	}
+	if err := q.db.Create(job).Error; err != nil {
+		// 并发入队相同 key：返回先入队的任务
+		if spec.IdempotencyKey != "" {
+			if existing, findErr := q.byKey(spec.IdempotencyKey); findErr == nil && existing != nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, err
+	}
+	return job, true, nil
+}
+
+func (q *Queue) byKey(key string) (*JobModel, error) {
+	var job JobModel
+	err := q.db.Where("idempotency_key = ?", key).Limit(1).Find(&job).Error
+	if err != nil || job.ID == 0 {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// LockSender 串行化同一发送地址的 nonce 分配、签名与入队，返回解锁函数
+func (q *Queue) LockSender(sender string) func() {
+	q.senderMu.Lock()
+	m, ok := q.senders[sender]
+	if !ok {
+		m = &sync.Mutex{}
+		q.senders[sender] = m
+	}
+	q.senderMu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+// NextNonce 返回发送地址下一个可用的 nonce：取节点 pending nonce 与队列中未完成任务最大 nonce + 1 的较大值
+// 调用方应持有 LockSender
+func (q *Queue) NextNonce(sender string, pending uint64) (uint64, error) {
+	var jobs []JobModel
+	err := q.db.Select("nonce").
+		Where("sender = ? AND status IN ? AND nonce IS NOT NULL", sender, []string{StatusQueued, StatusRunning}).
+		Order("nonce DESC").Limit(1).Find(&jobs).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(jobs) > 0 && *jobs[0].Nonce+1 > pending {
+		return *jobs[0].Nonce + 1, nil
+	}
+	return pending, nil
+}
+
+// Checkpoint 保存执行中的检查点（如已签名的交易），重试时处理函数可从 job.State 恢复
+func (q *Queue) Checkpoint(job *JobModel, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	job.State = string(data)
+	return q.db.Model(&JobModel{}).Where("id = ?", job.ID).
+		Updates(map[string]interface{}{"state": job.State, "updated_at": time.Now()}).Error
+}
+
+// Get 查询任务
+func (q *Queue) Get(id int64) (*JobModel, error) {
+	var job JobModel
+	err := q.db.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &job, err
+}
+
+// List 按 ID 倒序列出任务，status/kind/sender 为空时不过滤
+func (q *Queue) List(status, kind, sender string, limit int) ([]JobModel, error) {
+	query := q.db.Model(&JobModel{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if sender != "" {
+		query = query.Where("sender = ?", sender)
+	}
+	var jobs []JobModel
+	err := query.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// Counts 各状态的任务数
+func (q *Queue) Counts() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := q.db.Model(&JobModel{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, nil
+}
+
+// Wait 等待任务结束或 ctx 结束，返回最新状态
+func (q *Queue) Wait(ctx context.Context, id int64) (*JobModel, error) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		job, err := q.Get(id)
+		if err != nil || job.Terminal() {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Retry 重新执行死信或已取消的任务（重置尝试次数）
+func (q *Queue) Retry(id int64) (*JobModel, error) {
+	now := time.Now()
+	result := q.db.Model(&JobModel{}).
+		Where("id = ? AND status IN ?", id, []string{StatusDead, StatusCanceled}).
+		Updates(map[string]interface{}{
+			"status":      StatusQueued,
+			"attempts":    0,
+			"run_at":      now,
+			"finished_at": nil,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := q.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotRetryable
+	}
+	return q.Get(id)
+}
+
+// Cancel 取消等待执行的任务
+func (q *Queue) Cancel(id int64) (*JobModel, error) {
+	now := time.Now()
+	result := q.db.Model(&JobModel{}).
+		Where("id = ? AND status = ?", id, StatusQueued).
+		Updates(map[string]interface{}{
+			"status":      StatusCanceled,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := q.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotCancelable
+	}
+	job, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	q.failed(job)
+	return job, nil
+}
+
+// Run 启动 workers 个 worker 并定期回收过期租约，直到 ctx 结束
+func (q *Queue) Run(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	q.reap()
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			q.reap()
+		}
+	}
+}
+
+// reap 回收租约过期的任务（worker 崩溃或实例退出），按一次失败的尝试处理
+func (q *Queue) reap() {
+	now := time.Now()
+	var expired []JobModel
+	if err := q.db.Where("status = ? AND lease_expires_at < ?", StatusRunning, now).Find(&expired).Error; err != nil {
+		log.Printf("查询过期任务租约失败: %v", err)
+		return
+	}
+	for i := range expired {
+		job := &expired[i]
+		if err := q.finish(job, job.LeaseOwner, nil, errors.New("任务租约过期")); err != nil {
+			log.Printf("回收任务 %d 失败: %v", job.ID, err)
+		}
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		job, err := q.acquire()
+		if err != nil {
+			log.Printf("获取任务失败: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		q.execute(ctx, job)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// acquire 租用一个可执行的任务：同一发送地址没有执行中的任务，也没有更早的等待中任务
+func (q *Queue) acquire() (*JobModel, error) {
+	now := time.Now()
+	active := []string{StatusQueued, StatusRunning}
+	var candidates []JobModel
+	err := q.db.Where("status = ? AND run_at <= ?", StatusQueued, now).
+		Where("sender = '' OR NOT EXISTS (SELECT 1 FROM jobs p WHERE p.sender = jobs.sender AND p.id <> jobs.id AND "+
+			"(p.status = ? OR (p.status IN ? AND p.id < jobs.id)))", StatusRunning, active).
+		Order("run_at, id").Limit(10).Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		job := &candidates[i]
+		expires := now.Add(leaseDuration)
+		result := q.db.Model(&JobModel{}).
+			Where("id = ? AND status = ?", job.ID, StatusQueued).
+			Updates(map[string]interface{}{
+				"status":           StatusRunning,
+				"lease_owner":      q.owner,
+				"lease_expires_at": expires,
+				"attempts":         gorm.Expr("attempts + 1"),
+				"updated_at":       now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = StatusRunning
+			job.LeaseOwner = q.owner
+			job.LeaseExpiresAt = &expires
+			job.Attempts++
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (q *Queue) execute(ctx context.Context, job *JobModel) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	// 执行期间定期续租
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(leaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := q.db.Model(&JobModel{}).
+					Where("id = ? AND lease_owner = ? AND status = ?", job.ID, q.owner, StatusRunning).
+					Update("lease_expires_at", time.Now().Add(leaseDuration)).Error
+				if err != nil {
+					log.Printf("任务 %d 续租失败: %v", job.ID, err)
+				}
+			}
+		}
+	}()
+
+	var result interface{}
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("未知的任务类型: %s", job.Kind))
+	} else {
+		result, err = q.call(ctx, handler, job)
+	}
+	close(done)
+
+	if finishErr := q.finish(job, q.owner, result, err); finishErr != nil {
+		log.Printf("记录任务 %d 结果失败: %v", job.ID, finishErr)
+	}
+}
+
+// call 执行处理函数，panic 视为一次失败
+func (q *Queue) call(ctx context.Context, handler Handler, job *JobModel) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("任务执行 panic: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// finish 记录执行结果：成功、退避后重试或进入死信；只更新仍由 owner 租用的任务
+func (q *Queue) finish(job *JobModel, owner string, result interface{}, runErr error) error {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"lease_owner":      "",
+		"lease_expires_at": nil,
+		"updated_at":       now,
+	}
+	var permanent *permanentError
+	switch {
+	case runErr == nil:
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		fields["status"] = StatusSucceeded
+		fields["result"] = string(data)
+		fields["last_error"] = ""
+		fields["finished_at"] = now
+	case errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts:
+		fields["status"] = StatusDead
+		fields["last_error"] = runErr.Error()
+		fields["finished_at"] = now
+		log.Printf("任务 %d（%s）进入死信: %v", job.ID, job.Kind, runErr)
+	default:
+		fields["status"] = StatusQueued
+		fields["last_error"] = runErr.Error()
+		fields["run_at"] = now.Add(backoff(job.Attempts))
+		log.Printf("任务 %d（%s）第 %d 次执行失败，稍后重试: %v", job.ID, job.Kind, job.Attempts, runErr)
+	}
+	update := q.db.Model(&JobModel{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", job.ID, StatusRunning, owner).
+		Updates(fields)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 1 && fields["status"] == StatusDead {
+		job.Status = StatusDead
+		job.LastError = runErr.Error()
+		q.failed(job)
+	}
+	return nil
+}
+
+// backoff 指数退避：5s、10s、20s… 最长 10 分钟，附加最多 20% 的随机抖动
+func backoff(attempts int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempts && d < backoffMax; i++ {
+		d *= 2
+	}
+	if d > backoffMax {
+		d = backoffMax
+	}
+	return d + time.Duration(mrand.Int63n(int64(d)/5+1))
+}
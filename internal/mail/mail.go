@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer 通过 SMTP 发送邮件（服务器支持时自动使用 STARTTLS）
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string // 为空时不认证
+	Password string
+	From     string
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	if err := smtp.SendMail(addr, auth, m.From, []string{msg.To}, encode(m.From, msg)); err != nil {
+		return fmt.Errorf("SMTP 发送失败: %w", err)
+	}
+	return nil
+}
+
+// FileMailer 把邮件追加写入文件，用于开发和测试
+type FileMailer struct {
+	Path string
+	From string
+
+	mu sync.Mutex
+}
+
+// Send 追加写入邮件
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("打开邮件文件失败: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "From: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n\n",
+		m.From, msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+	return err
+}
+
+// LogMailer 把邮件打印到日志（未配置 SMTP 与邮件文件时使用）
+type LogMailer struct{}
+
+// Send 打印邮件
+func (LogMailer) Send(msg Message) error {
+	log.Printf("[mail] To: %s | Subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// encode 生成 RFC 5322 邮件，正文使用 UTF-8 + base64
+func encode(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,336 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/faucet"
+	"lbtc/internal/jobqueue"
+)
+
+// 操作类型
+const (
+	KindClaim = "claim" // 补贴 Gas 后领取每日奖励
+)
+
+// 操作状态
+const (
+	StatusFunding   = "funding"   // 等待 Gas 补贴发出并确认
+	StatusSubmitted = "submitted" // 等待业务交易广播并确认
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+)
+
+// dropAfter 交易长时间既无收据也不在交易池中时视为失败
+const dropAfter = 30 * time.Minute
+
+// ErrNotFound 操作不存在
+var ErrNotFound = errors.New("操作不存在")
+
+// OperationModel GORM 异步链上操作模型；补贴交易与业务交易均通过任务队列发送，服务重启后可继续执行
+type OperationModel struct {
+	ID         string    `gorm:"primaryKey;column:id"`
+	Kind       string    `gorm:"not null;column:kind"`
+	UserID     int64     `gorm:"index;not null;default:0;column:user_id"` // 未登录（直接提供私钥）时为 0
+	Address    string    `gorm:"not null;column:address"`
+	Account    string    `gorm:"column:account"`   // 子账户标签，用于释放领取锁
+	ClaimDay   int64     `gorm:"column:claim_day"` // 领取锁日期，0 表示未加锁
+	Status     string    `gorm:"index;not null;column:status"`
+	GrantID    int64     `gorm:"column:grant_id"`        // faucet_grants 记录
+	FundJobID  int64     `gorm:"column:fund_job_id"`     // 发送补贴交易的任务
+	FundTxHash string    `gorm:"column:fund_tx_hash"`    // 补贴任务成功后填入
+	JobID      int64     `gorm:"column:job_id"`          // 广播业务交易的任务
+	RawTx      string    `gorm:"not null;column:raw_tx"` // 已签名的业务交易
+	TxHash     string    `gorm:"index;not null;column:tx_hash"`
+	Error      string    `gorm:"column:error"`
+	CreatedAt  time.Time `gorm:"not null;column:created_at"`
+	UpdatedAt  time.Time `gorm:"not null;column:updated_at"`
+	StepAt     time.Time `gorm:"not null;column:step_started_at"` // 进入当前状态的时间，用于判断交易丢弃
+}
+
+// TableName 指定表名
+func (OperationModel) TableName() string {
+	return "operations"
+}
+
+// Terminal 是否已结束
+func (m *OperationModel) Terminal() bool {
+	return m.Status == StatusSuccess || m.Status == StatusFailed
+}
+
+// Service 异步链上操作：先等待 Gas 补贴确认，再等待预签名的业务交易广播并确认
+type Service struct {
+	db     *gorm.DB
+	client *ethclient.Client
+	auth   *auth.Service
+	faucet *faucet.Service
+	jobs   *jobqueue.Queue
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, client *ethclient.Client, authService *auth.Service, faucetService *faucet.Service, jobs *jobqueue.Queue) (*Service, error) {
+	if err := db.AutoMigrate(&OperationModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, client: client, auth: authService, faucet: faucetService, jobs: jobs}, nil
+}
+
+func encodeTx(tx *types.Transaction) (string, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(raw), nil
+}
+
+func decodeTx(raw string) (*types.Transaction, error) {
+	data, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// CreateClaim 保存领取操作；补贴任务与领取交易的发送任务均已入队，领取任务在补贴到账后才能发送成功
+func (s *Service) CreateClaim(userID int64, address common.Address, account string, claimDay, grantID, fundJobID int64, claimTx *types.Transaction, claimJobID int64) (*OperationModel, error) {
+	claimRaw, err := encodeTx(claimTx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	op := &OperationModel{
+		ID:        uuid.NewString(),
+		Kind:      KindClaim,
+		UserID:    userID,
+		Address:   address.Hex(),
+		Account:   account,
+		ClaimDay:  claimDay,
+		Status:    StatusFunding,
+		GrantID:   grantID,
+		FundJobID: fundJobID,
+		JobID:     claimJobID,
+		RawTx:     claimRaw,
+		TxHash:    claimTx.Hash().Hex(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		StepAt:    now,
+	}
+	if err := s.db.Create(op).Error; err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// Get 查询操作
+func (s *Service) Get(id string) (*OperationModel, error) {
+	var op OperationModel
+	err := s.db.First(&op, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &op, err
+}
+
+// List 列出用户最近的操作
+func (s *Service) List(userID int64, limit int) ([]OperationModel, error) {
+	var ops []OperationModel
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&ops).Error
+	return ops, err
+}
+
+// Fail 将操作标记为失败，取消尚未发送的业务交易并释放领取锁
+func (s *Service) Fail(op *OperationModel, cause error) error {
+	if op.JobID != 0 {
+		if _, err := s.jobs.Cancel(op.JobID); err != nil && !errors.Is(err, jobqueue.ErrNotCancelable) {
+			log.Printf("取消操作 %s 的交易任务失败: %v", op.ID, err)
+		}
+	}
+	if op.Kind == KindClaim && op.UserID != 0 && op.ClaimDay != 0 {
+		if err := s.auth.RemoveClaimLock(op.UserID, op.ClaimDay, op.Account); err != nil {
+			log.Printf("释放操作 %s 的领取锁失败: %v", op.ID, err)
+		}
+	}
+	return s.update(op, map[string]interface{}{"status": StatusFailed, "error": cause.Error()})
+}
+
+// FailFunding Gas 补贴未到账，操作失败并释放补贴额度
+func (s *Service) FailFunding(op *OperationModel, cause error) error {
+	if op.GrantID != 0 {
+		if err := s.faucet.MarkFailed(op.GrantID, cause); err != nil {
+			log.Printf("记录补贴 %d 失败状态出错: %v", op.GrantID, err)
+		}
+	}
+	return s.Fail(op, cause)
+}
+
+func (s *Service) update(op *OperationModel, fields map[string]interface{}) error {
+	now := time.Now()
+	fields["updated_at"] = now
+	if status, ok := fields["status"]; ok && status != op.Status {
+		fields["step_started_at"] = now
+	}
+	return s.db.Model(op).Updates(fields).Error
+}
+
+// Run 每隔 interval 推进一次未完成的操作，直到 ctx 结束
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll(ctx)
+		}
+	}
+}
+
+func (s *Service) poll(ctx context.Context) {
+	var ops []OperationModel
+	err := s.db.Where("status IN ?", []string{StatusFunding, StatusSubmitted}).
+		Order("created_at").Limit(100).Find(&ops).Error
+	if err != nil {
+		log.Printf("查询待处理操作失败: %v", err)
+		return
+	}
+	for i := range ops {
+		if err := s.advance(ctx, &ops[i]); err != nil {
+			log.Printf("推进操作 %s 失败: %v", ops[i].ID, err)
+		}
+	}
+}
+
+// advance 根据任务状态与链上收据推进一步
+func (s *Service) advance(ctx context.Context, op *OperationModel) error {
+	switch op.Status {
+	case StatusFunding:
+		if op.FundTxHash == "" {
+			hash, done, err := s.jobTx(op.FundJobID)
+			if err != nil {
+				return s.FailFunding(op, fmt.Errorf("Gas 补贴发送失败: %v", err))
+			}
+			if !done {
+				return nil
+			}
+			op.FundTxHash = hash
+			if err := s.update(op, map[string]interface{}{"fund_tx_hash": hash}); err != nil {
+				return err
+			}
+		}
+		receipt, err := s.receipt(ctx, op.FundTxHash, "")
+		if err != nil {
+			return err
+		}
+		if receipt == nil {
+			if time.Since(op.StepAt) > dropAfter {
+				return s.FailFunding(op, fmt.Errorf("Gas 补贴交易长时间未确认: %s", op.FundTxHash))
+			}
+			return nil
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			return s.FailFunding(op, fmt.Errorf("Gas 补贴交易失败: %s", op.FundTxHash))
+		}
+		if err := s.faucet.MarkConfirmed(op.GrantID); err != nil {
+			log.Printf("记录补贴 %d 确认状态失败: %v", op.GrantID, err)
+		}
+		return s.update(op, map[string]interface{}{"status": StatusSubmitted})
+
+	case StatusSubmitted:
+		if _, done, err := s.jobTx(op.JobID); err != nil {
+			return s.Fail(op, fmt.Errorf("发送交易失败: %v", err))
+		} else if !done {
+			if time.Since(op.StepAt) > dropAfter {
+				return s.Fail(op, fmt.Errorf("交易长时间未发送: %s", op.TxHash))
+			}
+			return nil
+		}
+		receipt, err := s.receipt(ctx, op.TxHash, op.RawTx)
+		if err != nil {
+			return err
+		}
+		if receipt == nil {
+			if time.Since(op.StepAt) > dropAfter {
+				return s.Fail(op, fmt.Errorf("交易长时间未确认: %s", op.TxHash))
+			}
+			return nil
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			return s.Fail(op, fmt.Errorf("交易执行失败: %s", op.TxHash))
+		}
+		return s.update(op, map[string]interface{}{"status": StatusSuccess})
+	}
+	return nil
+}
+
+// jobTx 查询发送交易的任务：成功时返回交易哈希；进入死信或被取消时返回错误
+func (s *Service) jobTx(id int64) (hash string, done bool, err error) {
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return "", false, err
+	}
+	switch job.Status {
+	case jobqueue.StatusSucceeded:
+		var result jobqueue.TxResult
+		if err := json.Unmarshal([]byte(job.Result), &result); err != nil {
+			return "", false, fmt.Errorf("解析任务结果失败: %v", err)
+		}
+		return result.TxHash, true, nil
+	case jobqueue.StatusDead:
+		return "", false, errors.New(job.LastError)
+	case jobqueue.StatusCanceled:
+		return "", false, errors.New("任务已取消")
+	}
+	return "", false, nil
+}
+
+// receipt 查询收据；没有收据且交易不在交易池中时重新广播 raw（已签名交易可安全重发，raw 为空时不重发），返回 nil 表示仍在等待
+func (s *Service) receipt(ctx context.Context, hash, raw string) (*types.Receipt, error) {
+	receipt, err := s.client.TransactionReceipt(ctx, common.HexToHash(hash))
+	if err == nil {
+		return receipt, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return nil, err
+	}
+	if _, _, err := s.client.TransactionByHash(ctx, common.HexToHash(hash)); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, ethereum.NotFound) {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	tx, err := decodeTx(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.SendTransaction(ctx, tx); err != nil && !alreadyKnown(err) {
+		log.Printf("重新广播交易 %s 失败: %v", hash, err)
+	}
+	return nil, nil
+}
+
+// alreadyKnown 节点已有该交易（重复广播）
+func alreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
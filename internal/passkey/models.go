@@ -0,0 +1,50 @@
+package passkey
+
+import (
+	"time"
+)
+
+// UserHandleModel GORM WebAuthn 用户句柄模型（随机生成，不暴露用户 ID 与邮箱）
+type UserHandleModel struct {
+	UserID              int64     `gorm:"primaryKey;column:user_id"`
+	Handle              []byte    `gorm:"uniqueIndex;not null;column:handle"`
+	RequireForTransfers bool      `gorm:"not null;default:false;column:require_for_transfers"` // 转账必须使用通行密钥确认
+	CreatedAt           time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (UserHandleModel) TableName() string {
+	return "webauthn_users"
+}
+
+// CredentialModel GORM 通行密钥模型
+type CredentialModel struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement"`
+	UserID       int64      `gorm:"index;not null;column:user_id"`
+	CredentialID string     `gorm:"uniqueIndex;not null;column:credential_id"` // base64url
+	Name         string     `gorm:"not null;column:name"`
+	Data         string     `gorm:"not null;column:data"` // webauthn.Credential JSON（公钥、签名计数等）
+	LastUsedAt   *time.Time `gorm:"column:last_used_at"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (CredentialModel) TableName() string {
+	return "webauthn_credentials"
+}
+
+// CeremonyModel GORM 进行中的注册/登录/确认流程（一次性，完成或过期后删除）
+type CeremonyModel struct {
+	ID        string    `gorm:"primaryKey;column:id"`
+	UserID    int64     `gorm:"index;column:user_id"` // 可发现凭据登录时为 0
+	Kind      string    `gorm:"not null;column:kind"`
+	Session   string    `gorm:"not null;column:session"` // webauthn.SessionData JSON
+	Message   string    `gorm:"column:message"`          // 交易确认时被承诺的交易参数
+	ExpiresAt time.Time `gorm:"index;not null;column:expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (CeremonyModel) TableName() string {
+	return "webauthn_ceremonies"
+}
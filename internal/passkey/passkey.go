@@ -0,0 +1,415 @@
+package passkey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 流程类型
+const (
+	kindRegister = "register"
+	kindLogin    = "login"
+	kindConfirm  = "confirm"
+)
+
+const ceremonyTTL = 5 * time.Minute
+
+var (
+	// ErrCeremonyNotFound 流程不存在、已使用或已过期
+	ErrCeremonyNotFound = errors.New("通行密钥流程不存在或已过期，请重新开始")
+	// ErrVerificationFailed 凭据或断言校验失败
+	ErrVerificationFailed = errors.New("通行密钥验证失败")
+	// ErrCredentialNotFound 通行密钥不存在
+	ErrCredentialNotFound = errors.New("通行密钥不存在")
+	// ErrTxMismatch 断言确认的交易参数与实际请求不一致
+	ErrTxMismatch = errors.New("通行密钥确认的交易参数与请求不一致")
+)
+
+// Service 通行密钥（WebAuthn）注册、登录与交易确认
+type Service struct {
+	db *gorm.DB
+	wa *webauthn.WebAuthn
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, rpID, rpName string, origins []string) (*Service, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("WebAuthn 配置无效: %w", err)
+	}
+	if err := db.AutoMigrate(&UserHandleModel{}, &CredentialModel{}, &CeremonyModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, wa: wa}, nil
+}
+
+// user 实现 webauthn.User
+type user struct {
+	id          int64
+	handle      []byte
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u *user) WebAuthnID() []byte                         { return u.handle }
+func (u *user) WebAuthnName() string                       { return u.name }
+func (u *user) WebAuthnDisplayName() string                { return u.name }
+func (u *user) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// handleFor 返回用户的 WebAuthn 句柄，不存在时生成
+func (s *Service) handleFor(userID int64) (*UserHandleModel, error) {
+	var model UserHandleModel
+	err := s.db.First(&model, "user_id = ?", userID).Error
+	if err == nil {
+		return &model, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	handle := make([]byte, 32)
+	if _, err := rand.Read(handle); err != nil {
+		return nil, err
+	}
+	model = UserHandleModel{UserID: userID, Handle: handle, CreatedAt: time.Now()}
+	if err := s.db.Create(&model).Error; err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// loadUser 加载用户句柄与全部凭据
+func (s *Service) loadUser(userID int64, name string) (*user, error) {
+	h, err := s.handleFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	var models []CredentialModel
+	if err := s.db.Where("user_id = ?", userID).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	u := &user{id: userID, handle: h.Handle, name: name}
+	for _, m := range models {
+		var cred webauthn.Credential
+		if err := json.Unmarshal([]byte(m.Data), &cred); err != nil {
+			return nil, fmt.Errorf("解析通行密钥 %d 失败: %w", m.ID, err)
+		}
+		u.credentials = append(u.credentials, cred)
+	}
+	return u, nil
+}
+
+// saveCeremony 保存进行中的流程，返回流程 ID
+func (s *Service) saveCeremony(userID int64, kind string, session *webauthn.SessionData, message string) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	ceremony := CeremonyModel{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Kind:      kind,
+		Session:   string(data),
+		Message:   message,
+		ExpiresAt: now.Add(ceremonyTTL),
+		CreatedAt: now,
+	}
+	// 顺便清理过期流程
+	if err := s.db.Where("expires_at < ?", now).Delete(&CeremonyModel{}).Error; err != nil {
+		return "", err
+	}
+	if err := s.db.Create(&ceremony).Error; err != nil {
+		return "", err
+	}
+	return ceremony.ID, nil
+}
+
+// takeCeremony 取出并删除流程，保证每个流程只能完成一次
+func (s *Service) takeCeremony(id string, userID int64, kind string) (*CeremonyModel, *webauthn.SessionData, error) {
+	var ceremony CeremonyModel
+	if err := s.db.First(&ceremony, "id = ? AND user_id = ? AND kind = ?", id, userID, kind).Error; err != nil {
+		return nil, nil, ErrCeremonyNotFound
+	}
+	result := s.db.Delete(&CeremonyModel{}, "id = ?", id)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(ceremony.ExpiresAt) {
+		return nil, nil, ErrCeremonyNotFound
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(ceremony.Session), &session); err != nil {
+		return nil, nil, err
+	}
+	return &ceremony, &session, nil
+}
+
+// BeginRegistration 开始注册通行密钥（要求可发现凭据与用户验证）
+func (s *Service) BeginRegistration(userID int64, name string) (string, *protocol.CredentialCreation, error) {
+	u, err := s.loadUser(userID, name)
+	if err != nil {
+		return "", nil, err
+	}
+	creation, session, err := s.wa.BeginRegistration(u,
+		webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := s.saveCeremony(userID, kindRegister, session, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return id, creation, nil
+}
+
+// FinishRegistration 校验认证器返回的凭据并保存
+func (s *Service) FinishRegistration(userID int64, ceremonyID, name, label string, response []byte) (*CredentialModel, error) {
+	_, session, err := s.takeCeremony(ceremonyID, userID, kindRegister)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	u, err := s.loadUser(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.wa.CreateCredential(u, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, err
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = "通行密钥"
+	}
+	if r := []rune(label); len(r) > 64 {
+		label = string(r[:64])
+	}
+	model := &CredentialModel{
+		UserID:       userID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(cred.ID),
+		Name:         label,
+		Data:         string(data),
+		CreatedAt:    time.Now(),
+	}
+	if err := s.db.Create(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// BeginLogin 开始可发现凭据登录（无需先输入邮箱）
+func (s *Service) BeginLogin() (string, *protocol.CredentialAssertion, error) {
+	assertion, session, err := s.wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := s.saveCeremony(0, kindLogin, session, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return id, assertion, nil
+}
+
+// FinishLogin 校验登录断言，返回用户 ID
+func (s *Service) FinishLogin(ceremonyID string, response []byte) (int64, error) {
+	_, session, err := s.takeCeremony(ceremonyID, 0, kindLogin)
+	if err != nil {
+		return 0, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+
+	var found *user
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var h UserHandleModel
+		if err := s.db.First(&h, "handle = ?", userHandle).Error; err != nil {
+			return nil, ErrCredentialNotFound
+		}
+		u, err := s.loadUser(h.UserID, "")
+		if err != nil {
+			return nil, err
+		}
+		found = u
+		return u, nil
+	}
+	_, cred, err := s.wa.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	if err := s.updateCredential(found.id, cred); err != nil {
+		return 0, err
+	}
+	return found.id, nil
+}
+
+// TxMessage 交易确认的规范化参数，challenge = SHA-256(message) || 16 字节随机数
+func TxMessage(chainID uint64, contract, account, to, amount string) string {
+	if account == "" {
+		account = "default"
+	}
+	return fmt.Sprintf("QXB transfer\nChain ID: %d\nContract: %s\nAccount: %s\nTo: %s\nAmount: %s",
+		chainID, contract, account, to, amount)
+}
+
+// SettingsMessage 通行密钥设置变更（删除通行密钥、关闭转账确认）的重新认证参数，与交易确认共用 challenge 承诺方式
+func SettingsMessage(chainID uint64, action string) string {
+	return fmt.Sprintf("QXB passkey settings\nChain ID: %d\nAction: %s", chainID, action)
+}
+
+// BeginConfirmation 开始交易（或设置变更）确认，challenge 承诺 message
+func (s *Service) BeginConfirmation(userID int64, message string) (string, *protocol.CredentialAssertion, error) {
+	u, err := s.loadUser(userID, "")
+	if err != nil {
+		return "", nil, err
+	}
+	if len(u.credentials) == 0 {
+		return "", nil, ErrCredentialNotFound
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	digest := sha256.Sum256([]byte(message))
+	challenge := append(digest[:], nonce...)
+
+	assertion, session, err := s.wa.BeginLogin(u,
+		webauthn.WithChallenge(challenge),
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := s.saveCeremony(userID, kindConfirm, session, message)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, assertion, nil
+}
+
+// VerifyConfirmation 校验确认断言；message 必须与开始确认时的参数一致
+func (s *Service) VerifyConfirmation(userID int64, ceremonyID, message string, response []byte) error {
+	ceremony, session, err := s.takeCeremony(ceremonyID, userID, kindConfirm)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(message))
+	challenge, err := base64.RawURLEncoding.DecodeString(session.Challenge)
+	if err != nil || ceremony.Message != message || !bytes.HasPrefix(challenge, digest[:]) {
+		return ErrTxMismatch
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	u, err := s.loadUser(userID, "")
+	if err != nil {
+		return err
+	}
+	cred, err := s.wa.ValidateLogin(u, *session, parsed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+	}
+	return s.updateCredential(userID, cred)
+}
+
+// updateCredential 保存新的签名计数；计数回退说明凭据可能被克隆，拒绝本次验证
+func (s *Service) updateCredential(userID int64, cred *webauthn.Credential) error {
+	if cred.Authenticator.CloneWarning {
+		return fmt.Errorf("%w: 签名计数异常，凭据可能被复制", ErrVerificationFailed)
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&CredentialModel{}).
+		Where("user_id = ? AND credential_id = ?", userID, base64.RawURLEncoding.EncodeToString(cred.ID)).
+		Updates(map[string]interface{}{"data": string(data), "last_used_at": time.Now()}).Error
+}
+
+// List 列出用户的通行密钥
+func (s *Service) List(userID int64) ([]CredentialModel, error) {
+	var models []CredentialModel
+	err := s.db.Where("user_id = ?", userID).Order("id").Find(&models).Error
+	return models, err
+}
+
+// Delete 删除通行密钥；删除最后一个时同时关闭转账确认要求
+func (s *Service) Delete(userID, id int64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND id = ?", userID, id).Delete(&CredentialModel{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCredentialNotFound
+		}
+		var remaining int64
+		if err := tx.Model(&CredentialModel{}).Where("user_id = ?", userID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Model(&UserHandleModel{}).Where("user_id = ?", userID).
+				Update("require_for_transfers", false).Error
+		}
+		return nil
+	})
+}
+
+// RequiredForTransfers 用户是否要求转账必须使用通行密钥确认
+func (s *Service) RequiredForTransfers(userID int64) (bool, error) {
+	var h UserHandleModel
+	err := s.db.First(&h, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return h.RequireForTransfers, err
+}
+
+// SetRequiredForTransfers 设置转账是否必须使用通行密钥确认（开启时至少要有一个通行密钥）
+func (s *Service) SetRequiredForTransfers(userID int64, required bool) error {
+	if required {
+		var count int64
+		if err := s.db.Model(&CredentialModel{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrCredentialNotFound
+		}
+	}
+	if _, err := s.handleFor(userID); err != nil {
+		return err
+	}
+	return s.db.Model(&UserHandleModel{}).Where("user_id = ?", userID).
+		Update("require_for_transfers", required).Error
+}
@@ -0,0 +1,305 @@
+package passkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+
+	"lbtc/internal/storage"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator 软件认证器：ES256 密钥、none 证明，每次断言都带用户在场与用户验证标志
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	credID     []byte
+	userHandle []byte
+	counter    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 16)
+	if _, err := rand.Read(credID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credID: credID}
+}
+
+func clientData(t *testing.T, typ string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": b64.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// authData rpIdHash || flags || signCount [|| 认证器凭据数据]
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte(nil), rpHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	return append(data, attested...)
+}
+
+// create 模拟 navigator.credentials.create()
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credID)))
+	attested = append(attested, a.credID...)
+	attested = append(attested, coseKey...)
+
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(flags, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// get 模拟 navigator.credentials.get()，签名 authData || SHA-256(clientDataJSON)
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	a.counter++
+	authData := a.authData(byte(protocol.FlagUserPresent|protocol.FlagUserVerified), nil)
+	cd := clientData(t, "webauthn.get", assertion.Response.Challenge)
+	cdHash := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), cdHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(cd),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(sig),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) marshal(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       b64.EncodeToString(a.credID),
+		"rawId":    b64.EncodeToString(a.credID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := storage.OpenGORM(filepath.Join(t.TempDir(), "passkey.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewService(db, testRPID, "QXB", []string{testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// register 用软件认证器为用户注册一个通行密钥
+func register(t *testing.T, s *Service, a *softAuthenticator, userID int64) *CredentialModel {
+	t.Helper()
+	id, creation, err := s.BeginRegistration(userID, "user@example.com")
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	cred, err := s.FinishRegistration(userID, id, "user@example.com", "软件认证器", a.create(t, creation))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+
+	cred := register(t, s, a, 42)
+	if cred.CredentialID != b64.EncodeToString(a.credID) {
+		t.Fatalf("credential id = %s", cred.CredentialID)
+	}
+
+	id, assertion, err := s.BeginLogin()
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	response := a.get(t, assertion)
+	userID, err := s.FinishLogin(id, response)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if userID != 42 {
+		t.Fatalf("FinishLogin user = %d, want 42", userID)
+	}
+
+	// 流程只能完成一次
+	if _, err := s.FinishLogin(id, response); !errors.Is(err, ErrCeremonyNotFound) {
+		t.Fatalf("replayed login err = %v, want ErrCeremonyNotFound", err)
+	}
+}
+
+func TestLoginRejectsUnknownKey(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+	register(t, s, a, 42)
+
+	// 同一凭据 ID 与用户句柄，但用另一把私钥签名
+	forged := newSoftAuthenticator(t)
+	forged.credID, forged.userHandle = a.credID, a.userHandle
+
+	id, assertion, err := s.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FinishLogin(id, forged.get(t, assertion)); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("forged login err = %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestTransferConfirmation(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+	register(t, s, a, 42)
+
+	const contract = "0x1111111111111111111111111111111111111111"
+	const to = "0x2222222222222222222222222222222222222222"
+	message := TxMessage(11155111, contract, "", to, "1000")
+
+	id, assertion, err := s.BeginConfirmation(42, message)
+	if err != nil {
+		t.Fatalf("BeginConfirmation: %v", err)
+	}
+	if err := s.VerifyConfirmation(42, id, message, a.get(t, assertion)); err != nil {
+		t.Fatalf("VerifyConfirmation: %v", err)
+	}
+}
+
+func TestTransferConfirmationMismatch(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+	register(t, s, a, 42)
+
+	const contract = "0x1111111111111111111111111111111111111111"
+	const to = "0x2222222222222222222222222222222222222222"
+	signed := TxMessage(11155111, contract, "", to, "1000")
+
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"amount", TxMessage(11155111, contract, "", to, "1000000")},
+		{"recipient", TxMessage(11155111, contract, "", "0x3333333333333333333333333333333333333333", "1000")},
+		{"account", TxMessage(11155111, contract, "savings", to, "1000")},
+		{"settings", SettingsMessage(11155111, "disable transfer confirmation")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, assertion, err := s.BeginConfirmation(42, signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 断言本身有效，但承诺的是另一笔交易
+			if err := s.VerifyConfirmation(42, id, tt.message, a.get(t, assertion)); !errors.Is(err, ErrTxMismatch) {
+				t.Fatalf("err = %v, want ErrTxMismatch", err)
+			}
+			// 不一致时流程同样作废，不能改用正确的参数重试
+			if err := s.VerifyConfirmation(42, id, signed, a.get(t, assertion)); !errors.Is(err, ErrCeremonyNotFound) {
+				t.Fatalf("retry err = %v, want ErrCeremonyNotFound", err)
+			}
+		})
+	}
+}
+
+func TestConfirmationBoundToUser(t *testing.T) {
+	s := newTestService(t)
+	a := newSoftAuthenticator(t)
+	register(t, s, a, 42)
+	register(t, s, newSoftAuthenticator(t), 43)
+
+	message := SettingsMessage(11155111, "delete passkey 1")
+	id, assertion, err := s.BeginConfirmation(42, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyConfirmation(43, id, message, a.get(t, assertion)); !errors.Is(err, ErrCeremonyNotFound) {
+		t.Fatalf("other user err = %v, want ErrCeremonyNotFound", err)
+	}
+}
+
+func TestDeleteLastCredentialClearsPolicy(t *testing.T) {
+	s := newTestService(t)
+	cred := register(t, s, newSoftAuthenticator(t), 42)
+
+	if err := s.SetRequiredForTransfers(42, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(42, cred.ID); err != nil {
+		t.Fatal(err)
+	}
+	required, err := s.RequiredForTransfers(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required {
+		t.Fatal("requireForTransfers still set after deleting the last passkey")
+	}
+}
@@ -0,0 +1,18 @@
+package pow
+
+import (
+	"time"
+)
+
+// SpentModel GORM 已使用的挑战（防重放），过期后清理
+type SpentModel struct {
+	ID        string    `gorm:"primaryKey;column:id"` // 挑战随机数
+	Action    string    `gorm:"index:idx_pow_spent_action_time;not null;column:action"`
+	CreatedAt time.Time `gorm:"index:idx_pow_spent_action_time;not null;column:created_at"`
+	ExpiresAt time.Time `gorm:"index;not null;column:expires_at"`
+}
+
+// TableName 指定表名
+func (SpentModel) TableName() string {
+	return "pow_spent"
+}
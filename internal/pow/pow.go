@@ -0,0 +1,213 @@
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 需要工作量证明的操作
+const (
+	ActionRegister = "register" // 注册
+	ActionClaim    = "claim"    // 领取每日奖励
+	ActionFaucet   = "faucet"   // 转账前的 Gas 补贴
+)
+
+// volumeWindow 统计请求量的时间窗口：窗口内完成的证明超过 TargetRate 后逐步提高难度
+const volumeWindow = 10 * time.Minute
+
+// maxSolutionLen 解的最大长度
+const maxSolutionLen = 64
+
+var (
+	ErrRequired     = errors.New("需要先完成工作量证明")
+	ErrInvalid      = errors.New("工作量证明无效")
+	ErrExpired      = errors.New("工作量证明已过期，请重新获取")
+	ErrReplayed     = errors.New("工作量证明已使用过，请重新获取")
+	ErrInsufficient = errors.New("工作量证明的解不满足难度要求")
+)
+
+// Config 工作量证明配置；Difficulty 为 0 表示不启用
+type Config struct {
+	Secret        []byte        // HMAC 密钥
+	Difficulty    int           // 基础难度（SHA-256 前导零位数）
+	MaxDifficulty int           // 请求量升高时的难度上限
+	TTL           time.Duration // 挑战有效期
+	TargetRate    int           // 每个操作在 10 分钟内完成的证明超过该数量后，每翻一倍难度加 1
+}
+
+// Challenge 下发给客户端的挑战
+type Challenge struct {
+	Action     string
+	Token      string
+	Difficulty int
+	ExpiresAt  time.Time
+}
+
+// Service 无状态的 hashcash 挑战：挑战由 HMAC 签名并绑定操作与客户端 IP，服务器不保存下发的挑战，只记录已使用的挑战防止重放
+// 客户端需要找到 solution，使 SHA-256(token + ":" + solution) 至少有 difficulty 个前导零位
+type Service struct {
+	db  *gorm.DB
+	cfg Config
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, cfg Config) (*Service, error) {
+	if cfg.Difficulty > 0 && len(cfg.Secret) == 0 {
+		return nil, fmt.Errorf("工作量证明密钥不能为空")
+	}
+	if cfg.MaxDifficulty < cfg.Difficulty {
+		cfg.MaxDifficulty = cfg.Difficulty
+	}
+	if err := db.AutoMigrate(&SpentModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Service{db: db, cfg: cfg}, nil
+}
+
+// Enabled 是否启用工作量证明
+func (s *Service) Enabled() bool {
+	return s.cfg.Difficulty > 0
+}
+
+// ValidAction 是否为需要工作量证明的操作
+func ValidAction(action string) bool {
+	return action == ActionRegister || action == ActionClaim || action == ActionFaucet
+}
+
+// Difficulty 操作当前的难度：最近 10 分钟完成的证明超过 TargetRate 后，每翻一倍加 1，不超过 MaxDifficulty
+func (s *Service) Difficulty(action string) (int, error) {
+	difficulty := s.cfg.Difficulty
+	if s.cfg.TargetRate <= 0 {
+		return difficulty, nil
+	}
+	var count int64
+	err := s.db.Model(&SpentModel{}).Where("action = ? AND created_at >= ?", action, time.Now().Add(-volumeWindow)).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	if count > int64(s.cfg.TargetRate) {
+		difficulty += bits.Len64(uint64(count / int64(s.cfg.TargetRate)))
+	}
+	if difficulty > s.cfg.MaxDifficulty {
+		difficulty = s.cfg.MaxDifficulty
+	}
+	return difficulty, nil
+}
+
+// Issue 为 ip 签发 action 的挑战
+func (s *Service) Issue(action, ip string) (*Challenge, error) {
+	difficulty, err := s.Difficulty(action)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.cfg.TTL).Truncate(time.Second)
+	payload := strings.Join([]string{"v1", action, strconv.Itoa(difficulty), strconv.FormatInt(expiresAt.Unix(), 10), hex.EncodeToString(nonce)}, ".")
+	return &Challenge{
+		Action:     action,
+		Token:      payload + "." + s.sign(payload, ip),
+		Difficulty: difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// sign 对挑战与客户端 IP 计算 HMAC；IP 不出现在挑战中，只参与签名
+func (s *Service) sign(payload, ip string) string {
+	mac := hmac.New(sha256.New, s.cfg.Secret)
+	mac.Write([]byte(payload + "|" + ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验 ip 为 action 提交的挑战与解，通过后记录挑战已使用
+func (s *Service) Verify(action, ip, token, solution string) error {
+	if token == "" || solution == "" {
+		return ErrRequired
+	}
+	if len(solution) > maxSolutionLen {
+		return ErrInvalid
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 6 || parts[0] != "v1" {
+		return ErrInvalid
+	}
+	payload := strings.Join(parts[:5], ".")
+	if !hmac.Equal([]byte(parts[5]), []byte(s.sign(payload, ip))) || parts[1] != action {
+		return ErrInvalid
+	}
+	difficulty, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return ErrInvalid
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	expiresAt := time.Unix(expires, 0)
+	if time.Now().After(expiresAt) {
+		return ErrExpired
+	}
+	if LeadingZeroBits(token, solution) < difficulty {
+		return ErrInsufficient
+	}
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&SpentModel{
+		ID:        parts[4],
+		Action:    action,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// LeadingZeroBits SHA-256(token + ":" + solution) 的前导零位数
+func LeadingZeroBits(token, solution string) int {
+	sum := sha256.Sum256([]byte(token + ":" + solution))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Run 每隔 interval 清理已过期且不再计入请求量的挑战记录，直到 ctx 结束
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			err := s.db.Where("expires_at < ? AND created_at < ?", now, now.Add(-volumeWindow)).Delete(&SpentModel{}).Error
+			if err != nil {
+				log.Printf("清理工作量证明记录失败: %v", err)
+			}
+		}
+	}
+}
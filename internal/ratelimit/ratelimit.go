@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventModel GORM 限流事件模型（滑动窗口日志，每次尝试一条）
+type EventModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Key       string    `gorm:"index:idx_rate_limit_key_time;not null;column:key"`
+	CreatedAt time.Time `gorm:"index:idx_rate_limit_key_time;not null;column:created_at"`
+}
+
+// TableName 指定表名
+func (EventModel) TableName() string {
+	return "rate_limit_events"
+}
+
+// LockoutModel GORM 连续失败与锁定状态模型
+type LockoutModel struct {
+	Key           string     `gorm:"primaryKey;column:key"`
+	Failures      int        `gorm:"not null;column:failures"` // 上次成功或锁定以来的连续失败次数
+	Lockouts      int        `gorm:"not null;column:lockouts"` // 累计锁定次数，锁定时长随之翻倍
+	NextAttemptAt *time.Time `gorm:"column:next_attempt_at"`   // 渐进延迟：此时间之前拒绝尝试
+	LockedUntil   *time.Time `gorm:"column:locked_until"`      // 临时锁定到期时间
+	UpdatedAt     time.Time  `gorm:"not null;column:updated_at"`
+}
+
+// TableName 指定表名
+func (LockoutModel) TableName() string {
+	return "auth_lockouts"
+}
+
+// Rule 滑动窗口规则：Window 内最多 Limit 次
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// LockoutPolicy 连续失败策略
+type LockoutPolicy struct {
+	FreeFailures    int           // 不延迟的失败次数
+	BaseDelay       time.Duration // 超过后第一次延迟，之后每次翻倍
+	MaxDelay        time.Duration
+	LockAfter       int           // 连续失败达到该次数后锁定
+	LockDuration    time.Duration // 第 n 次锁定时长为 LockDuration × 2^(n-1)
+	MaxLockDuration time.Duration
+}
+
+// ErrLimited 触发限流、延迟或锁定
+type ErrLimited struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ErrLimited) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("尝试失败次数过多，账户已临时锁定，请 %d 秒后再试", retrySeconds(e.RetryAfter))
+	}
+	return fmt.Sprintf("请求过于频繁，请 %d 秒后再试", retrySeconds(e.RetryAfter))
+}
+
+func retrySeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// Limiter 基于数据库的滑动窗口限流与失败锁定，多实例共享同一数据库时同样有效
+type Limiter struct {
+	db        *gorm.DB
+	maxWindow time.Duration // 清理时保留的最长窗口
+}
+
+// NewLimiter 创建限流器并初始化表结构
+func NewLimiter(db *gorm.DB) (*Limiter, error) {
+	if err := db.AutoMigrate(&EventModel{}, &LockoutModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Limiter{db: db, maxWindow: 24 * time.Hour}, nil
+}
+
+// Allow 检查 key 在窗口内的次数，未超限时记录本次尝试；超限返回 *ErrLimited
+func (l *Limiter) Allow(key string, rule Rule) error {
+	now := time.Now()
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&EventModel{}).
+			Where("key = ? AND created_at > ?", key, now.Add(-rule.Window)).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(rule.Limit) {
+			// 窗口内第 count-Limit+1 早的记录滑出窗口后才有余量
+			var oldest EventModel
+			if err := tx.Where("key = ? AND created_at > ?", key, now.Add(-rule.Window)).
+				Order("created_at").Offset(int(count) - rule.Limit).First(&oldest).Error; err != nil {
+				return err
+			}
+			return &ErrLimited{RetryAfter: oldest.CreatedAt.Add(rule.Window).Sub(now)}
+		}
+		return tx.Create(&EventModel{Key: key, CreatedAt: now}).Error
+	})
+}
+
+// Check 检查 key 是否处于渐进延迟或锁定中
+func (l *Limiter) Check(key string) error {
+	var state LockoutModel
+	err := l.db.First(&state, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if state.LockedUntil != nil && now.Before(*state.LockedUntil) {
+		return &ErrLimited{RetryAfter: state.LockedUntil.Sub(now), Locked: true}
+	}
+	if state.NextAttemptAt != nil && now.Before(*state.NextAttemptAt) {
+		return &ErrLimited{RetryAfter: state.NextAttemptAt.Sub(now)}
+	}
+	return nil
+}
+
+// Failure 记录一次失败，返回本次是否触发锁定以及锁定到期时间
+func (l *Limiter) Failure(key string, policy LockoutPolicy) (bool, time.Time, error) {
+	var locked bool
+	var until time.Time
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		var state LockoutModel
+		err := tx.First(&state, "key = ?", key).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		now := time.Now()
+		state.Key = key
+		state.Failures++
+		state.UpdatedAt = now
+		state.NextAttemptAt = nil
+
+		switch {
+		case state.Failures >= policy.LockAfter:
+			state.Lockouts++
+			d := policy.LockDuration << (state.Lockouts - 1)
+			if d > policy.MaxLockDuration || d <= 0 {
+				d = policy.MaxLockDuration
+			}
+			until = now.Add(d)
+			locked = true
+			state.Failures = 0
+			state.LockedUntil = &until
+		case state.Failures > policy.FreeFailures:
+			d := policy.BaseDelay << (state.Failures - policy.FreeFailures - 1)
+			if d > policy.MaxDelay || d <= 0 {
+				d = policy.MaxDelay
+			}
+			next := now.Add(d)
+			state.NextAttemptAt = &next
+		}
+		return tx.Save(&state).Error
+	})
+	return locked, until, err
+}
+
+// Success 成功后清除失败计数与锁定记录
+func (l *Limiter) Success(key string) error {
+	return l.db.Delete(&LockoutModel{}, "key = ?", key).Error
+}
+
+// Run 定期清理过期的限流事件和锁定记录
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.cleanup()
+		}
+	}
+}
+
+func (l *Limiter) cleanup() {
+	now := time.Now()
+	if err := l.db.Where("created_at < ?", now.Add(-l.maxWindow)).Delete(&EventModel{}).Error; err != nil {
+		log.Printf("清理限流记录失败: %v", err)
+	}
+	// 锁定次数保留一段时间，使短期内反复触发的锁定逐次延长
+	if err := l.db.Where("updated_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-l.maxWindow), now).
+		Delete(&LockoutModel{}).Error; err != nil {
+		log.Printf("清理锁定记录失败: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package risk
+
+import (
+	"time"
+)
+
+// 自动评估结果
+const (
+	StatusClear   = "clear"   // 未发现异常
+	StatusFlagged = "flagged" // 可疑：暂停 Gas 补贴，等待管理员审核
+	StatusBlocked = "blocked" // 高风险：暂停 Gas 补贴并禁止领取奖励
+)
+
+// 管理员审核决定；为空时按自动评估结果处理
+const (
+	DecisionAllow = "allow" // 放行：不再限制，重新评分也不会改变
+	DecisionBlock = "block" // 封禁：按 blocked 处理
+)
+
+// RegistrationModel GORM 注册信息：注册 IP、设备指纹与邮箱特征，用于关联同一人注册的多个账户
+type RegistrationModel struct {
+	UserID      int64     `gorm:"primaryKey;autoIncrement:false;column:user_id"`
+	IP          string    `gorm:"index;column:ip"`          // 功能上线前注册的用户为空
+	IPPrefix    string    `gorm:"index;column:ip_prefix"`   // IPv4 /24 或 IPv6 /64
+	Fingerprint string    `gorm:"index;column:fingerprint"` // 设备指纹的 SHA-256
+	EmailDomain string    `gorm:"index:idx_risk_email;not null;column:email_domain"`
+	EmailLocal  string    `gorm:"index;not null;column:email_local"`               // 去除 +标签（Gmail 还去除点号）后的本地部分
+	EmailStem   string    `gorm:"index:idx_risk_email;not null;column:email_stem"` // 本地部分去除数字与分隔符
+	CreatedAt   time.Time `gorm:"index;not null;column:created_at"`
+}
+
+// TableName 指定表名
+func (RegistrationModel) TableName() string {
+	return "risk_registrations"
+}
+
+// AssessmentModel GORM 用户风险评估与管理员审核结果
+type AssessmentModel struct {
+	UserID     int64      `gorm:"primaryKey;autoIncrement:false;column:user_id"`
+	Score      int        `gorm:"index;not null;column:score"`
+	Signals    string     `gorm:"not null;column:signals"` // []Signal 的 JSON
+	Status     string     `gorm:"index;not null;column:status"`
+	Decision   string     `gorm:"index;column:decision"`
+	Note       string     `gorm:"column:note"`
+	ReviewedBy string     `gorm:"column:reviewed_by"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at"`
+	ScoredAt   time.Time  `gorm:"not null;column:scored_at"`
+}
+
+// TableName 指定表名
+func (AssessmentModel) TableName() string {
+	return "risk_assessments"
+}
+
+// Effective 综合管理员决定与自动评估的最终状态
+func (a *AssessmentModel) Effective() string {
+	switch a.Decision {
+	case DecisionAllow:
+		return StatusClear
+	case DecisionBlock:
+		return StatusBlocked
+	}
+	return a.Status
+}
@@ -0,0 +1,577 @@
+package risk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"lbtc/internal/auth"
+	"lbtc/internal/claims"
+)
+
+// 风险信号代码
+const (
+	SignalSharedIP          = "shared_ip"          // 同一 IP 短时间内注册多个账户
+	SignalSharedDevice      = "shared_device"      // 多个账户使用同一设备指纹注册
+	SignalRegistrationBurst = "registration_burst" // 同一网段集中注册
+	SignalDisposableEmail   = "disposable_email"   // 一次性邮箱域名
+	SignalEmailAlias        = "email_alias"        // 与其他账户是同一邮箱的别名（+标签、Gmail 点号）
+	SignalEmailPattern      = "email_pattern"      // 同一域名下批量编号的邮箱
+	SignalFundSink          = "fund_sink"          // 领取的 QXB 转入多人共用的归集地址
+	SignalCoClaim           = "co_claim"           // 与另一账户多日在同一区块领取
+)
+
+const (
+	sharedIPWindow  = 24 * time.Hour   // 统计同一 IP 注册的时间窗口（前后）
+	burstWindow     = 10 * time.Minute // 统计同一网段集中注册的时间窗口（前后）
+	sinkMinOwners   = 5                // 归集地址至少接收多少个不同用户（或未注册地址）的领取地址转账
+	coClaimDays     = 14               // 同区块领取的统计天数
+	coClaimMinDays  = 5                // 同一对地址至少多少天在同一区块领取
+	coClaimMaxBlock = 100              // 同一区块领取地址超过该数量时不统计（避免配对数爆炸）
+)
+
+// defaultDisposableDomains 内置的一次性邮箱域名
+var defaultDisposableDomains = []string{
+	"mailinator.com", "guerrillamail.com", "sharklasers.com", "10minutemail.com", "temp-mail.org",
+	"tempmail.com", "yopmail.com", "trashmail.com", "getnada.com", "dispostable.com",
+	"maildrop.cc", "throwawaymail.com", "fakeinbox.com", "mohmal.com", "emailondeck.com",
+}
+
+// ErrBlocked 账户被风控限制领取
+var ErrBlocked = errors.New("账户存在批量注册风险，已被限制领取奖励，请联系管理员")
+
+// Signal 一条风险信号
+type Signal struct {
+	Code   string `json:"code"`
+	Points int    `json:"points"`
+	Detail string `json:"detail"`
+}
+
+// Policy 评分阈值；阈值为 0 表示不启用该级别
+type Policy struct {
+	FlagScore         int      // 达到该分数标记为可疑（暂停 Gas 补贴）
+	BlockScore        int      // 达到该分数限制领取
+	DisposableDomains []string // 追加到内置列表的一次性邮箱域名
+}
+
+// Service 根据注册信息与链上行为为用户评分，管理员可审核放行或封禁
+type Service struct {
+	db         *gorm.DB
+	auth       *auth.Service
+	claims     *claims.Service
+	policy     Policy
+	disposable map[string]bool
+
+	mu    sync.RWMutex
+	chain map[string][]Signal // 最近一次后台评分时按地址计算的链上信号
+}
+
+// NewService 创建服务并初始化表结构
+func NewService(db *gorm.DB, authService *auth.Service, claimsService *claims.Service, policy Policy) (*Service, error) {
+	if err := db.AutoMigrate(&RegistrationModel{}, &AssessmentModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	disposable := make(map[string]bool)
+	for _, d := range append(defaultDisposableDomains, policy.DisposableDomains...) {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			disposable[d] = true
+		}
+	}
+	return &Service{db: db, auth: authService, claims: claimsService, policy: policy, disposable: disposable}, nil
+}
+
+// Fingerprint 计算设备指纹：优先使用客户端提供的指纹，否则由 User-Agent、Accept-Language 与网段组合（同一网络内相同浏览器才会相同）
+func Fingerprint(client, userAgent, acceptLanguage, ip string) string {
+	source := "client:" + client
+	if client == "" {
+		if userAgent == "" {
+			return ""
+		}
+		source = "ua:" + userAgent + "|" + acceptLanguage + "|" + ipPrefix(ip)
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// ipPrefix IPv4 取 /24，IPv6 取 /64
+func ipPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// normalizeEmail 返回域名、去除别名后的本地部分与去除数字和分隔符后的词干
+func normalizeEmail(email string) (domain, local, stem string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", email, email
+	}
+	local, domain = email[:at], email[at+1:]
+	if i := strings.Index(local, "+"); i >= 0 {
+		local = local[:i]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	stem = strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, local)
+	return domain, local, stem
+}
+
+// RecordRegistration 记录注册信息并立即评分（链上信号在后台评分时补充）
+func (s *Service) RecordRegistration(userID int64, email, address, ip, fingerprint string) (*AssessmentModel, error) {
+	domain, local, stem := normalizeEmail(email)
+	reg := &RegistrationModel{
+		UserID:      userID,
+		IP:          ip,
+		IPPrefix:    ipPrefix(ip),
+		Fingerprint: fingerprint,
+		EmailDomain: domain,
+		EmailLocal:  local,
+		EmailStem:   stem,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reg).Error; err != nil {
+		return nil, err
+	}
+	return s.assess(userID, []string{address})
+}
+
+// Status 用户的最终风控状态（未评估视为 clear）
+func (s *Service) Status(userID int64) (string, error) {
+	var a AssessmentModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&a).Error; err != nil {
+		return "", err
+	}
+	if a.UserID == 0 {
+		return StatusClear, nil
+	}
+	return a.Effective(), nil
+}
+
+// StatusByAddress 使用该地址的所有用户中最严重的风控状态（未注册地址视为 clear）
+func (s *Service) StatusByAddress(address string) (string, error) {
+	refs, err := s.auth.AccountsByAddress(address)
+	if err != nil {
+		return "", err
+	}
+	worst := StatusClear
+	for _, ref := range refs {
+		status, err := s.Status(ref.UserID)
+		if err != nil {
+			return "", err
+		}
+		if severity(status) > severity(worst) {
+			worst = status
+		}
+	}
+	return worst, nil
+}
+
+func severity(status string) int {
+	switch status {
+	case StatusBlocked:
+		return 2
+	case StatusFlagged:
+		return 1
+	}
+	return 0
+}
+
+// DecodeSignals 解析评估中的风险信号
+func (a *AssessmentModel) DecodeSignals() []Signal {
+	var signals []Signal
+	_ = json.Unmarshal([]byte(a.Signals), &signals)
+	return signals
+}
+
+// Get 查询用户的评估与注册信息，未评估时返回 nil
+func (s *Service) Get(userID int64) (*AssessmentModel, *RegistrationModel, error) {
+	var a AssessmentModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&a).Error; err != nil {
+		return nil, nil, err
+	}
+	if a.UserID == 0 {
+		return nil, nil, nil
+	}
+	var reg RegistrationModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&reg).Error; err != nil {
+		return nil, nil, err
+	}
+	if reg.UserID == 0 {
+		return &a, nil, nil
+	}
+	return &a, &reg, nil
+}
+
+// ReviewQueue 待审核的用户：自动评估为 flagged 或 blocked 且管理员尚未决定，分数高的在前
+// status 为空时包含两者
+func (s *Service) ReviewQueue(status string, limit int) ([]AssessmentModel, error) {
+	var list []AssessmentModel
+	query := s.db.Where("(decision IS NULL OR decision = '')").Order("score DESC, user_id").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status <> ?", StatusClear)
+	}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// Review 记录管理员决定；decision 为空表示撤销决定，恢复按自动评估处理
+func (s *Service) Review(userID int64, decision, note, reviewer string) (*AssessmentModel, error) {
+	var a AssessmentModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&a).Error; err != nil {
+		return nil, err
+	}
+	if a.UserID == 0 {
+		// 尚未评估的用户先评分，保证审核记录包含当前信号
+		assessed, err := s.assess(userID, nil)
+		if err != nil {
+			return nil, err
+		}
+		a = *assessed
+	}
+	now := time.Now()
+	a.Decision = decision
+	a.Note = note
+	a.ReviewedBy = reviewer
+	a.ReviewedAt = &now
+	err := s.db.Model(&AssessmentModel{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"decision":    decision,
+		"note":        note,
+		"reviewed_by": reviewer,
+		"reviewed_at": now,
+	}).Error
+	return &a, err
+}
+
+// Run 每隔 interval 重新计算链上信号并为所有用户重新评分，直到 ctx 结束；启动时先执行一次
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	if err := s.rescore(ctx); err != nil {
+		log.Printf("风控评分失败: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.rescore(ctx); err != nil {
+				log.Printf("风控评分失败: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) rescore(ctx context.Context) error {
+	var users []auth.UserSummary
+	var after int64
+	for {
+		page, err := s.auth.ListUserSummaries(after, 500)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			break
+		}
+		users = append(users, page...)
+		after = page[len(page)-1].ID
+	}
+
+	owners := make(map[string]int64)
+	for _, u := range users {
+		for _, address := range u.Addresses {
+			owners[address] = u.ID
+		}
+	}
+	chain, err := s.chainSignals(owners)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.chain = chain
+	s.mu.Unlock()
+
+	for _, u := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 功能上线前注册的用户补记邮箱特征（没有 IP 与设备指纹）
+		domain, local, stem := normalizeEmail(u.Email)
+		backfill := &RegistrationModel{UserID: u.ID, EmailDomain: domain, EmailLocal: local, EmailStem: stem, CreatedAt: u.CreatedAt}
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(backfill).Error; err != nil {
+			return err
+		}
+		if _, err := s.assess(u.ID, u.Addresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chainSignals 根据索引的 Transfer 与领取事件计算各地址的链上信号
+func (s *Service) chainSignals(owners map[string]int64) (map[string][]Signal, error) {
+	signals := make(map[string][]Signal)
+	owner := func(address string) string {
+		if id, ok := owners[address]; ok {
+			return fmt.Sprintf("user:%d", id)
+		}
+		return "address:" + address
+	}
+
+	// 归集地址：多个用户的领取地址把 QXB 转到同一地址
+	sinks, err := s.claims.SinkSources(sinkMinOwners)
+	if err != nil {
+		return nil, err
+	}
+	for sink, sources := range sinks {
+		distinct := make(map[string]bool)
+		for _, src := range sources {
+			if owner(src) != owner(sink) {
+				distinct[owner(src)] = true
+			}
+		}
+		if len(distinct) < sinkMinOwners {
+			continue
+		}
+		for _, src := range sources {
+			if owner(src) == owner(sink) {
+				continue
+			}
+			signals[src] = append(signals[src], Signal{
+				Code:   SignalFundSink,
+				Points: 50,
+				Detail: fmt.Sprintf("%s 向归集地址 %s 转出 QXB（共 %d 个账户向其转账）", src, sink, len(distinct)),
+			})
+		}
+		signals[sink] = append(signals[sink], Signal{
+			Code:   SignalFundSink,
+			Points: 50,
+			Detail: fmt.Sprintf("%s 接收了 %d 个账户的领取地址转出的 QXB", sink, len(distinct)),
+		})
+	}
+
+	// 同区块领取：同一对地址多日在同一区块领取，通常是脚本批量发送
+	events, err := s.claims.ClaimsSince(claims.Today() - coClaimDays + 1)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make(map[uint64][]claims.EventModel)
+	for _, e := range events {
+		blocks[e.BlockNumber] = append(blocks[e.BlockNumber], e)
+	}
+	pairDays := make(map[[2]string]map[int64]bool)
+	for _, group := range blocks {
+		if len(group) < 2 || len(group) > coClaimMaxBlock {
+			continue
+		}
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i].Address, group[j].Address
+				if a == b || owner(a) == owner(b) {
+					continue
+				}
+				if a > b {
+					a, b = b, a
+				}
+				key := [2]string{a, b}
+				if pairDays[key] == nil {
+					pairDays[key] = make(map[int64]bool)
+				}
+				pairDays[key][group[i].Day] = true
+			}
+		}
+	}
+	strongest := make(map[string]Signal)
+	strongestDays := make(map[string]int)
+	for pair, days := range pairDays {
+		if len(days) < coClaimMinDays {
+			continue
+		}
+		for k, address := range pair {
+			if len(days) <= strongestDays[address] {
+				continue
+			}
+			strongestDays[address] = len(days)
+			strongest[address] = Signal{
+				Code:   SignalCoClaim,
+				Points: 40,
+				Detail: fmt.Sprintf("%s 与 %s 在最近 %d 天中有 %d 天在同一区块领取", address, pair[1-k], coClaimDays, len(days)),
+			}
+		}
+	}
+	for address, signal := range strongest {
+		signals[address] = append(signals[address], signal)
+	}
+	return signals, nil
+}
+
+// assess 计算并保存用户的评分；addresses 为用户的链上地址，用于合并链上信号
+func (s *Service) assess(userID int64, addresses []string) (*AssessmentModel, error) {
+	var reg RegistrationModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&reg).Error; err != nil {
+		return nil, err
+	}
+	var signals []Signal
+	if reg.UserID != 0 {
+		var err error
+		if signals, err = s.registrationSignals(&reg); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.RLock()
+	for _, address := range addresses {
+		signals = append(signals, s.chain[address]...)
+	}
+	s.mu.RUnlock()
+
+	// 同类信号只计一次（取分数最高的一条）
+	best := make(map[string]Signal)
+	for _, signal := range signals {
+		if prev, ok := best[signal.Code]; !ok || signal.Points > prev.Points {
+			best[signal.Code] = signal
+		}
+	}
+	signals = signals[:0]
+	score := 0
+	for _, signal := range best {
+		signals = append(signals, signal)
+		score += signal.Points
+	}
+	sort.Slice(signals, func(i, j int) bool {
+		if signals[i].Points != signals[j].Points {
+			return signals[i].Points > signals[j].Points
+		}
+		return signals[i].Code < signals[j].Code
+	})
+	encoded, err := json.Marshal(signals)
+	if err != nil {
+		return nil, err
+	}
+
+	status := StatusClear
+	switch {
+	case s.policy.BlockScore > 0 && score >= s.policy.BlockScore:
+		status = StatusBlocked
+	case s.policy.FlagScore > 0 && score >= s.policy.FlagScore:
+		status = StatusFlagged
+	}
+
+	var a AssessmentModel
+	if err := s.db.Where("user_id = ?", userID).Limit(1).Find(&a).Error; err != nil {
+		return nil, err
+	}
+	previous := a.Status
+	a.UserID = userID
+	a.Score = score
+	a.Signals = string(encoded)
+	a.Status = status
+	a.ScoredAt = time.Now()
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "signals", "status", "scored_at"}),
+	}).Create(&a).Error
+	if err != nil {
+		return nil, err
+	}
+	if severity(status) > severity(previous) {
+		log.Printf("⚠️ 用户 %d 风险评分 %d，状态 %s", userID, score, status)
+	}
+	return &a, nil
+}
+
+// registrationSignals 根据注册 IP、设备指纹与邮箱特征计算信号
+func (s *Service) registrationSignals(reg *RegistrationModel) ([]Signal, error) {
+	var signals []Signal
+	others := func(query *gorm.DB) (int64, error) {
+		var n int64
+		err := query.Model(&RegistrationModel{}).Where("user_id <> ?", reg.UserID).Count(&n).Error
+		return n, err
+	}
+	around := func(window time.Duration) *gorm.DB {
+		return s.db.Where("created_at BETWEEN ? AND ?", reg.CreatedAt.Add(-window), reg.CreatedAt.Add(window))
+	}
+
+	if reg.IP != "" {
+		n, err := others(around(sharedIPWindow).Where("ip = ?", reg.IP))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case n >= 10:
+			signals = append(signals, Signal{Code: SignalSharedIP, Points: 60, Detail: fmt.Sprintf("24 小时内同一 IP 注册了另外 %d 个账户", n)})
+		case n >= 3:
+			signals = append(signals, Signal{Code: SignalSharedIP, Points: 30, Detail: fmt.Sprintf("24 小时内同一 IP 注册了另外 %d 个账户", n)})
+		}
+	}
+	if reg.IPPrefix != "" {
+		n, err := others(around(burstWindow).Where("ip_prefix = ?", reg.IPPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if n >= 3 {
+			signals = append(signals, Signal{Code: SignalRegistrationBurst, Points: 20, Detail: fmt.Sprintf("前后 10 分钟内同一网段 %s 注册了另外 %d 个账户", reg.IPPrefix, n)})
+		}
+	}
+	if reg.Fingerprint != "" {
+		n, err := others(s.db.Where("fingerprint = ?", reg.Fingerprint))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case n >= 5:
+			signals = append(signals, Signal{Code: SignalSharedDevice, Points: 60, Detail: fmt.Sprintf("同一设备注册了另外 %d 个账户", n)})
+		case n >= 2:
+			signals = append(signals, Signal{Code: SignalSharedDevice, Points: 30, Detail: fmt.Sprintf("同一设备注册了另外 %d 个账户", n)})
+		}
+	}
+
+	if s.disposable[reg.EmailDomain] {
+		signals = append(signals, Signal{Code: SignalDisposableEmail, Points: 30, Detail: "一次性邮箱域名 " + reg.EmailDomain})
+	}
+	aliases, err := others(s.db.Where("email_domain = ? AND email_local = ?", reg.EmailDomain, reg.EmailLocal))
+	if err != nil {
+		return nil, err
+	}
+	if aliases > 0 {
+		signals = append(signals, Signal{Code: SignalEmailAlias, Points: 50, Detail: fmt.Sprintf("与另外 %d 个账户是同一邮箱的别名", aliases)})
+	}
+	// 只对带编号或分隔符的邮箱（如 alice01、alice.02）检查批量编号，避免常见名字误报
+	if len(reg.EmailStem) >= 3 && reg.EmailStem != reg.EmailLocal {
+		n, err := others(s.db.Where("email_domain = ? AND email_stem = ? AND email_local <> ?", reg.EmailDomain, reg.EmailStem, reg.EmailLocal))
+		if err != nil {
+			return nil, err
+		}
+		if n >= 3 {
+			signals = append(signals, Signal{Code: SignalEmailPattern, Points: 20, Detail: fmt.Sprintf("%s 下另有 %d 个以 %s 加编号命名的邮箱", reg.EmailDomain, n, reg.EmailStem)})
+		}
+	}
+	return signals, nil
+}
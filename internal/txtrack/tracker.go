@@ -0,0 +1,161 @@
+package txtrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gorm.io/gorm"
+)
+
+// 交易状态
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"  // 已上链但执行失败（revert）
+	StatusDropped = "dropped" // 长时间既无收据也不在交易池中
+)
+
+// dropAfter 交易在节点中消失多久后视为被丢弃
+const dropAfter = 30 * time.Minute
+
+// ErrNotFound 交易未被跟踪
+var ErrNotFound = errors.New("交易不存在")
+
+// TxModel GORM 被跟踪的交易模型
+type TxModel struct {
+	Hash        string    `gorm:"primaryKey;column:hash"`
+	UserID      int64     `gorm:"index;not null;column:user_id"`
+	From        string    `gorm:"index;not null;column:from_address"`
+	To          string    `gorm:"not null;column:to_address"`
+	Method      string    `gorm:"column:method"` // 合约方法名，如 transfer / claimDailyReward
+	Nonce       uint64    `gorm:"not null;column:nonce"`
+	Status      string    `gorm:"index;not null;column:status"`
+	BlockNumber uint64    `gorm:"column:block_number"`
+	GasUsed     uint64    `gorm:"column:gas_used"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+// TableName 指定表名
+func (TxModel) TableName() string {
+	return "tracked_txs"
+}
+
+// Tracker 跟踪已广播交易的收据，后台定期更新状态
+type Tracker struct {
+	db     *gorm.DB
+	client *ethclient.Client
+}
+
+// NewTracker 创建跟踪器并初始化表结构
+func NewTracker(db *gorm.DB, client *ethclient.Client) (*Tracker, error) {
+	if err := db.AutoMigrate(&TxModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移表结构失败: %w", err)
+	}
+	return &Tracker{db: db, client: client}, nil
+}
+
+// Track 记录一笔已广播的交易
+func (t *Tracker) Track(userID int64, tx *types.Transaction, from common.Address, method string) (*TxModel, error) {
+	now := time.Now()
+	model := &TxModel{
+		Hash:      tx.Hash().Hex(),
+		UserID:    userID,
+		From:      from.Hex(),
+		To:        tx.To().Hex(),
+		Method:    method,
+		Nonce:     tx.Nonce(),
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := t.db.Create(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// Get 查询用户的交易状态
+func (t *Tracker) Get(userID int64, hash string) (*TxModel, error) {
+	var model TxModel
+	err := t.db.Where("hash = ? AND user_id = ?", common.HexToHash(hash).Hex(), userID).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &model, err
+}
+
+// List 列出用户最近的交易
+func (t *Tracker) List(userID int64, limit int) ([]TxModel, error) {
+	var txs []TxModel
+	err := t.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&txs).Error
+	return txs, err
+}
+
+// Run 每隔 interval 检查一次待确认交易，直到 ctx 结束
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.poll(ctx)
+		}
+	}
+}
+
+func (t *Tracker) poll(ctx context.Context) {
+	var pending []TxModel
+	if err := t.db.Where("status = ?", StatusPending).Order("created_at").Limit(100).Find(&pending).Error; err != nil {
+		log.Printf("查询待确认交易失败: %v", err)
+		return
+	}
+	for i := range pending {
+		if err := t.refresh(ctx, &pending[i]); err != nil {
+			log.Printf("更新交易 %s 状态失败: %v", pending[i].Hash, err)
+		}
+	}
+}
+
+func (t *Tracker) refresh(ctx context.Context, model *TxModel) error {
+	hash := common.HexToHash(model.Hash)
+	receipt, err := t.client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		status := StatusSuccess
+		if receipt.Status == types.ReceiptStatusFailed {
+			status = StatusFailed
+		}
+		return t.db.Model(model).Updates(map[string]interface{}{
+			"status":       status,
+			"block_number": receipt.BlockNumber.Uint64(),
+			"gas_used":     receipt.GasUsed,
+			"updated_at":   time.Now(),
+		}).Error
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+
+	// 没有收据：仍在交易池中则继续等待，否则超时后标记为丢弃
+	if _, _, err := t.client.TransactionByHash(ctx, hash); err == nil {
+		return nil
+	} else if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	if time.Since(model.CreatedAt) < dropAfter {
+		return nil
+	}
+	return t.db.Model(model).Updates(map[string]interface{}{
+		"status":     StatusDropped,
+		"updated_at": time.Now(),
+	}).Error
+}
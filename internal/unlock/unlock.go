@@ -0,0 +1,186 @@
+package unlock
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLocked 钱包未解锁、已过期或登录会话不匹配
+var ErrLocked = errors.New("钱包未解锁或解锁已过期")
+
+// entry 一个解锁会话：私钥在内存中以 AES-GCM 加密，密钥由随机盐和登录会话 ID 派生
+type entry struct {
+	userID     int64
+	salt       []byte
+	ciphertext []byte
+	expiresAt  time.Time
+}
+
+// Store 内存中的钱包解锁会话（按登录会话 ID 区分，访问令牌刷新后仍然有效，重启后全部失效）
+type Store struct {
+	mu      sync.Mutex
+	entries map[[32]byte]*entry
+}
+
+// NewStore 创建会话存储，并启动后台协程定期清理过期会话
+func NewStore(sweepInterval time.Duration) *Store {
+	s := &Store{entries: make(map[[32]byte]*entry)}
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.sweep()
+		}
+	}()
+	return s
+}
+
+// Unlock 保存私钥，ttl 后自动失效；同一登录会话重复解锁会覆盖旧会话
+func (s *Store) Unlock(userID int64, sessionID string, privKey []byte, ttl time.Duration) (time.Time, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return time.Time{}, err
+	}
+	gcm, err := sessionCipher(salt, sessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return time.Time{}, err
+	}
+
+	e := &entry{
+		userID:     userID,
+		salt:       salt,
+		ciphertext: gcm.Seal(nonce, nonce, privKey, nil),
+		expiresAt:  time.Now().Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey(sessionID)
+	if old, ok := s.entries[key]; ok {
+		old.wipe()
+	}
+	s.entries[key] = e
+	return e.expiresAt, nil
+}
+
+// Get 使用请求令牌中的登录会话 ID 解密并返回私钥副本，调用方用完后应清零
+func (s *Store) Get(userID int64, sessionID string) ([]byte, error) {
+	s.mu.Lock()
+	e, ok := s.entries[sessionKey(sessionID)]
+	if !ok || e.userID != userID {
+		s.mu.Unlock()
+		return nil, ErrLocked
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(s.entries, sessionKey(sessionID))
+		e.wipe()
+		s.mu.Unlock()
+		return nil, ErrLocked
+	}
+	salt := append([]byte(nil), e.salt...)
+	ciphertext := append([]byte(nil), e.ciphertext...)
+	s.mu.Unlock()
+
+	gcm, err := sessionCipher(salt, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrLocked
+	}
+	privKey, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrLocked
+	}
+	return privKey, nil
+}
+
+// Status 返回会话的过期时间
+func (s *Store) Status(userID int64, sessionID string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[sessionKey(sessionID)]
+	if !ok || e.userID != userID || time.Now().After(e.expiresAt) {
+		return time.Time{}, false
+	}
+	return e.expiresAt, true
+}
+
+// Lock 立即结束登录会话对应的解锁会话
+func (s *Store) Lock(userID int64, sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey(sessionID)
+	e, ok := s.entries[key]
+	if !ok || e.userID != userID {
+		return false
+	}
+	delete(s.entries, key)
+	e.wipe()
+	return true
+}
+
+// LockUser 结束用户的全部解锁会话（例如密码重置后）
+func (s *Store) LockUser(userID int64) {
+	s.LockUserExcept(userID, "")
+}
+
+// LockUserExcept 结束用户除 keepSessionID 以外的全部解锁会话（例如注销其他登录会话后）
+func (s *Store) LockUserExcept(userID int64, keepSessionID string) {
+	keep := sessionKey(keepSessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if e.userID == userID && (keepSessionID == "" || key != keep) {
+			delete(s.entries, key)
+			e.wipe()
+		}
+	}
+}
+
+func (s *Store) sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, key)
+			e.wipe()
+		}
+	}
+}
+
+func (e *entry) wipe() {
+	for i := range e.ciphertext {
+		e.ciphertext[i] = 0
+	}
+	for i := range e.salt {
+		e.salt[i] = 0
+	}
+}
+
+// sessionKey 内存中只保存会话 ID 的哈希，会话 ID 本身只出现在请求的访问令牌中
+func sessionKey(sessionID string) [32]byte {
+	return sha256.Sum256([]byte("unlock-key:" + sessionID))
+}
+
+// sessionCipher 会话密钥 = SHA-256(salt || 会话 ID)，没有携带该会话 ID 的访问令牌无法解密
+func sessionCipher(salt []byte, sessionID string) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(sessionID))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,50 @@
+package walletlink
+
+import (
+	"time"
+)
+
+// ChallengeModel GORM 绑定挑战模型（一次性）
+type ChallengeModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"index:idx_link_challenge_user_address;not null;column:user_id"`
+	Address   string    `gorm:"index:idx_link_challenge_user_address;not null;column:address"`
+	Label     string    `gorm:"column:label"`
+	Message   string    `gorm:"not null;column:message"` // 需要外部钱包签名的原文
+	ExpiresAt time.Time `gorm:"not null;column:expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (ChallengeModel) TableName() string {
+	return "wallet_link_challenges"
+}
+
+// LinkedWalletModel GORM 已绑定的外部钱包模型
+type LinkedWalletModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"uniqueIndex:idx_linked_wallet_user_address;not null;column:user_id"`
+	Address   string    `gorm:"uniqueIndex:idx_linked_wallet_user_address;not null;column:address"`
+	Label     string    `gorm:"column:label"`
+	LinkedAt  time.Time `gorm:"not null;column:linked_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;column:created_at"`
+}
+
+// TableName 指定表名
+func (LinkedWalletModel) TableName() string {
+	return "linked_wallets"
+}
+
+// PolicyModel GORM 提现冷静期策略模型（每个用户一条，未设置时使用默认值）；存在即表示已启用提现白名单
+type PolicyModel struct {
+	UserID            int64      `gorm:"primaryKey;column:user_id"`
+	CoolingOffSeconds int64      `gorm:"not null;column:cooling_off_seconds"`
+	PendingSeconds    *int64     `gorm:"column:pending_seconds"`      // 缩短冷静期的待生效值
+	PendingEffectAt   *time.Time `gorm:"column:pending_effective_at"` // 待生效值的生效时间（旧冷静期结束后）
+	UpdatedAt         time.Time  `gorm:"column:updated_at"`
+}
+
+// TableName 指定表名
+func (PolicyModel) TableName() string {
+	return "withdraw_policies"
+}
@@ -0,0 +1,271 @@
+package walletlink
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const challengeTTL = 10 * time.Minute
+
+var (
+	// ErrChallengeNotFound 没有有效的绑定挑战
+	ErrChallengeNotFound = errors.New("绑定挑战不存在或已过期，请重新获取")
+	// ErrInvalidSignature 签名与外部地址不匹配
+	ErrInvalidSignature = errors.New("签名无效或与地址不匹配")
+	// ErrNotLinked 地址未绑定
+	ErrNotLinked = errors.New("该地址未绑定，只能提现到已绑定的外部钱包")
+	// ErrCoolingOff 地址仍在冷静期内
+	ErrCoolingOff = errors.New("该地址仍在冷静期内，暂不能提现")
+)
+
+// Service 负责外部钱包绑定与提现白名单
+type Service struct {
+	db                *gorm.DB
+	defaultCoolingOff time.Duration
+	minCoolingOff     time.Duration
+}
+
+// Policy 用户当前的冷静期策略
+type Policy struct {
+	CoolingOff        time.Duration
+	PendingCoolingOff *time.Duration
+	PendingEffectAt   *time.Time
+}
+
+// NewService 创建服务并初始化表结构；defaultCoolingOff 为未设置策略时的冷静期，minCoolingOff 为允许设置的最小值
+func NewService(db *gorm.DB, defaultCoolingOff, minCoolingOff time.Duration) (*Service, error) {
+	s := &Service{db: db, defaultCoolingOff: defaultCoolingOff, minCoolingOff: minCoolingOff}
+	if err := s.db.AutoMigrate(&ChallengeModel{}, &LinkedWalletModel{}, &PolicyModel{}); err != nil {
+		return nil, fmt.Errorf("自动迁移钱包绑定表结构失败: %w", err)
+	}
+	return s, nil
+}
+
+// challengeMessage 生成需要外部钱包 personal_sign 的挑战原文
+func challengeMessage(userID int64, address common.Address, nonce string, expiresAt time.Time) string {
+	return fmt.Sprintf("QXB wallet link\nUser: %d\nAddress: %s\nNonce: %s\nExpires At: %s",
+		userID, address.Hex(), nonce, expiresAt.UTC().Format(time.RFC3339))
+}
+
+// NewChallenge 为外部地址生成绑定挑战（10 分钟有效），同一地址的旧挑战作废
+func (s *Service) NewChallenge(userID int64, address common.Address, label string) (*ChallengeModel, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	challenge := &ChallengeModel{
+		UserID:    userID,
+		Address:   address.Hex(),
+		Label:     normalizeLabel(label),
+		ExpiresAt: now.Add(challengeTTL),
+		CreatedAt: now,
+	}
+	challenge.Message = challengeMessage(userID, address, hex.EncodeToString(b), challenge.ExpiresAt)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("(user_id = ? AND address = ?) OR expires_at < ?", userID, address.Hex(), now).
+			Delete(&ChallengeModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(challenge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// Link 校验外部钱包对挑战的签名并绑定地址；冷静期从绑定时开始计算。
+// 首次绑定时写入默认策略，此后（即使解除全部绑定）转账也只能发往已绑定的地址
+func (s *Service) Link(userID int64, address common.Address, signature []byte) (*LinkedWalletModel, error) {
+	var challenge ChallengeModel
+	err := s.db.Where("user_id = ? AND address = ? AND expires_at > ?", userID, address.Hex(), time.Now()).
+		First(&challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(address, challenge.Message, signature); err != nil {
+		return nil, err
+	}
+
+	linked := &LinkedWalletModel{
+		UserID:    userID,
+		Address:   address.Hex(),
+		Label:     challenge.Label,
+		LinkedAt:  time.Now(),
+		CreatedAt: time.Now(),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 删除挑战，保证只能使用一次
+		result := tx.Delete(&challenge)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrChallengeNotFound
+		}
+		policy := PolicyModel{UserID: userID, CoolingOffSeconds: int64(s.defaultCoolingOff / time.Second), UpdatedAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&policy).Error; err != nil {
+			return err
+		}
+		var existing LinkedWalletModel
+		if tx.Where("user_id = ? AND address = ?", userID, address.Hex()).First(&existing).Error == nil {
+			*linked = existing
+			return nil
+		}
+		return tx.Create(linked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return linked, nil
+}
+
+func verifySignature(address common.Address, message string, signature []byte) error {
+	if len(signature) != crypto.SignatureLength {
+		return ErrInvalidSignature
+	}
+	sig := common.CopyBytes(signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != address {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// List 列出用户绑定的外部钱包
+func (s *Service) List(userID int64) ([]LinkedWalletModel, error) {
+	var wallets []LinkedWalletModel
+	err := s.db.Where("user_id = ?", userID).Order("linked_at").Find(&wallets).Error
+	return wallets, err
+}
+
+// Unlink 解除绑定
+func (s *Service) Unlink(userID, id int64) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&LinkedWalletModel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotLinked
+	}
+	return nil
+}
+
+// GetPolicy 返回用户当前生效的冷静期；到期的待生效值在读取时应用
+func (s *Service) GetPolicy(userID int64) (*Policy, error) {
+	var model PolicyModel
+	err := s.db.First(&model, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Policy{CoolingOff: s.defaultCoolingOff}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{CoolingOff: time.Duration(model.CoolingOffSeconds) * time.Second}
+	if model.PendingSeconds != nil && model.PendingEffectAt != nil {
+		pending := time.Duration(*model.PendingSeconds) * time.Second
+		if time.Now().Before(*model.PendingEffectAt) {
+			policy.PendingCoolingOff = &pending
+			policy.PendingEffectAt = model.PendingEffectAt
+		} else {
+			policy.CoolingOff = pending
+		}
+	}
+	return policy, nil
+}
+
+// SetCoolingOff 设置冷静期：延长立即生效；缩短要等当前冷静期结束后才生效，
+// 避免盗号者先缩短冷静期再绑定自己的地址立即提现
+func (s *Service) SetCoolingOff(userID int64, coolingOff time.Duration) (*Policy, error) {
+	if coolingOff < s.minCoolingOff {
+		return nil, fmt.Errorf("冷静期不能短于 %s", s.minCoolingOff)
+	}
+	current, err := s.GetPolicy(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	model := PolicyModel{UserID: userID, UpdatedAt: now}
+	if coolingOff >= current.CoolingOff {
+		model.CoolingOffSeconds = int64(coolingOff / time.Second)
+	} else {
+		pending := int64(coolingOff / time.Second)
+		effectAt := now.Add(current.CoolingOff)
+		model.CoolingOffSeconds = int64(current.CoolingOff / time.Second)
+		model.PendingSeconds = &pending
+		model.PendingEffectAt = &effectAt
+	}
+	if err := s.db.Save(&model).Error; err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(userID)
+}
+
+// UsableAt 返回绑定地址可以提现的时间
+func (s *Service) UsableAt(w *LinkedWalletModel, policy *Policy) time.Time {
+	return w.LinkedAt.Add(policy.CoolingOff)
+}
+
+// Restricted 判断用户是否已启用提现白名单（绑定过外部钱包或设置过冷静期）
+func (s *Service) Restricted(userID int64) (bool, error) {
+	var policies, wallets int64
+	if err := s.db.Model(&PolicyModel{}).Where("user_id = ?", userID).Count(&policies).Error; err != nil {
+		return false, err
+	}
+	if policies > 0 {
+		return true, nil
+	}
+	if err := s.db.Model(&LinkedWalletModel{}).Where("user_id = ?", userID).Count(&wallets).Error; err != nil {
+		return false, err
+	}
+	return wallets > 0, nil
+}
+
+// CheckWithdraw 检查地址是否已绑定且已过冷静期
+func (s *Service) CheckWithdraw(userID int64, to common.Address) error {
+	var linked LinkedWalletModel
+	err := s.db.Where("user_id = ? AND address = ?", userID, to.Hex()).First(&linked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotLinked
+	}
+	if err != nil {
+		return err
+	}
+	policy, err := s.GetPolicy(userID)
+	if err != nil {
+		return err
+	}
+	if time.Now().Before(s.UsableAt(&linked, policy)) {
+		return fmt.Errorf("%w（可提现时间 %s）", ErrCoolingOff, s.UsableAt(&linked, policy).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// normalizeLabel 去掉首尾空白并限制长度
+func normalizeLabel(label string) string {
+	runes := []rune(strings.TrimSpace(label))
+	if len(runes) > 64 {
+		runes = runes[:64]
+	}
+	return string(runes)
+}